@@ -53,3 +53,112 @@ func BenchmarkDeserializer(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkSerializerCompressed(b *testing.B) {
+	ser := NewSerializer().SetCompress(true)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := ser.Serialize(&testStruct)
+		if err != nil {
+			b.Error(err)
+		}
+		_ = data
+	}
+}
+
+func BenchmarkDeserializerCompressed(b *testing.B) {
+	ser := NewSerializer().SetCompress(true)
+	data, err := ser.Serialize(&testStruct)
+	if err != nil {
+		b.Error(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var newt_struct Testie
+		err := ser.Deserialize(data, &newt_struct)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func BenchmarkCompress(b *testing.B) {
+	data, err := NewSerializer().Serialize(&testStruct)
+	if err != nil {
+		b.Error(err)
+	}
+	var buf []byte
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		buf, err = AppendCompress(buf[:0], data)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func BenchmarkDecompress(b *testing.B) {
+	data, err := NewSerializer().Serialize(&testStruct)
+	if err != nil {
+		b.Error(err)
+	}
+	compressed, err := Compress(data)
+	if err != nil {
+		b.Error(err)
+	}
+	var buf []byte
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		buf, err = AppendDecompress(buf[:0], compressed, len(data))
+		if err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func BenchmarkAppendSerialize(b *testing.B) {
+	ser := NewSerializer()
+	buf := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(10, func() {
+		buf, _ = ser.AppendSerialize(buf[:0], &ASTRUCT)
+	})
+	if allocs != 0 {
+		b.Fatalf("expected no allocations, got %v", allocs)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		buf, err = ser.AppendSerialize(buf[:0], &ASTRUCT)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func BenchmarkDeserializerZeroCopy(b *testing.B) {
+	var newt_struct Testie
+	ser := NewSerializer().SetZeroCopy(true)
+	data, err := ser.AppendSerialize(nil, &testStruct)
+	if err != nil {
+		b.Error(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		err := ser.Deserialize(data, &newt_struct)
+		if err != nil {
+			b.Error(err)
+		}
+	}
+}
+
+func BenchmarkSum64(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := Sum64(&testStruct); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package tinyserializer
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+)
+
+// Blob stores a value in a database BLOB column, serialized with the serializer.
+// It implements driver.Valuer and sql.Scanner:
+//
+//	var session Blob[Session]
+//	err := db.QueryRow("SELECT data FROM sessions WHERE id = ?", id).Scan(&session)
+//	_, err = db.Exec("UPDATE sessions SET data = ? WHERE id = ?", Blob[Session]{V: s, Compress: true}, id)
+//
+// Stored values always start with a payload header, so compressed and uncompressed values can be read alike.
+type Blob[T any] struct {
+	V T
+	// Compress sets whether the value is compressed when it is stored
+	Compress bool
+}
+
+// Value serializes the value for storage
+func (b Blob[T]) Value() (driver.Value, error) {
+	var s = NewSerializer().SetCompress(true)
+	if !b.Compress {
+		// Only write the header
+		s.SetCompressThreshold(math.MaxInt)
+	}
+	data, err := s.Serialize(&b.V)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize %T: %w", b.V, err)
+	}
+	return data, nil
+}
+
+// Scan deserializes a stored value, NULL is scanned as the zero value
+func (b *Blob[T]) Scan(src interface{}) error {
+	var zero T
+	b.V = zero
+
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, b)
+	}
+	if err := NewSerializer().SetCompress(true).Deserialize(data, &b.V); err != nil {
+		return fmt.Errorf("failed to deserialize %T: %w", b.V, err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package tinyserializer
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// blobDriver is a database driver storing a single table of id and data columns in memory.
+// Statements starting with INSERT take the id and data, all others select the data of an id.
+type blobDriver struct {
+	mu   sync.Mutex
+	rows map[int64]interface{}
+}
+
+type blobConn struct{ d *blobDriver }
+
+type blobStmt struct {
+	d     *blobDriver
+	query string
+}
+
+type blobRows struct {
+	data []interface{}
+}
+
+func (d *blobDriver) Open(string) (driver.Conn, error) { return blobConn{d}, nil }
+
+func (c blobConn) Prepare(query string) (driver.Stmt, error) { return &blobStmt{c.d, query}, nil }
+func (c blobConn) Close() error                              { return nil }
+func (c blobConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (s *blobStmt) Close() error  { return nil }
+func (s *blobStmt) NumInput() int { return -1 }
+
+func (s *blobStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.rows[args[0].(int64)] = args[1]
+	return driver.RowsAffected(1), nil
+}
+
+func (s *blobStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	data, ok := s.d.rows[args[0].(int64)]
+	if !ok {
+		return &blobRows{}, nil
+	}
+	return &blobRows{data: []interface{}{data}}, nil
+}
+
+func (r *blobRows) Columns() []string { return []string{"data"} }
+func (r *blobRows) Close() error      { return nil }
+
+func (r *blobRows) Next(dest []driver.Value) error {
+	if len(r.data) == 0 {
+		return io.EOF
+	}
+	dest[0], r.data = r.data[0], r.data[1:]
+	return nil
+}
+
+var blobDB = &blobDriver{rows: make(map[int64]interface{})}
+
+func init() {
+	sql.Register("tinyblob", blobDB)
+}
+
+func TestBlob(t *testing.T) {
+	db, err := sql.Open("tinyblob", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("INSERT", int64(1), Blob[AllStruct]{V: *All_S}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec("INSERT", int64(2), &Blob[AllStruct]{V: *All_S, Compress: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec("INSERT", int64(3), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	plain, compressed := blobDB.rows[1].([]byte), blobDB.rows[2].([]byte)
+	if len(compressed) >= len(plain) {
+		t.Fatalf("expected the compressed blob to be smaller, got %d >= %d", len(compressed), len(plain))
+	}
+
+	for _, id := range []int64{1, 2} {
+		var blob = Blob[AllStruct]{V: AllStruct{StringField: "overwritten"}}
+		if err = db.QueryRow("SELECT", id).Scan(&blob); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(&blob.V, All_S) {
+			t.Fatalf("row %d: expected %+v, got %+v", id, All_S, blob.V)
+		}
+	}
+
+	var null = Blob[AllStruct]{V: *All_S}
+	if err = db.QueryRow("SELECT", int64(3)).Scan(&null); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(null.V, AllStruct{}) {
+		t.Fatalf("expected NULL to scan as the zero value, got %+v", null.V)
+	}
+}
+
+func TestBlobScanErrors(t *testing.T) {
+	var blob Blob[AllStruct]
+	if err := blob.Scan(int64(1)); err == nil {
+		t.Fatal("expected error scanning an integer")
+	}
+	value, err := Blob[AllStruct]{V: *All_S}.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data = value.([]byte)
+	if err = blob.Scan(data[:len(data)/2]); err == nil {
+		t.Fatal("expected error scanning a truncated blob")
+	}
+	if err = blob.Scan(string(data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlobScalar(t *testing.T) {
+	value, err := Blob[[]string]{V: []string{"a", "b"}}.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blob Blob[[]string]
+	if err = blob.Scan(value); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(blob.V, []string{"a", "b"}) {
+		t.Fatalf("expected [a b], got %v", blob.V)
+	}
+}
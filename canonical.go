@@ -0,0 +1,129 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// SetCanonical sets whether values are encoded canonically.
+//
+// In canonical mode, equal values always encode to identical bytes, which makes
+// the output suitable for hashing, content addressing and cache keys:
+//   - map entries are sorted by the encoded bytes of their keys,
+//     which for integers is not their numeric order
+//   - NaN is always written as the same NaN, and -0 as 0
+//   - generated MarshalTiny methods are not used
+//
+// Canonical output is read like any other output, the setting does not affect deserializing.
+func (s *Serializer) SetCanonical(canonical bool) *Serializer {
+	s.canonical = canonical
+	return s
+}
+
+// Bits of the NaN written in canonical mode
+const (
+	canonicalNaN64 = 0x7ff8000000000001
+	canonicalNaN32 = 0x7fc00000
+)
+
+// canonicalFloat64 returns the bits of the float64, with NaN and -0 normalized
+func canonicalFloat64(f float64) uint64 {
+	switch {
+	case f != f:
+		return canonicalNaN64
+	case f == 0:
+		return 0
+	}
+	return math.Float64bits(f)
+}
+
+// canonicalFloat32 returns the bits of the float32, with NaN and -0 normalized
+func canonicalFloat32(f float32) uint32 {
+	switch {
+	case f != f:
+		return canonicalNaN32
+	case f == 0:
+		return 0
+	}
+	return math.Float32bits(f)
+}
+
+// appendCanonicalScalar appends the scalar value to dst, with floats normalized
+func appendCanonicalScalar(dst []byte, value reflect.Value) ([]byte, error) {
+	switch value.Kind() {
+	case reflect.Float32:
+		return appendUint(dst, uint64(canonicalFloat32(float32(value.Float()))), 4), nil
+	case reflect.Float64:
+		return appendUint(dst, canonicalFloat64(value.Float()), 8), nil
+	case reflect.Complex64:
+		var c = value.Complex()
+		dst = binary.LittleEndian.AppendUint16(dst, 8)
+		dst = binary.LittleEndian.AppendUint32(dst, canonicalFloat32(float32(real(c))))
+		return binary.LittleEndian.AppendUint32(dst, canonicalFloat32(float32(imag(c)))), nil
+	case reflect.Complex128:
+		var c = value.Complex()
+		dst = binary.LittleEndian.AppendUint16(dst, 16)
+		dst = binary.LittleEndian.AppendUint64(dst, canonicalFloat64(real(c)))
+		return binary.LittleEndian.AppendUint64(dst, canonicalFloat64(imag(c))), nil
+	}
+	return appendScalar(dst, value)
+}
+
+// mapEntry holds the offsets of an encoded map entry
+type mapEntry struct {
+	start, key, end int
+}
+
+// appendCanonicalMap appends the length of the map, followed by its entries sorted by their encoded keys
+func (s *Serializer) appendCanonicalMap(dst []byte, value reflect.Value) ([]byte, error) {
+	if value.Len() < 2 {
+		return s.appendMap(dst, value)
+	}
+	var err error
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(value.Len()))
+
+	// Encode the entries in iteration order, and record where each of them starts.
+	// Offsets are relative to start, as dst may be reallocated while appending.
+	var start = len(dst)
+	s.sortingMaps++
+	defer func() { s.sortingMaps-- }()
+	var entries = make([]mapEntry, 0, value.Len())
+	var mapType = value.Type()
+	var key = reflect.New(mapType.Key()).Elem()
+	var elem = reflect.New(mapType.Elem()).Elem()
+	var iter = value.MapRange()
+	for iter.Next() {
+		key.SetIterKey(iter)
+		elem.SetIterValue(iter)
+		var entry = mapEntry{start: len(dst) - start}
+		if dst, err = s.appendValue(dst, key); err != nil {
+			return nil, fmt.Errorf("failed to serialize map key: %w", err)
+		}
+		entry.key = len(dst) - start
+		if dst, err = s.appendValue(dst, elem); err != nil {
+			return nil, fmt.Errorf("failed to serialize map value: %w", err)
+		}
+		entry.end = len(dst) - start
+		entries = append(entries, entry)
+	}
+
+	// Sort by key, and by the whole entry for keys which encode the same, such as NaN
+	var encoded = append([]byte(nil), dst[start:]...)
+	sort.Slice(entries, func(i, j int) bool {
+		var a, b = entries[i], entries[j]
+		if c := bytes.Compare(encoded[a.start:a.key], encoded[b.start:b.key]); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(encoded[a.start:a.end], encoded[b.start:b.end]) < 0
+	})
+
+	dst = dst[:start]
+	for _, entry := range entries {
+		dst = append(dst, encoded[entry.start:entry.end]...)
+	}
+	return dst, nil
+}
@@ -0,0 +1,90 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type CanonicalStruct struct {
+	Names  map[string]int64          `tiny:"names"`
+	Nested map[int]map[string]string `tiny:"nested"`
+	Float  float64                   `tiny:"float"`
+	Floats []float32                 `tiny:"floats,packed"`
+	NaNs   map[float64]bool          `tiny:"nans"`
+}
+
+func newCanonicalStruct(keys []string) CanonicalStruct {
+	var v = CanonicalStruct{
+		Names:  make(map[string]int64),
+		Nested: make(map[int]map[string]string),
+		NaNs:   map[float64]bool{1: true, 2: false},
+	}
+	for i, key := range keys {
+		v.Names[key] = int64(len(key))
+		if v.Nested[len(key)] == nil {
+			v.Nested[len(key)] = make(map[string]string)
+		}
+		v.Nested[len(key)][key] = key
+		v.NaNs[math.NaN()] = i%2 == 0
+	}
+	return v
+}
+
+func TestCanonicalMaps(t *testing.T) {
+	var keys = []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta", "iota", "kappa"}
+	var reversed = make([]string, len(keys))
+	for i, key := range keys {
+		reversed[len(keys)-1-i] = key
+	}
+
+	var s = NewSerializer().SetCanonical(true)
+	var v = newCanonicalStruct(keys)
+	expected, err := s.AppendSerialize(nil, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		var other = newCanonicalStruct(reversed)
+		serialized, err := s.AppendSerialize(nil, &other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected, serialized) {
+			t.Fatal("expected equal values to serialize to identical bytes")
+		}
+	}
+
+	// Canonical output is read by any serializer
+	var deserialized CanonicalStruct
+	if err = NewSerializer().Deserialize(expected, &deserialized); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v.Names, deserialized.Names) || !reflect.DeepEqual(v.Nested, deserialized.Nested) {
+		t.Fatalf("expected %+v, got %+v", v, deserialized)
+	}
+}
+
+func TestCanonicalFloats(t *testing.T) {
+	var s = NewSerializer().SetCanonical(true)
+	var serialize = func(f float64) []byte {
+		var v = CanonicalStruct{Float: f, Floats: []float32{float32(f)}}
+		data, err := s.AppendSerialize(nil, &v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	if !bytes.Equal(serialize(math.Copysign(0, -1)), serialize(0)) {
+		t.Fatal("expected -0 and 0 to serialize to identical bytes")
+	}
+	var otherNaN = math.Float64frombits(0x7ff8000000000abc)
+	if !bytes.Equal(serialize(math.NaN()), serialize(otherNaN)) {
+		t.Fatal("expected all NaNs to serialize to identical bytes")
+	}
+	if bytes.Equal(serialize(1), serialize(0)) {
+		t.Fatal("expected different floats to serialize to different bytes")
+	}
+}
@@ -0,0 +1,132 @@
+// Command tinydump prints an annotated listing of a serialized payload,
+// to inspect data which fails to decode.
+//
+// Usage:
+//
+//	tinydump [-type name] [-packing mode] [file]
+//	tinydump json -type name [-packing mode] [file]
+//	tinydump fromjson -type name [-packing mode] [-compress] [file]
+//
+// The input is read from the file, or from stdin if no file is given.
+// A payload header or gzip compression is detected automatically.
+//
+// With -type, the payload is listed as a tree of the fields and values of the type,
+// showing the offset and bytes of every length prefix and value.
+// The type must be registered in internal/tinytypes, -list lists the registered types.
+// Without -type, the payload is listed as a hex dump.
+//
+// The json subcommand converts the payload to JSON keyed by the tiny tag names of the fields,
+// and fromjson converts such JSON back to a payload, which is written to stdout.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/Nigel2392/tinyserializer"
+	"github.com/Nigel2392/tinyserializer/internal/tinytypes"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tinydump: ")
+
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
+
+// run runs the command with the given arguments
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	var command = "dump"
+	if len(args) > 0 && (args[0] == "json" || args[0] == "fromjson") {
+		command, args = args[0], args[1:]
+	}
+
+	var flags = flag.NewFlagSet("tinydump "+command, flag.ContinueOnError)
+	var (
+		typeName = flags.String("type", "", "registered type of the payload")
+		packing  = flags.String("packing", "none", "packing the payload was written with: none, raw, varint or delta")
+		compress = flags.Bool("compress", false, "compress the payload written by fromjson")
+		list     = flags.Bool("list", false, "list the registered types")
+	)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *list {
+		for _, name := range tinytypes.Names() {
+			fmt.Fprintln(stdout, name)
+		}
+		return nil
+	}
+
+	var schema interface{}
+	if *typeName != "" {
+		var ok bool
+		if schema, ok = tinytypes.Types[*typeName]; !ok {
+			return fmt.Errorf("unknown type %s, see -list for the registered types", *typeName)
+		}
+	} else if command != "dump" {
+		return fmt.Errorf("%s requires -type", command)
+	}
+
+	var s = tinyserializer.NewSerializer()
+	switch *packing {
+	case "none":
+	case "raw":
+		s.SetPacking(tinyserializer.PackRaw)
+	case "varint":
+		s.SetPacking(tinyserializer.PackVarint)
+	case "delta":
+		s.SetPacking(tinyserializer.PackDelta)
+	default:
+		return fmt.Errorf("unknown packing %s", *packing)
+	}
+
+	data, err := readInput(flags.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	switch command {
+	case "json":
+		// Payloads with a header are read whether they are compressed or not
+		s.SetCompress(isHeader(data))
+		if data, err = s.ToJSON(data, schema); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	case "fromjson":
+		s.SetCompress(*compress)
+		if data, err = s.FromJSON(data, schema); err != nil {
+			return err
+		}
+	default:
+		return s.Dump(stdout, data, schema)
+	}
+	_, err = stdout.Write(data)
+	return err
+}
+
+// isHeader reports whether the data starts with a payload header or a gzip stream
+func isHeader(data []byte) bool {
+	return len(data) >= 3 && (data[0] == 't' && data[1] == 'y' || data[0] == 0x1f && data[1] == 0x8b)
+}
+
+// readInput reads the file named by the arguments, or stdin if there are none
+func readInput(args []string, stdin io.Reader) ([]byte, error) {
+	switch {
+	case len(args) > 1:
+		return nil, errors.New("expected a single file")
+	case len(args) == 0 || args[0] == "-":
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(args[0])
+}
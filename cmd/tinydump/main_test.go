@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Nigel2392/tinyserializer"
+	"github.com/Nigel2392/tinyserializer/internal/tinytest"
+)
+
+func TestRun(t *testing.T) {
+	var v = &tinytest.Child{Name: "child", Values: []int64{1, 2}}
+	data, err := tinyserializer.NewSerializer().Serialize(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file = filepath.Join(t.TempDir(), "payload")
+	if err = os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Child is not registered, so read it as the start of a Nested
+	var out bytes.Buffer
+	if err = run([]string{"-type", "tinytest.Nested", "-"}, bytes.NewReader(data), &out); err == nil {
+		t.Fatal("expected error dumping as the wrong type")
+	}
+	if !strings.Contains(out.String(), `name string size=5 = "child"`) {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	// Without a type, the file is listed as a hex dump
+	out.Reset()
+	if err = run([]string{file}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "000000  05 00 63 68 69 6c 64") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestRunPacked(t *testing.T) {
+	var v = &tinytest.Packed{Unpacked: []int16{1, 2}}
+	data, err := tinyserializer.NewSerializer().SetPacking(tinyserializer.PackVarint).Serialize(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err = run([]string{"-type", "tinytest.Packed", "-packing", "varint"}, bytes.NewReader(data), &out); err != nil {
+		t.Fatalf("%v:\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "unpacked []int16 packed len=2 mode=varint") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestRunList(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"-list"}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "tinytest.Collections\ntinytest.Fallback\n") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+	if err := run([]string{"-type", "Missing"}, nil, &out); err == nil || !strings.Contains(err.Error(), "unknown type") {
+		t.Fatalf("expected unknown type error, got %v", err)
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	var v = &tinytest.Named{Level: -3, Tags: tinytest.Tags{"a", "b"}, Raw: tinytest.Raw("raw")}
+	for _, compress := range []bool{false, true} {
+		data, err := tinyserializer.NewSerializer().SetCompress(compress).Serialize(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if err = run([]string{"json", "-type", "tinytest.Named"}, bytes.NewReader(data), &out); err != nil {
+			t.Fatal(err)
+		}
+		var expected = `{"level":-3,"tags":["a","b"],"raw":"cmF3","index":{}}` + "\n"
+		if out.String() != expected {
+			t.Fatalf("expected %s, got %s", expected, out.String())
+		}
+
+		var payload bytes.Buffer
+		var args = []string{"fromjson", "-type", "tinytest.Named"}
+		if compress {
+			args = append(args, "-compress")
+		}
+		if err = run(args, &out, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(payload.Bytes(), data) {
+			t.Fatalf("expected fromjson to reproduce the payload\n%v\n%v", data, payload.Bytes())
+		}
+	}
+
+	if err := run([]string{"json"}, bytes.NewReader(nil), new(bytes.Buffer)); err == nil || !strings.Contains(err.Error(), "requires -type") {
+		t.Fatalf("expected missing type error, got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/Nigel2392/tinyserializer/internal/tinytest"
+	"github.com/Nigel2392/tinyserializer/internal/tinytypes"
+)
+
+// The installed command has no types, the tests use the fixtures of tinytest
+func init() {
+	tinytypes.Register("tinytest.Scalars", (*tinytest.Scalars)(nil))
+	tinytypes.Register("tinytest.Collections", (*tinytest.Collections)(nil))
+	tinytypes.Register("tinytest.Nested", (*tinytest.Nested)(nil))
+	tinytypes.Register("tinytest.Named", (*tinytest.Named)(nil))
+	tinytypes.Register("tinytest.Fallback", (*tinytest.Fallback)(nil))
+	tinytypes.Register("tinytest.Packed", (*tinytest.Packed)(nil))
+}
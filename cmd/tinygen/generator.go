@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+const importPath = "github.com/Nigel2392/tinyserializer"
+
+// Kinds of types the generator knows how to encode without reflection
+const (
+	kindString = "string"
+	kindBool   = "bool"
+	kindInt    = "int"
+	kindUint   = "uint"
+	kindFloat  = "float"
+	kindCmplx  = "complex"
+	kindBytes  = "bytes"
+	kindSlice  = "slice"
+	kindMap    = "map"
+	kindStruct = "struct"
+	// Types which are left to the reflective serializer
+	kindValue = "value"
+)
+
+// Basic types, and the kind and name of the Append function used to encode them
+var basicTypes = map[string]struct{ kind, appendFunc string }{
+	"string":     {kindString, "AppendString"},
+	"bool":       {kindBool, "AppendBool"},
+	"int":        {kindInt, "AppendInt"},
+	"int8":       {kindInt, "AppendInt8"},
+	"int16":      {kindInt, "AppendInt16"},
+	"int32":      {kindInt, "AppendInt32"},
+	"rune":       {kindInt, "AppendInt32"},
+	"int64":      {kindInt, "AppendInt64"},
+	"uint":       {kindUint, "AppendUint"},
+	"uint8":      {kindUint, "AppendUint8"},
+	"byte":       {kindUint, "AppendUint8"},
+	"uint16":     {kindUint, "AppendUint16"},
+	"uint32":     {kindUint, "AppendUint32"},
+	"uint64":     {kindUint, "AppendUint64"},
+	"uintptr":    {kindUint, "AppendUintptr"},
+	"float32":    {kindFloat, "AppendFloat32"},
+	"float64":    {kindFloat, "AppendFloat64"},
+	"complex64":  {kindCmplx, "AppendComplex64"},
+	"complex128": {kindCmplx, "AppendComplex128"},
+}
+
+// typeInfo describes how a type is encoded
+type typeInfo struct {
+	kind string
+	// Source of the type expression, as written in the struct
+	expr string
+	// Name of the basic type for scalars
+	basic string
+	// Element and key types of slices and maps
+	elem, key *typeInfo
+}
+
+type generator struct {
+	pkgName   string
+	typeSpecs map[string]*ast.TypeSpec
+	structs   []structDecl
+
+	buf *bytes.Buffer
+	// Whether the function being generated uses err
+	usesErr bool
+}
+
+func newGenerator() *generator {
+	return &generator{
+		typeSpecs: make(map[string]*ast.TypeSpec),
+		buf:       new(bytes.Buffer),
+	}
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g.buf, format, args...)
+}
+
+// resolve determines how the type expression is encoded
+func (g *generator) resolve(expr ast.Expr) *typeInfo {
+	var info = &typeInfo{kind: kindValue, expr: types.ExprString(expr)}
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if basic, ok := basicTypes[t.Name]; ok {
+			info.kind, info.basic = basic.kind, t.Name
+			return info
+		}
+		var spec, ok = g.typeSpecs[t.Name]
+		if !ok || spec.TypeParams != nil {
+			return info
+		}
+		if _, ok := spec.Type.(*ast.StructType); ok {
+			info.kind = kindStruct
+			return info
+		}
+		// Named types are encoded like their underlying type
+		var underlying = g.resolve(spec.Type)
+		if underlying.kind == kindStruct || underlying.kind == kindValue {
+			return info
+		}
+		underlying.expr = info.expr
+		return underlying
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return info
+		}
+		if ident, ok := t.Elt.(*ast.Ident); ok && (ident.Name == "byte" || ident.Name == "uint8") {
+			info.kind = kindBytes
+			return info
+		}
+		var elem = g.resolve(t.Elt)
+		if elem.basic == "uint8" || elem.basic == "byte" {
+			// Slices of named bytes are byte slices, but can not be passed as []byte
+			return info
+		}
+		info.kind, info.elem = kindSlice, elem
+		return info
+	case *ast.MapType:
+		info.kind, info.key, info.elem = kindMap, g.resolve(t.Key), g.resolve(t.Value)
+		return info
+	}
+	return info
+}
+
+// packable reports whether the type is a slice which can be packed
+func (t *typeInfo) packable() bool {
+	if t.kind != kindSlice {
+		return false
+	}
+	switch t.elem.kind {
+	case kindInt, kindUint, kindFloat, kindBool:
+		return true
+	}
+	return false
+}
+
+// packing returns the name of the Packing constant for the value of the packed option
+func packing(option string) string {
+	switch strings.TrimPrefix(option, "packed=") {
+	case "varint":
+		return "tinyserializer.PackVarint"
+	case "delta":
+		return "tinyserializer.PackDelta"
+	}
+	return "tinyserializer.PackRaw"
+}
+
+// minSize returns an expression for the minimum encoded size of the type, as checked against slice and map lengths.
+// The size of structs and types left to the reflective serializer is determined at runtime.
+func (t *typeInfo) minSize() string {
+	switch t.kind {
+	case kindStruct, kindValue:
+		return fmt.Sprintf("tinyserializer.EncodedSize((*%s)(nil))", t.expr)
+	case kindBytes, kindSlice, kindMap:
+		return "4"
+	}
+	return "2"
+}
+
+// addSizes returns an expression for the sum of two sizes, adding them up if both are constant
+func addSizes(a, b string) string {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		return strconv.Itoa(x + y)
+	}
+	return a + " + " + b
+}
+
+// generate generates the methods for the given types
+func (g *generator) generate(typeNames []string) ([]byte, error) {
+	g.printf("// Code generated by tinygen. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", g.pkgName)
+	g.printf("import %q\n", importPath)
+
+	for _, name := range typeNames {
+		var spec, ok = g.typeSpecs[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found", name)
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return nil, fmt.Errorf("type %s is not a struct", name)
+		}
+		var fields = structFields(st)
+		g.generateMarshal(name, fields)
+		g.generateUnmarshal(name, fields)
+	}
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w\n%s", err, g.buf.Bytes())
+	}
+	return src, nil
+}
+
+// generateMethod writes the method, declaring err if the body uses it
+func (g *generator) generateMethod(signature string, body func(), ret string) {
+	var outer = g.buf
+	g.buf = new(bytes.Buffer)
+	g.usesErr = false
+	body()
+	var inner = g.buf
+	g.buf = outer
+
+	g.printf("%s {\n", signature)
+	if g.usesErr {
+		g.printf("var err error\n")
+	}
+	g.buf.Write(inner.Bytes())
+	g.printf("return %s\n}\n", ret)
+}
+
+func (g *generator) generateMarshal(name string, fields []field) {
+	g.printf("\n// MarshalTiny appends the serialized %s to dst\n", name)
+	g.generateMethod(fmt.Sprintf("func (v *%s) MarshalTiny(dst []byte) ([]byte, error)", name), func() {
+		for _, f := range fields {
+			var t = g.resolve(f.typ)
+			var src = "v." + f.goName
+			if f.omitEmpty {
+				// Omitempty fields are prefixed with whether they are present
+				g.printf("if %s {\n", g.notZero(src, t))
+				g.printf("dst = tinyserializer.AppendPresence(dst, true)\n")
+			}
+			if f.packed != "" && t.packable() {
+				g.encodePacked(src, t, f.packed)
+			} else {
+				g.encode(src, t, 1)
+			}
+			if f.omitEmpty {
+				g.printf("} else {\ndst = tinyserializer.AppendPresence(dst, false)\n}\n")
+			}
+		}
+	}, "dst, nil")
+}
+
+func (g *generator) generateUnmarshal(name string, fields []field) {
+	g.printf("\n// UnmarshalTiny deserializes %s from the start of data, and returns the number of bytes read\n", name)
+	g.generateMethod(fmt.Sprintf("func (v *%s) UnmarshalTiny(data []byte) (int, error)", name), func() {
+		g.printf("var r = tinyserializer.NewReader(data)\n")
+		for _, f := range fields {
+			var t = g.resolve(f.typ)
+			var dst = "v." + f.goName
+			var fail = fmt.Sprintf("return r.Pos(), tinyserializer.WrapFieldError(%q, err)", f.name)
+			if f.omitEmpty {
+				// Fields which are left out are reset to their zero value
+				g.usesErr = true
+				g.printf("{\nvar present bool\n")
+				g.printf("if present, err = r.Presence(); err != nil {\n%s\n}\n", fail)
+				g.printf("if !present {\nvar zero %s\n%s = zero\n} else {\n", t.expr, dst)
+			}
+			if f.packed != "" && t.packable() {
+				g.decodePacked(dst, t, fail)
+			} else {
+				g.decode(dst, t, 1, fail)
+			}
+			if f.omitEmpty {
+				g.printf("}\n}\n")
+			}
+		}
+	}, "r.Pos(), nil")
+}
+
+// notZero returns an expression which reports whether the value is not its zero value
+func (g *generator) notZero(expr string, t *typeInfo) string {
+	switch t.kind {
+	case kindString:
+		return expr + ` != ""`
+	case kindBool:
+		return expr
+	case kindInt, kindUint, kindFloat, kindCmplx:
+		return expr + " != 0"
+	case kindBytes, kindSlice, kindMap:
+		return expr + " != nil"
+	}
+	return fmt.Sprintf("!tinyserializer.IsZero(&%s)", expr)
+}
+
+// encode writes the code appending the value of the expression to dst
+func (g *generator) encode(src string, t *typeInfo, depth int) {
+	switch t.kind {
+	case kindString:
+		g.usesErr = true
+		g.printf("if dst, err = tinyserializer.AppendString(dst, %s); err != nil {\nreturn nil, err\n}\n", convert(src, t.expr, "string"))
+	case kindBool, kindInt, kindUint, kindFloat, kindCmplx:
+		g.printf("dst = tinyserializer.%s(dst, %s)\n", basicTypes[t.basic].appendFunc, convert(src, t.expr, t.basic))
+	case kindBytes:
+		g.printf("dst = tinyserializer.AppendBytes(dst, %s)\n", src)
+	case kindSlice:
+		var i = fmt.Sprintf("i%d", depth)
+		g.printf("dst = tinyserializer.AppendLength(dst, len(%s))\n", src)
+		g.printf("for %s := range %s {\n", i, src)
+		g.encode(fmt.Sprintf("%s[%s]", src, i), t.elem, depth+1)
+		g.printf("}\n")
+	case kindMap:
+		var k, v = fmt.Sprintf("k%d", depth), fmt.Sprintf("v%d", depth)
+		g.printf("dst = tinyserializer.AppendLength(dst, len(%s))\n", src)
+		g.printf("for %s, %s := range %s {\n", k, v, src)
+		g.encode(k, t.key, depth+1)
+		g.encode(v, t.elem, depth+1)
+		g.printf("}\n")
+	default:
+		// Structs are encoded through the serializer, which prefers their own generated methods
+		g.usesErr = true
+		g.printf("if dst, err = tinyserializer.AppendValue(dst, &%s); err != nil {\nreturn nil, err\n}\n", src)
+	}
+}
+
+// decode writes the code reading the value into the expression
+func (g *generator) decode(dst string, t *typeInfo, depth int, fail string) {
+	g.usesErr = true
+	var x = fmt.Sprintf("x%d", depth)
+	switch t.kind {
+	case kindString, kindBool, kindInt, kindUint, kindFloat, kindCmplx, kindBytes:
+		var readFunc, readType = readMethod(t.kind)
+		g.printf("{\nvar %s %s\n", x, readType)
+		g.printf("if %s, err = r.%s(); err != nil {\n%s\n}\n", x, readFunc, fail)
+		g.printf("%s = %s\n}\n", dst, convert(x, readType, t.expr))
+	case kindSlice:
+		var n, i = fmt.Sprintf("n%d", depth), fmt.Sprintf("i%d", depth)
+		g.printf("{\nvar %s int\n", n)
+		g.printf("if %s, err = r.Length(%s); err != nil {\n%s\n}\n", n, t.elem.minSize(), fail)
+		g.printf("%s = make(%s, %s)\n", dst, t.expr, n)
+		g.printf("for %s := range %s {\n", i, dst)
+		g.decode(fmt.Sprintf("%s[%s]", dst, i), t.elem, depth+1, fail)
+		g.printf("}\n}\n")
+	case kindMap:
+		var n, i = fmt.Sprintf("n%d", depth), fmt.Sprintf("i%d", depth)
+		var k, v = fmt.Sprintf("k%d", depth), fmt.Sprintf("v%d", depth)
+		g.printf("{\nvar %s int\n", n)
+		g.printf("if %s, err = r.Length(%s); err != nil {\n%s\n}\n", n, addSizes(t.key.minSize(), t.elem.minSize()), fail)
+		g.printf("%s = make(%s, %s)\n", dst, t.expr, n)
+		g.printf("for %s := 0; %s < %s; %s++ {\n", i, i, n, i)
+		g.printf("var %s %s\nvar %s %s\n", k, t.key.expr, v, t.elem.expr)
+		g.decode(k, t.key, depth+1, fail)
+		g.decode(v, t.elem, depth+1, fail)
+		g.printf("%s[%s] = %s\n}\n}\n", dst, k, v)
+	default:
+		g.printf("if err = r.Value(&%s); err != nil {\n%s\n}\n", dst, fail)
+	}
+}
+
+// encodePacked writes the code appending the slice as a packed block
+func (g *generator) encodePacked(src string, t *typeInfo, option string) {
+	switch t.elem.kind {
+	case kindFloat:
+		g.printf("dst = tinyserializer.AppendPackedFloats[%s](dst, %s)\n", t.elem.expr, src)
+	case kindBool:
+		g.printf("dst = tinyserializer.AppendPackedBools[%s](dst, %s)\n", t.elem.expr, src)
+	default:
+		g.printf("dst = tinyserializer.AppendPackedInts[%s](dst, %s, %s)\n", t.elem.expr, src, packing(option))
+	}
+}
+
+// decodePacked writes the code reading a packed block into the slice
+func (g *generator) decodePacked(dst string, t *typeInfo, fail string) {
+	g.usesErr = true
+	var readFunc = "ReadPackedInts"
+	switch t.elem.kind {
+	case kindFloat:
+		readFunc = "ReadPackedFloats"
+	case kindBool:
+		readFunc = "ReadPackedBools"
+	}
+	var sliceType = "[]" + t.elem.expr
+	g.printf("{\nvar x1 %s\n", sliceType)
+	g.printf("if x1, err = tinyserializer.%s[%s](&r); err != nil {\n%s\n}\n", readFunc, t.elem.expr, fail)
+	g.printf("%s = %s\n}\n", dst, convert("x1", sliceType, t.expr))
+}
+
+// readMethod returns the Reader method for the kind, and the type it returns
+func readMethod(kind string) (string, string) {
+	switch kind {
+	case kindString:
+		return "String", "string"
+	case kindBool:
+		return "Bool", "bool"
+	case kindInt:
+		return "Int", "int64"
+	case kindUint:
+		return "Uint", "uint64"
+	case kindFloat:
+		return "Float", "float64"
+	case kindCmplx:
+		return "Complex", "complex128"
+	case kindBytes:
+		return "Bytes", "[]byte"
+	}
+	panic("no read method for kind " + kind)
+}
+
+// convert returns the expression of type from, converted to type to if they differ
+func convert(expr, from, to string) string {
+	if from == to {
+		return expr
+	}
+	return fmt.Sprintf("%s(%s)", to, expr)
+}
@@ -0,0 +1,211 @@
+// Command tinygen generates MarshalTiny and UnmarshalTiny methods for structs,
+// which serialize them without reflection.
+//
+// The generated methods produce exactly the same bytes as the reflective serializer,
+// which prefers them automatically when they are available.
+//
+// Usage:
+//
+//	//go:generate go run github.com/Nigel2392/tinyserializer/cmd/tinygen -type=User,Session
+//
+// Without -type, methods are generated for every struct in the package which has fields with tiny tags.
+// The methods are written to <file>_tiny.go, where file is the file containing the go:generate directive.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tinygen: ")
+
+	var (
+		typeNames = flag.String("type", "", "comma separated list of struct types to generate methods for")
+		output    = flag.String("output", "", "output file name; default <file>_tiny.go")
+		dir       = flag.String("dir", ".", "directory of the package to generate methods for")
+	)
+	flag.Parse()
+
+	if *output == "" {
+		var name = "tiny_gen.go"
+		if file := os.Getenv("GOFILE"); file != "" {
+			name = strings.TrimSuffix(file, ".go") + "_tiny.go"
+		}
+		*output = filepath.Join(*dir, name)
+	}
+
+	var types []string
+	if *typeNames != "" {
+		types = strings.Split(*typeNames, ",")
+	}
+
+	src, err := Generate(*dir, types, filepath.Base(*output))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Generate generates the methods for the given struct types of the package in dir.
+// If no types are given, methods are generated for all structs with tiny tags.
+// The file named output is ignored while parsing the package.
+func Generate(dir string, types []string, output string) ([]byte, error) {
+	var fset = token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && info.Name() != output
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected a single package in %s, found %d", dir, len(pkgs))
+	}
+
+	var g = newGenerator()
+	for _, pkg := range pkgs {
+		g.pkgName = pkg.Name
+		// Sort the files, so the output does not depend on map order
+		var names = make([]string, 0, len(pkg.Files))
+		for name := range pkg.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			g.collect(pkg.Files[name])
+		}
+	}
+
+	if len(types) == 0 {
+		types = g.taggedStructs()
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("no structs with tiny tags found in %s", dir)
+	}
+	return g.generate(types)
+}
+
+// structDecl is a struct type declared in the package
+type structDecl struct {
+	name string
+	typ  *ast.StructType
+}
+
+// collect records all type declarations of the file
+func (g *generator) collect(file *ast.File) {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			var ts = spec.(*ast.TypeSpec)
+			g.typeSpecs[ts.Name.Name] = ts
+			if st, ok := ts.Type.(*ast.StructType); ok {
+				g.structs = append(g.structs, structDecl{name: ts.Name.Name, typ: st})
+			}
+		}
+	}
+}
+
+// taggedStructs returns the names of all structs which have fields with tiny tags
+func (g *generator) taggedStructs() []string {
+	var names []string
+	for _, decl := range g.structs {
+		if len(structFields(decl.typ)) > 0 {
+			names = append(names, decl.name)
+		}
+	}
+	return names
+}
+
+// field is a serialized field of a struct
+type field struct {
+	goName    string
+	name      string
+	typ       ast.Expr
+	omitEmpty bool
+	// Value of the packed option, empty if the field is not packed
+	packed string
+}
+
+// structFields returns the serialized fields of the struct,
+// following the same rules as the reflective serializer.
+func structFields(st *ast.StructType) []field {
+	var fields []field
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		var tag = tinyTag(f.Tag.Value)
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		var names []string
+		for _, name := range f.Names {
+			names = append(names, name.Name)
+		}
+		if len(names) == 0 {
+			// Embedded fields are named after their type
+			names = append(names, embeddedName(f.Type))
+		}
+
+		var options = strings.Split(tag, ",")
+		for _, name := range names {
+			if !ast.IsExported(name) {
+				continue
+			}
+			var fi = field{
+				goName:    name,
+				name:      options[0],
+				typ:       f.Type,
+				omitEmpty: tag == "omitempty",
+			}
+			for _, option := range options[1:] {
+				switch {
+				case option == "omitempty":
+					fi.omitEmpty = true
+				case option == "packed" || strings.HasPrefix(option, "packed="):
+					fi.packed = option
+				}
+			}
+			fields = append(fields, fi)
+		}
+	}
+	return fields
+}
+
+// tinyTag returns the value of the tiny key in the raw struct tag literal
+func tinyTag(literal string) string {
+	unquoted, err := strconv.Unquote(literal)
+	if err != nil {
+		return ""
+	}
+	return reflect.StructTag(unquoted).Get("tiny")
+}
+
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	}
+	return ""
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The checked in test types must match the output of the generator
+func TestGenerateTestTypes(t *testing.T) {
+	const dir = "../../internal/tinytest"
+	expected, err := os.ReadFile(dir + "/types_tiny.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := Generate(dir, []string{"Scalars", "Collections", "Nested", "Child", "Named", "Fallback", "Packed"}, "types_tiny.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, expected) {
+		t.Fatal("generated code differs from types_tiny.go, run go generate in internal/tinytest")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	if _, err := Generate("../../internal/tinytest", []string{"Missing"}, "types_tiny.go"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected type not found error, got %v", err)
+	}
+	if _, err := Generate("../../internal/tinytest", []string{"Level"}, "types_tiny.go"); err == nil || !strings.Contains(err.Error(), "not a struct") {
+		t.Fatalf("expected not a struct error, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Command tinyschema exports the schemas of types, and checks changes to them
+// do not break decoding of existing payloads.
+//
+// Usage:
+//
+//	tinyschema export -type name
+//	tinyschema diff -type name old.json
+//	tinyschema diff old.json new.json
+//
+// export writes the schema of the type as JSON to stdout, to check it in next to the code.
+// diff compares an old schema with the schema of the type, or with a second schema file,
+// and lists the changes which break decoding of old payloads.
+// It exits with status 1 if there are any, so it can be run as part of a test suite.
+//
+// The types must be registered in internal/tinytypes.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/Nigel2392/tinyserializer"
+	"github.com/Nigel2392/tinyserializer/internal/tinytypes"
+)
+
+// errIncompatible is returned by diff when the schemas are incompatible
+var errIncompatible = errors.New("schemas are incompatible")
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tinyschema: ")
+
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		log.Fatal(err)
+	}
+}
+
+// run runs the command with the given arguments
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] != "export" && args[0] != "diff" {
+		return errors.New("expected the export or diff command")
+	}
+	var command = args[0]
+	var flags = flag.NewFlagSet("tinyschema "+command, flag.ContinueOnError)
+	var typeName = flags.String("type", "", "registered type to use the schema of")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var current *tinyserializer.Schema
+	if *typeName != "" {
+		v, ok := tinytypes.Types[*typeName]
+		if !ok {
+			return fmt.Errorf("unknown type %s", *typeName)
+		}
+		current = tinyserializer.SchemaOf(v)
+	}
+
+	if command == "export" {
+		if current == nil {
+			return errors.New("export requires -type")
+		}
+		data, err := json.MarshalIndent(current, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = stdout.Write(append(data, '\n'))
+		return err
+	}
+
+	var files = flags.Args()
+	switch {
+	case current == nil && len(files) != 2:
+		return errors.New("diff requires an old and a new schema file, or -type and an old schema file")
+	case current != nil && len(files) != 1:
+		return errors.New("diff requires an old schema file")
+	}
+	old, err := readSchema(files[0])
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		if current, err = readSchema(files[1]); err != nil {
+			return err
+		}
+	}
+
+	var found = tinyserializer.CheckCompatibility(old, current)
+	for _, incompatibility := range found {
+		fmt.Fprintln(stdout, incompatibility)
+	}
+	if len(found) > 0 {
+		return fmt.Errorf("%w: %d breaking changes", errIncompatible, len(found))
+	}
+	return nil
+}
+
+// readSchema reads a schema file written by export
+func readSchema(file string) (*tinyserializer.Schema, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var schema tinyserializer.Schema
+	if err = json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", file, err)
+	}
+	return &schema, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Nigel2392/tinyserializer"
+)
+
+func TestExportDiff(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"export", "-type", "tinytest.Packed"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"name": "varint"`) || !strings.Contains(out.String(), `"packing": 2`) {
+		t.Fatalf("unexpected schema:\n%s", out.String())
+	}
+	var dir = t.TempDir()
+	var packed = filepath.Join(dir, "packed.json")
+	if err := os.WriteFile(packed, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := run([]string{"diff", "-type", "tinytest.Packed", packed}, &out); err != nil {
+		t.Fatalf("%v:\n%s", err, out.String())
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected no output, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := run([]string{"export", "-type", "tinytest.Named"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var named = filepath.Join(dir, "named.json")
+	if err := os.WriteFile(named, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	var err = run([]string{"diff", packed, named}, &out)
+	if !errors.Is(err, errIncompatible) {
+		t.Fatalf("expected incompatible schemas, got %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "raw: field moved: field moved from position 0 to 2\n") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	var file = filepath.Join(t.TempDir(), "invalid.json")
+	if err := os.WriteFile(file, []byte(`{"types":[{"kind":"slice","elem":2}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := run([]string{"diff", "-type", "tinytest.Named", file}, &out); err == nil || !strings.Contains(err.Error(), "unknown type") {
+		t.Fatalf("expected invalid schema error, got %v", err)
+	}
+	if err := run([]string{"diff", "-type", "tinytest.Named"}, &out); err == nil {
+		t.Fatal("expected error without an old schema")
+	}
+	if err := run([]string{"export"}, &out); err == nil {
+		t.Fatal("expected error without a type")
+	}
+	if err := run([]string{"check"}, &out); err == nil {
+		t.Fatal("expected error for an unknown command")
+	}
+}
+
+// The exported JSON is the JSON format of the serializer
+func TestExportFormat(t *testing.T) {
+	var out bytes.Buffer
+	if err := run([]string{"export", "-type", "tinytest.Named"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := tinyserializer.FromJSON(out.Bytes(), (*tinyserializer.Schema)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema tinyserializer.Schema
+	if err = tinyserializer.NewSerializer().Deserialize(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Root().Name != "tinytest.Named" {
+		t.Fatalf("unexpected schema %+v", schema)
+	}
+}
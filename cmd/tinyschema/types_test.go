@@ -0,0 +1,12 @@
+package main
+
+import (
+	"github.com/Nigel2392/tinyserializer/internal/tinytest"
+	"github.com/Nigel2392/tinyserializer/internal/tinytypes"
+)
+
+// The installed command has no types, the tests use the fixtures of tinytest
+func init() {
+	tinytypes.Register("tinytest.Named", (*tinytest.Named)(nil))
+	tinytypes.Register("tinytest.Packed", (*tinytest.Packed)(nil))
+}
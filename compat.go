@@ -0,0 +1,186 @@
+package tinyserializer
+
+import (
+	"fmt"
+)
+
+// IncompatibilityKind classifies a change which breaks decoding of existing payloads
+type IncompatibilityKind uint8
+
+const (
+	// FieldRemoved means a field of the old schema is no longer in the new schema
+	FieldRemoved IncompatibilityKind = iota + 1
+	// FieldAdded means a field of the new schema is missing from payloads written with the old schema
+	FieldAdded
+	// FieldMoved means a field is at a different position in the new schema
+	FieldMoved
+	// KindChanged means the type of a value changed its kind, or the length of an array changed
+	KindChanged
+	// OptionChanged means the omitempty or packed option of a field changed
+	OptionChanged
+	// SchemaInvalid means one of the schemas refers to types which are not in it
+	SchemaInvalid
+)
+
+func (k IncompatibilityKind) String() string {
+	switch k {
+	case FieldRemoved:
+		return "field removed"
+	case FieldAdded:
+		return "field added"
+	case FieldMoved:
+		return "field moved"
+	case KindChanged:
+		return "kind changed"
+	case OptionChanged:
+		return "option changed"
+	case SchemaInvalid:
+		return "invalid schema"
+	}
+	return fmt.Sprintf("IncompatibilityKind(%d)", uint8(k))
+}
+
+// Incompatibility describes a change between two schemas which breaks decoding of payloads written with the old one
+type Incompatibility struct {
+	// Path of the value, with struct fields separated by dots, [] for elements and [key] for map keys.
+	// The path is empty for the top level value.
+	Path    string
+	Kind    IncompatibilityKind
+	Message string
+}
+
+func (i Incompatibility) String() string {
+	if i.Path == "" {
+		return fmt.Sprintf("%s: %s", i.Kind, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Path, i.Kind, i.Message)
+}
+
+// CheckCompatibility reports the changes from the old to the new schema which break decoding of payloads written with the old schema.
+//
+// Fields are encoded by position, so fields must keep their position, kind and options.
+// Fields may be renamed, but can not be removed, moved or added.
+// Fields with a default, and any field of a struct implementing Defaulter, may be added to the end of structs
+// which are at the end of the payload, the top level struct and structs in the last field of those.
+// Required fields without a default can not be added, old payloads would fail validation.
+// An empty result means old payloads decode into the new type.
+func CheckCompatibility(old, new *Schema) []Incompatibility {
+	var c = compatibility{old: old, new: new, seen: make(map[comparedTypes]bool)}
+	for _, schema := range []*Schema{old, new} {
+		if err := schema.Validate(); err != nil {
+			return []Incompatibility{{Kind: SchemaInvalid, Message: err.Error()}}
+		}
+	}
+	c.compare("", 0, 0, true)
+	return c.found
+}
+
+// compatibility compares the types of two schemas
+type compatibility struct {
+	old, new *Schema
+	found    []Incompatibility
+	// Pairs of types which have been compared, to stop at recursive types
+	seen map[comparedTypes]bool
+}
+
+// comparedTypes is a pair of compared types, and whether they are at the end of the payload
+type comparedTypes struct {
+	old, new int32
+	atEnd    bool
+}
+
+func (c *compatibility) report(path string, kind IncompatibilityKind, format string, args ...interface{}) {
+	c.found = append(c.found, Incompatibility{Path: path, Kind: kind, Message: fmt.Sprintf(format, args...)})
+}
+
+// compare compares the old type at index oi with the new type at index ni.
+// atEnd is set if nothing follows the value in old payloads, so fields may be missing from it.
+func (c *compatibility) compare(path string, oi, ni int32, atEnd bool) {
+	var key = comparedTypes{oi, ni, atEnd}
+	if c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+
+	var o, n = c.old.Type(oi), c.new.Type(ni)
+	if o.Kind != n.Kind {
+		c.report(path, KindChanged, "%s (%s) changed to %s (%s)", o.Kind, o.Name, n.Kind, n.Name)
+		return
+	}
+	switch o.Kind {
+	case "array":
+		if o.Len != n.Len {
+			c.report(path, KindChanged, "array length changed from %d to %d", o.Len, n.Len)
+			return
+		}
+		c.compare(path+"[]", o.Elem, n.Elem, false)
+	case "slice":
+		c.compare(path+"[]", o.Elem, n.Elem, false)
+	case "map":
+		c.compare(path+"[key]", o.Key, n.Key, false)
+		c.compare(path+"[]", o.Elem, n.Elem, false)
+	case "struct":
+		c.compareFields(path, o.Fields, n.Fields, atEnd)
+	}
+}
+
+// compareFields compares the fields of a struct by position
+func (c *compatibility) compareFields(path string, old, new []SchemaField, atEnd bool) {
+	var position = func(fields []SchemaField, name string) int {
+		for i := range fields {
+			if fields[i].Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+	var fieldPath = func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "." + name
+	}
+
+	for i := range old {
+		var o = &old[i]
+		var last = atEnd && i == len(old)-1
+		var moved = position(new, o.Name)
+		switch {
+		case moved == -1 && i >= len(new):
+			c.report(fieldPath(o.Name), FieldRemoved, "field %d is no longer encoded", i)
+		case moved == -1 && position(old, new[i].Name) == -1 && c.old.Type(o.Type).Kind == c.new.Type(new[i].Type).Kind:
+			// The field at this position was renamed
+			c.compareField(fieldPath(new[i].Name), o, &new[i], last)
+		case moved == -1:
+			c.report(fieldPath(o.Name), FieldRemoved, "field %d is no longer encoded, %s took its place", i, new[i].Name)
+		case moved != i:
+			c.report(fieldPath(o.Name), FieldMoved, "field moved from position %d to %d", i, moved)
+		default:
+			c.compareField(fieldPath(o.Name), o, &new[i], last)
+		}
+	}
+	// Fields added to the end are missing from old payloads, which can end before them
+	var optional = atEnd
+	for i := len(old); i < len(new); i++ {
+		var n = &new[i]
+		optional = optional && (n.HasDefault || n.Required)
+		switch {
+		case position(old, n.Name) != -1:
+		case !optional:
+			c.report(fieldPath(n.Name), FieldAdded, "field %d is missing from old payloads", i)
+		case !n.HasDefault:
+			c.report(fieldPath(n.Name), FieldAdded, "required field %d is missing from old payloads, which fail validation", i)
+		}
+	}
+}
+
+// compareField compares the options and types of fields at the same position
+func (c *compatibility) compareField(path string, o, n *SchemaField, atEnd bool) {
+	if o.OmitEmpty != n.OmitEmpty {
+		c.report(path, OptionChanged, "omitempty changed from %v to %v", o.OmitEmpty, n.OmitEmpty)
+	}
+	if o.Packing != n.Packing {
+		c.report(path, OptionChanged, "packing changed from %s to %s", o.Packing, n.Packing)
+	}
+	c.compare(path, o.Type, n.Type, atEnd)
+}
@@ -0,0 +1,197 @@
+package tinyserializer
+
+import (
+	"reflect"
+	"testing"
+)
+
+type compatItem struct {
+	Name  string `tiny:"name"`
+	Count int32  `tiny:"count"`
+}
+
+type compatV1 struct {
+	ID    int64             `tiny:"id"`
+	Name  string            `tiny:"name"`
+	Items []compatItem      `tiny:"items"`
+	Tags  map[string][2]int `tiny:"tags"`
+	IDs   []int64           `tiny:"ids,packed"`
+	Note  string            `tiny:"note"`
+}
+
+func checkCompatibility(old, new interface{}) []Incompatibility {
+	return CheckCompatibility(SchemaOf(old), SchemaOf(new))
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	if found := checkCompatibility(compatV1{}, compatV1{}); len(found) != 0 {
+		t.Fatalf("expected no incompatibilities, got %v", found)
+	}
+
+	// Renaming Go types, fields and tags keeps the encoding
+	type renamedItem struct {
+		Label string `tiny:"label"`
+		Count int32  `tiny:"count"`
+	}
+	type renamed struct {
+		Key   int64             `tiny:"key"`
+		Name  string            `tiny:"name"`
+		Items []renamedItem     `tiny:"items"`
+		Tags  map[string][2]int `tiny:"tags"`
+		IDs   []int64           `tiny:"ids,packed"`
+		Note  string            `tiny:"note"`
+	}
+	if found := checkCompatibility(compatV1{}, renamed{}); len(found) != 0 {
+		t.Fatalf("expected no incompatibilities, got %v", found)
+	}
+
+	type changedItem struct {
+		Name  string `tiny:"name"`
+		Count int64  `tiny:"count"`
+	}
+	type changed struct {
+		ID    int64             `tiny:"id"`
+		Items []changedItem     `tiny:"items"`
+		Name  string            `tiny:"name"`
+		Tags  map[int][3]int    `tiny:"tags"`
+		IDs   []int64           `tiny:"ids,packed=delta"`
+		Extra bool              `tiny:"extra"`
+		More  map[string]string `tiny:"more,omitempty"`
+	}
+	var found = checkCompatibility(compatV1{}, changed{})
+	var expected = []Incompatibility{
+		{Path: "name", Kind: FieldMoved, Message: "field moved from position 1 to 2"},
+		{Path: "items", Kind: FieldMoved, Message: "field moved from position 2 to 1"},
+		{Path: "tags[key]", Kind: KindChanged, Message: "string (string) changed to int (int)"},
+		{Path: "tags[]", Kind: KindChanged, Message: "array length changed from 2 to 3"},
+		{Path: "ids", Kind: OptionChanged, Message: "packing changed from raw to delta"},
+		{Path: "note", Kind: FieldRemoved, Message: "field 5 is no longer encoded, extra took its place"},
+		{Path: "more", Kind: FieldAdded, Message: "field 6 is missing from old payloads"},
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("expected\n%v\ngot\n%v", expected, found)
+	}
+	if found[2].String() != "tags[key]: kind changed: string (string) changed to int (int)" {
+		t.Fatalf("unexpected string %s", found[2])
+	}
+
+	// Nested changes are reported with their path
+	type nested struct {
+		ID    int64         `tiny:"id"`
+		Name  string        `tiny:"name"`
+		Items []changedItem `tiny:"items"`
+	}
+	found = checkCompatibility(compatV1{}, nested{})
+	expected = []Incompatibility{
+		{Path: "items[].count", Kind: KindChanged, Message: "int32 (int32) changed to int64 (int64)"},
+		{Path: "tags", Kind: FieldRemoved, Message: "field 3 is no longer encoded"},
+		{Path: "ids", Kind: FieldRemoved, Message: "field 4 is no longer encoded"},
+		{Path: "note", Kind: FieldRemoved, Message: "field 5 is no longer encoded"},
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("expected\n%v\ngot\n%v", expected, found)
+	}
+
+	found = checkCompatibility(compatV1{}, []compatV1{})
+	if len(found) != 1 || found[0].Kind != KindChanged || found[0].Path != "" {
+		t.Fatalf("expected a top level kind change, got %v", found)
+	}
+}
+
+func TestCheckCompatibilityDefaults(t *testing.T) {
+	type item struct {
+		Name  string `tiny:"name"`
+		Count int32  `tiny:"count"`
+		Extra int32  `tiny:"extra,default=1"`
+	}
+	type added struct {
+		ID      int64             `tiny:"id"`
+		Name    string            `tiny:"name"`
+		Items   []item            `tiny:"items"`
+		Tags    map[string][2]int `tiny:"tags"`
+		IDs     []int64           `tiny:"ids,packed"`
+		Note    string            `tiny:"note"`
+		Retries int32             `tiny:"retries,default=3"`
+		Labels  []string          `tiny:"labels"`
+	}
+	var found = checkCompatibility(compatV1{}, added{})
+	var expected = []Incompatibility{
+		// Old payloads do not end after the items
+		{Path: "items[].extra", Kind: FieldAdded, Message: "field 2 is missing from old payloads"},
+		// Fields after a field without a default can not be missing
+		{Path: "labels", Kind: FieldAdded, Message: "field 7 is missing from old payloads"},
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("expected\n%v\ngot\n%v", expected, found)
+	}
+
+	type nested struct {
+		ID   int64 `tiny:"id"`
+		Item item  `tiny:"item"`
+	}
+	type nestedV1 struct {
+		ID   int64      `tiny:"id"`
+		Item compatItem `tiny:"item"`
+	}
+	if found = checkCompatibility(nestedV1{}, nested{}); len(found) != 0 {
+		t.Fatalf("expected a default in the last field to be compatible, got %v", found)
+	}
+}
+
+type compatDefaulted struct {
+	ID    int64  `tiny:"id"`
+	Name  string `tiny:"name"`
+	Added int32  `tiny:"added"`
+}
+
+func (c *compatDefaulted) SetDefaults() {
+	c.Added = 1
+}
+
+func TestCheckCompatibilityDefaulter(t *testing.T) {
+	type v1 struct {
+		ID   int64  `tiny:"id"`
+		Name string `tiny:"name"`
+	}
+	// Any field of a Defaulter can be missing from old payloads
+	if found := checkCompatibility(v1{}, compatDefaulted{}); len(found) != 0 {
+		t.Fatalf("expected a field added to a Defaulter to be compatible, got %v", found)
+	}
+	data, err := NewSerializer().Serialize(&v1{ID: 1, Name: "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded compatDefaulted
+	if err = NewSerializer().Deserialize(data, &decoded); err != nil || decoded.Added != 1 {
+		t.Fatalf("expected the old payload to decode with the default, got %+v (%v)", decoded, err)
+	}
+
+	// Required fields can be missing from the payload, but then fail validation
+	type required struct {
+		ID      int64  `tiny:"id"`
+		Name    string `tiny:"name"`
+		Owner   string `tiny:"owner,required"`
+		Retries int32  `tiny:"retries,default=3"`
+	}
+	var found = checkCompatibility(v1{}, required{})
+	var expected = []Incompatibility{
+		{Path: "owner", Kind: FieldAdded, Message: "required field 2 is missing from old payloads, which fail validation"},
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Fatalf("expected\n%v\ngot\n%v", expected, found)
+	}
+	var schema = SchemaOf(required{}).Root()
+	if !schema.Fields[2].Required || schema.Fields[2].HasDefault || !schema.Fields[3].HasDefault || !SchemaOf(compatDefaulted{}).Root().Fields[0].HasDefault {
+		t.Fatalf("unexpected fields %+v", schema.Fields)
+	}
+}
+
+func TestCheckCompatibilityRecursive(t *testing.T) {
+	if found := checkCompatibility(schemaNode{}, schemaNode{}); len(found) != 0 {
+		t.Fatalf("expected no incompatibilities, got %v", found)
+	}
+	var invalid = &Schema{Types: []SchemaType{{Kind: "slice", Elem: 3}}}
+	if found := CheckCompatibility(invalid, SchemaOf(schemaNode{})); len(found) != 1 || found[0].Kind != SchemaInvalid {
+		t.Fatalf("expected invalid schema, got %v", found)
+	}
+}
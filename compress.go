@@ -0,0 +1,318 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// DefaultCompressThreshold is the default minimum size of a payload before it is compressed.
+// Smaller payloads are stored as-is, since the gzip header alone is about 18 bytes.
+const DefaultCompressThreshold = 64
+
+// CompressionStats holds statistics about the payloads a serializer has compressed
+type CompressionStats struct {
+	// Payloads is the number of payloads considered for compression
+	Payloads int64
+	// Compressed is the number of payloads which were stored compressed
+	Compressed int64
+	// BytesIn is the uncompressed size of the payloads which were stored compressed
+	BytesIn int64
+	// BytesOut is the compressed size of the payloads which were stored compressed
+	BytesOut int64
+}
+
+// Skipped returns the number of payloads which were stored uncompressed
+func (c CompressionStats) Skipped() int64 {
+	return c.Payloads - c.Compressed
+}
+
+// Ratio returns the achieved compression ratio (compressed size / uncompressed size)
+// of the payloads which were stored compressed, or 1 if none were.
+func (c CompressionStats) Ratio() float64 {
+	if c.BytesIn == 0 {
+		return 1
+	}
+	return float64(c.BytesOut) / float64(c.BytesIn)
+}
+
+// SetCompressThreshold sets the minimum payload size for compression,
+// payloads smaller than the threshold are stored uncompressed.
+func (s *Serializer) SetCompressThreshold(threshold int) *Serializer {
+	s.compressThreshold = threshold
+	return s
+}
+
+// SetCompressIfSmaller sets whether compressed data should only be kept
+// if it is smaller than the uncompressed data.
+func (s *Serializer) SetCompressIfSmaller(ifSmaller bool) *Serializer {
+	s.compressIfSmaller = ifSmaller
+	return s
+}
+
+// CompressionStats returns the compression statistics of the serializer
+func (s *Serializer) CompressionStats() CompressionStats {
+	return s.stats
+}
+
+// ResetCompressionStats resets the compression statistics of the serializer
+func (s *Serializer) ResetCompressionStats() {
+	s.stats = CompressionStats{}
+}
+
+// appendCompressed compresses the data according to the serializer's policy,
+// and appends it to dst behind the header, which tells the reader whether it was compressed.
+func (s *Serializer) appendCompressed(dst, data []byte, h header) ([]byte, error) {
+	var start = len(dst)
+	var out = dst
+
+	s.stats.Payloads++
+	if len(data) >= s.compressThreshold {
+		h.flags |= flagCompressed
+		if s.dictionary != nil {
+			h.flags |= flagDictionary
+			h.dictID = s.dictionary.id
+		}
+		out = h.appendTo(out)
+
+		var err error
+		var headerLen = len(out)
+		if s.dictionary != nil {
+			out, err = s.dictionary.appendCompress(out, data)
+		} else {
+			out, err = AppendCompress(out, data)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var compressedSize = len(out) - headerLen
+		if !s.compressIfSmaller || compressedSize < len(data) {
+			s.stats.Compressed++
+			s.stats.BytesIn += int64(len(data))
+			s.stats.BytesOut += int64(compressedSize)
+			return out, nil
+		}
+
+		// Compression did not pay off, store the data as-is
+		h.flags &^= flagCompressed | flagDictionary
+		out = out[:start]
+	}
+
+	out = h.appendTo(out)
+	return append(out, data...), nil
+}
+
+// decompressPayload reads the header of the data and decompresses the payload if needed.
+// Payloads written before headers were introduced are plain gzip streams, these are still accepted.
+//
+// The decompressed data is stored in a buffer owned by the serializer,
+// which is reused by the next call.
+func (s *Serializer) decompressPayload(data []byte) ([]byte, error) {
+	_, payload, err := s.readPayload(data)
+	return payload, err
+}
+
+// readPayload is like decompressPayload, but also returns the header of the data
+func (s *Serializer) readPayload(data []byte) (header, []byte, error) {
+	if isGzip(data) {
+		payload, err := s.decompressGzip(data)
+		return header{}, payload, err
+	}
+
+	h, payload, err := parseHeader(data)
+	if err != nil {
+		return h, nil, err
+	}
+	switch {
+	case h.flags&flagCompressed == 0:
+		return h, payload, nil
+	case h.flags&flagDictionary != 0:
+		dict, err := s.lookupDictionary(h.dictID)
+		if err != nil {
+			return h, nil, err
+		}
+		s.decompressBuffer, err = dict.appendDecompress(s.decompressBuffer[:0], payload, 0)
+		if err != nil {
+			return h, nil, err
+		}
+		return h, s.decompressBuffer, nil
+	default:
+		payload, err = s.decompressGzip(payload)
+		return h, payload, err
+	}
+}
+
+func (s *Serializer) decompressGzip(data []byte) ([]byte, error) {
+	var err error
+	s.decompressBuffer, err = AppendDecompress(s.decompressBuffer[:0], data, gzipSizeHint(data))
+	if err != nil {
+		return nil, err
+	}
+	return s.decompressBuffer, nil
+}
+
+// isGzip reports whether data starts with the gzip magic number
+func isGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// gzipSizeHint returns the uncompressed size recorded in the trailer of a gzip stream.
+// The trailer stores the size modulo 2^32 and can not be trusted, so it is only used as a hint.
+func gzipSizeHint(data []byte) int {
+	if len(data) < 18 {
+		return 0
+	}
+	var size = int(binary.LittleEndian.Uint32(data[len(data)-4:]))
+	// Deflate can not compress better than about 1032:1
+	if size > len(data)*1032 {
+		return 0
+	}
+	return size
+}
+
+// sliceWriter is an io.Writer which appends to a byte slice
+type sliceWriter struct {
+	b []byte
+}
+
+func (w *sliceWriter) Write(p []byte) (int, error) {
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+type gzipWriter struct {
+	dst sliceWriter
+	w   *gzip.Writer
+}
+
+type gzipReader struct {
+	src bytes.Reader
+	r   gzip.Reader
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		var w = new(gzipWriter)
+		w.w = gzip.NewWriter(&w.dst)
+		return w
+	},
+}
+
+var gzipReaderPool = sync.Pool{
+	New: func() interface{} {
+		return new(gzipReader)
+	},
+}
+
+// Compress compresses the data with gzip
+func Compress(data []byte) ([]byte, error) {
+	return AppendCompress(nil, data)
+}
+
+// AppendCompress compresses the data with gzip and appends it to dst.
+// Writers are pooled, so repeated calls do not allocate a new compressor.
+func AppendCompress(dst, data []byte) ([]byte, error) {
+	var w = gzipWriterPool.Get().(*gzipWriter)
+	defer gzipWriterPool.Put(w)
+
+	w.dst.b = dst
+	w.w.Reset(&w.dst)
+
+	// Write the data to the writer
+	if _, err := w.w.Write(data); err != nil {
+		w.dst.b = nil
+		return nil, err
+	}
+
+	// Close the writer to flush the gzip trailer
+	if err := w.w.Close(); err != nil {
+		w.dst.b = nil
+		return nil, err
+	}
+
+	dst = w.dst.b
+	w.dst.b = nil
+	return dst, nil
+}
+
+// Decompress decompresses gzip compressed data
+func Decompress(data []byte) ([]byte, error) {
+	return AppendDecompress(nil, data, gzipSizeHint(data))
+}
+
+// AppendDecompress decompresses gzip compressed data and appends it to dst.
+// sizeHint is the expected size of the decompressed data, dst is grown
+// to fit it upfront to avoid reallocations while reading.
+// Readers are pooled, so repeated calls do not allocate a new decompressor.
+func AppendDecompress(dst, data []byte, sizeHint int) ([]byte, error) {
+	return appendDecompressLimit(dst, data, sizeHint, -1)
+}
+
+// errDecompressLimit is returned when decompressed data exceeds its limit
+var errDecompressLimit = errors.New("decompressed data exceeds limit")
+
+// appendDecompressLimit is like AppendDecompress, but stops with errDecompressLimit
+// once more than limit bytes have been decompressed. A negative limit disables it.
+func appendDecompressLimit(dst, data []byte, sizeHint, limit int) ([]byte, error) {
+	var r = gzipReaderPool.Get().(*gzipReader)
+	defer func() {
+		// Do not keep the compressed data alive through the pool
+		r.src.Reset(nil)
+		gzipReaderPool.Put(r)
+	}()
+
+	r.src.Reset(data)
+	if err := r.r.Reset(&r.src); err != nil {
+		return nil, err
+	}
+
+	var src io.Reader = &r.r
+	if limit >= 0 {
+		src = &io.LimitedReader{R: &r.r, N: int64(limit) + 1}
+	}
+	var start = len(dst)
+	dst, err := readAll(dst, src, sizeHint)
+	if err != nil {
+		return nil, err
+	}
+	if limit >= 0 && len(dst)-start > limit {
+		return nil, errDecompressLimit
+	}
+
+	// Close the reader
+	if err := r.r.Close(); err != nil {
+		return nil, err
+	}
+
+	return dst, nil
+}
+
+// readAll reads r until EOF and appends the data to dst.
+// dst is grown to fit sizeHint bytes upfront.
+func readAll(dst []byte, r io.Reader, sizeHint int) ([]byte, error) {
+	// Reserve one extra byte, so that reading the end of the stream does not grow dst
+	if sizeHint > 0 && cap(dst)-len(dst) <= sizeHint {
+		var grown = make([]byte, len(dst), len(dst)+sizeHint+1)
+		copy(grown, dst)
+		dst = grown
+	}
+
+	// Read the data from the reader into the spare capacity of dst
+	for {
+		if len(dst) == cap(dst) {
+			dst = append(dst, 0)[:len(dst)]
+		}
+		n, err := r.Read(dst[len(dst):cap(dst)])
+		dst = dst[:len(dst)+n]
+		if err == io.EOF {
+			return dst, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressThreshold(t *testing.T) {
+	var s = NewSerializer().SetCompress(true)
+
+	// Small payloads are stored uncompressed, behind the header
+	var small = A{Name: "John Doe", Siblings: 2}
+	serialized, err := s.Serialize(&small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasHeader(serialized) || serialized[2]&flagCompressed != 0 {
+		t.Fatalf("expected uncompressed payload with header, got %v", serialized[:headerSize])
+	}
+
+	var deserialized A
+	if err = s.Deserialize(serialized, &deserialized); err != nil {
+		t.Fatal(err)
+	}
+	if deserialized.Name != small.Name || deserialized.Siblings != small.Siblings {
+		t.Fatalf("expected %+v, got %+v", small, deserialized)
+	}
+
+	// Large payloads are compressed
+	serialized, err = s.Serialize(&testStruct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serialized[2]&flagCompressed == 0 {
+		t.Fatal("expected compressed payload")
+	}
+
+	var stats = s.CompressionStats()
+	if stats.Payloads != 2 || stats.Compressed != 1 || stats.Skipped() != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.Ratio() <= 0 || stats.Ratio() >= 1 {
+		t.Fatalf("unexpected compression ratio: %f", stats.Ratio())
+	}
+
+	s.ResetCompressionStats()
+	if s.CompressionStats() != (CompressionStats{}) {
+		t.Fatal("expected stats to be reset")
+	}
+}
+
+func TestCompressIfSmaller(t *testing.T) {
+	var small = A{Name: "x"}
+
+	// Without the policy, small payloads above the threshold grow
+	var s = NewSerializer().SetCompress(true).SetCompressThreshold(0).SetCompressIfSmaller(false)
+	serialized, err := s.Serialize(&small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serialized[2]&flagCompressed == 0 {
+		t.Fatal("expected compressed payload")
+	}
+	if s.CompressionStats().Ratio() <= 1 {
+		t.Fatalf("expected payload to grow, ratio: %f", s.CompressionStats().Ratio())
+	}
+
+	// With the policy, the uncompressed payload is kept
+	s = NewSerializer().SetCompress(true).SetCompressThreshold(0).SetCompressIfSmaller(true)
+	serialized, err = s.Serialize(&small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serialized[2]&flagCompressed != 0 {
+		t.Fatal("expected uncompressed payload")
+	}
+}
+
+func TestDecompressLegacyPayload(t *testing.T) {
+	var s = NewSerializer()
+	serialized, err := s.Serialize(&AllStruct{StringField: "legacy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Payloads used to be plain gzip streams without a header
+	compressed, err := Compress(serialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deserialized AllStruct
+	if err = NewSerializer().SetCompress(true).Deserialize(compressed, &deserialized); err != nil {
+		t.Fatal(err)
+	}
+	if deserialized.StringField != "legacy" {
+		t.Fatalf("expected %q, got %q", "legacy", deserialized.StringField)
+	}
+
+	var invalid = bytes.Repeat([]byte{0xff}, 8)
+	if err = NewSerializer().SetCompress(true).Deserialize(invalid, &deserialized); err != ErrInvalidHeader {
+		t.Fatalf("expected %v, got %v", ErrInvalidHeader, err)
+	}
+}
+
+func TestAppendDecompress(t *testing.T) {
+	var data = bytes.Repeat([]byte("tinyserializer"), 100)
+	compressed, err := AppendCompress([]byte("prefix"), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(compressed, []byte("prefix")) {
+		t.Fatal("expected compressed data to be appended to dst")
+	}
+	compressed = compressed[len("prefix"):]
+
+	if hint := gzipSizeHint(compressed); hint != len(data) {
+		t.Fatalf("expected size hint %d, got %d", len(data), hint)
+	}
+
+	// Decompressing into a buffer large enough does not reallocate it
+	var buf = make([]byte, 0, len(data)+1)
+	decompressed, err := AppendDecompress(buf, compressed, len(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("decompressed data is not equal to the original")
+	}
+	if &decompressed[0] != &buf[:1][0] {
+		t.Fatal("expected data to be decompressed into the given buffer")
+	}
+
+	// A wrong hint only affects performance
+	decompressed, err = AppendDecompress(nil, compressed, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("decompressed data is not equal to the original")
+	}
+}
@@ -0,0 +1,452 @@
+package tinyserializer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// SetZeroCopy sets whether deserialized strings and byte slices may alias the input data.
+//
+// In zero-copy mode, []byte fields point into the data passed to Deserialize,
+// and strings share its memory instead of being copied.
+// This greatly reduces garbage when decoding large batches, but the caller must
+// guarantee that the data is not modified for as long as the decoded values are in use.
+// Modifying the data afterwards changes the contents of the decoded strings,
+// which breaks the immutability Go assumes for them.
+//
+// Compressed payloads are decompressed into a new buffer which the decoded values keep alive.
+func (s *Serializer) SetZeroCopy(zeroCopy bool) *Serializer {
+	s.zeroCopy = zeroCopy
+	return s
+}
+
+// deserialize deserializes the data into out, which must be a pointer
+func (s *Serializer) deserialize(data []byte, out interface{}) error {
+	// Get the value of the data
+	value := reflect.ValueOf(out)
+
+	// Check if the data is a pointer
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("data is not a pointer %s", value.Kind())
+	}
+
+	s.data, s.pos = data, 0
+	err := s.decodeValue(value.Elem())
+	// Do not keep the data alive through the serializer
+	s.data = nil
+	return err
+}
+
+// decodeValue decodes the value at the current position into value
+func (s *Serializer) decodeValue(value reflect.Value) error {
+	switch value.Kind() {
+	case reflect.Struct:
+		return s.decodeStruct(value)
+	case reflect.Ptr:
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return s.decodeValue(value.Elem())
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return s.decodeBytes(value)
+		}
+		if s.packing != PackNone && packable(value.Type()) {
+			return s.decodePacked(value)
+		}
+		return s.decodeSlice(value)
+	case reflect.Array:
+		return s.decodeArray(value)
+	case reflect.Map:
+		return s.decodeMap(value)
+	default:
+		return s.decodeScalar(value)
+	}
+}
+
+// decodeStruct decodes all serialized fields of the struct
+func (s *Serializer) decodeStruct(value reflect.Value) error {
+	var info = getStructInfo(value.Type())
+	if info.err != nil {
+		return info.err
+	}
+	if s.useUnmarshaler(info) {
+		return s.decodeUnmarshaler(value)
+	}
+	for i := range info.fields {
+		if s.pos == len(s.data) && i >= info.optionalFrom && s.elements == 0 {
+			setDefaults(value, info, i, nil)
+			break
+		}
+		var fi = &info.fields[i]
+		var field = value.Field(fi.index)
+		if fi.omitEmpty {
+			present, err := s.readPresence()
+			if err != nil {
+				return WrapFieldError(fi.name, err)
+			}
+			if !present {
+				field.Set(reflect.Zero(fi.typ))
+				continue
+			}
+		}
+		if err := s.decodeField(field, fi); err != nil {
+			if verr, ok := err.(*ValidationError); ok {
+				return verr.in(fi.name)
+			}
+			return WrapFieldError(fi.name, err)
+		}
+	}
+	if s.inspecting {
+		return nil
+	}
+	return decodedStruct(value, info)
+}
+
+// decodeField decodes the value of a struct field
+func (s *Serializer) decodeField(field reflect.Value, fi *fieldInfo) error {
+	if fi.packing != PackNone {
+		return s.decodePacked(field)
+	}
+	return s.decodeValue(field)
+}
+
+func (s *Serializer) decodeSlice(value reflect.Value) error {
+	// Get the length of the slice
+	length, err := s.readLength()
+	if err == nil {
+		err = s.checkLength(length, encodedSize(value.Type().Elem()))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read slice length: %w", err)
+	}
+
+	// Create a new slice
+	value.Set(reflect.MakeSlice(value.Type(), length, length))
+	s.elements++
+	defer s.endElements()
+	for i := 0; i < length; i++ {
+		if err = s.decodeValue(value.Index(i)); err != nil {
+			if verr, ok := err.(*ValidationError); ok {
+				return verr.in(fmt.Sprintf("[%d]", i))
+			}
+			return fmt.Errorf("failed to deserialize slice element: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Serializer) decodeArray(value reflect.Value) error {
+	// Get the length of the array
+	length, err := s.readLength()
+	if err != nil {
+		return fmt.Errorf("failed to read array length: %w", err)
+	}
+	if length != value.Len() {
+		return fmt.Errorf("array length mismatch: expected %d, got %d", value.Len(), length)
+	}
+	s.elements++
+	defer s.endElements()
+	for i := 0; i < length; i++ {
+		if err = s.decodeValue(value.Index(i)); err != nil {
+			if verr, ok := err.(*ValidationError); ok {
+				return verr.in(fmt.Sprintf("[%d]", i))
+			}
+			return fmt.Errorf("failed to deserialize array element: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Serializer) decodeMap(value reflect.Value) error {
+	// Get the length of the map
+	length, err := s.readLength()
+	if err == nil {
+		err = s.checkLength(length, encodedSize(value.Type().Key())+encodedSize(value.Type().Elem()))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read map length: %w", err)
+	}
+
+	// Create a new map, keys and values are decoded into reusable values
+	var mapType = value.Type()
+	var m = reflect.MakeMapWithSize(mapType, length)
+	var key = reflect.New(mapType.Key()).Elem()
+	var elem = reflect.New(mapType.Elem()).Elem()
+	var zeroKey, zeroElem = reflect.Zero(mapType.Key()), reflect.Zero(mapType.Elem())
+	s.elements++
+	defer s.endElements()
+	for i := 0; i < length; i++ {
+		// Reset the values, so pointers, slices and maps are not shared between entries
+		key.Set(zeroKey)
+		elem.Set(zeroElem)
+		if err = s.decodeValue(key); err != nil {
+			if verr, ok := err.(*ValidationError); ok {
+				return verr.in("[key]")
+			}
+			return fmt.Errorf("failed to deserialize map key: %w", err)
+		}
+		if err = s.decodeValue(elem); err != nil {
+			if verr, ok := err.(*ValidationError); ok {
+				return verr.in(fmt.Sprintf("[%v]", key))
+			}
+			return fmt.Errorf("failed to deserialize map value: %w", err)
+		}
+		m.SetMapIndex(key, elem)
+	}
+	value.Set(m)
+	return nil
+}
+
+// endElements ends decoding the elements of a slice, array or map
+func (s *Serializer) endElements() {
+	s.elements--
+}
+
+// decodeBytes decodes a byte slice, which is stored as a single run of bytes
+func (s *Serializer) decodeBytes(value reflect.Value) error {
+	length, err := s.readLength()
+	if err != nil {
+		return fmt.Errorf("failed to read slice length: %w", err)
+	}
+	data, err := s.read(length)
+	if err != nil {
+		return fmt.Errorf("failed to read slice data: %w", err)
+	}
+	if !s.zeroCopy {
+		data = append(make([]byte, 0, len(data)), data...)
+	}
+	value.SetBytes(data)
+	return nil
+}
+
+func (s *Serializer) decodeScalar(value reflect.Value) error {
+	data, err := s.readScalar()
+	if err != nil {
+		return err
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		if s.zeroCopy {
+			value.SetString(unsafeString(data))
+		} else {
+			value.SetString(string(data))
+		}
+	case reflect.Bool:
+		v, err := scalarBool(data)
+		if err != nil {
+			return err
+		}
+		value.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := scalarInt(data)
+		if err != nil {
+			return err
+		}
+		value.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v, err := scalarUint(data)
+		if err != nil {
+			return err
+		}
+		value.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := scalarFloat(data)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(v)
+	case reflect.Complex64, reflect.Complex128:
+		v, err := scalarComplex(data)
+		if err != nil {
+			return err
+		}
+		value.SetComplex(v)
+	default:
+		return fmt.Errorf("cannot deserialize value of kind %s", value.Kind())
+	}
+	return nil
+}
+
+func scalarBool(data []byte) (bool, error) {
+	if len(data) != 1 {
+		return false, fmt.Errorf("invalid size %d for bool", len(data))
+	}
+	return data[0] == 1, nil
+}
+
+func scalarInt(data []byte) (int64, error) {
+	if len(data) > 8 {
+		return 0, fmt.Errorf("invalid size %d for int", len(data))
+	}
+	return signExtend(decodeUint(data), len(data)), nil
+}
+
+func scalarUint(data []byte) (uint64, error) {
+	if len(data) > 8 {
+		return 0, fmt.Errorf("invalid size %d for uint", len(data))
+	}
+	return decodeUint(data), nil
+}
+
+func scalarFloat(data []byte) (float64, error) {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), nil
+	case 8:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	}
+	return 0, fmt.Errorf("invalid size %d for float", len(data))
+}
+
+func scalarComplex(data []byte) (complex128, error) {
+	switch len(data) {
+	case 8:
+		return complex(
+			float64(math.Float32frombits(binary.LittleEndian.Uint32(data))),
+			float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4:]))),
+		), nil
+	case 16:
+		return complex(
+			math.Float64frombits(binary.LittleEndian.Uint64(data)),
+			math.Float64frombits(binary.LittleEndian.Uint64(data[8:])),
+		), nil
+	}
+	return 0, fmt.Errorf("invalid size %d for complex", len(data))
+}
+
+// reader reads encoded values from a byte slice
+type reader struct {
+	data []byte
+	pos  int
+}
+
+// readLength reads a 4 byte slice or map length
+func (s *reader) readLength() (int, error) {
+	if len(s.data)-s.pos < 4 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	var length = binary.LittleEndian.Uint32(s.data[s.pos:])
+	s.pos += 4
+	return int(length), nil
+}
+
+// readScalar reads the size of a scalar and returns its data, without copying it
+func (s *reader) readScalar() ([]byte, error) {
+	// Get the field size
+	if len(s.data)-s.pos < 2 {
+		return nil, fmt.Errorf("failed to read field size: %w", io.ErrUnexpectedEOF)
+	}
+	var size = int(binary.LittleEndian.Uint16(s.data[s.pos:]))
+	s.pos += 2
+
+	// Read field data for the given size
+	data, err := s.read(size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read field data: %w", err)
+	}
+	return data, nil
+}
+
+// readPresence reads the byte before an omitempty field, which reports whether the field follows it
+func (s *reader) readPresence() (bool, error) {
+	data, err := s.read(1)
+	if err != nil {
+		return false, fmt.Errorf("failed to read presence: %w", err)
+	}
+	switch data[0] {
+	case 0:
+		return false, nil
+	case 1:
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid presence byte %#x", data[0])
+}
+
+// checkLength rejects lengths which can not fit the remaining data before allocating for them,
+// given the smallest encoded size of an element.
+// Elements without a size, such as structs without serialized fields, are limited to maxEmptyElements.
+func (s *reader) checkLength(length, elemSize int) error {
+	if elemSize == 0 {
+		if length > maxEmptyElements {
+			return fmt.Errorf("%d elements without data exceed the limit of %d", length, maxEmptyElements)
+		}
+		return nil
+	}
+	if length > (len(s.data)-s.pos)/elemSize {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// maxEmptyElements is the maximum length of slices and maps whose elements do not take up any data
+const maxEmptyElements = 1 << 16
+
+// encodedSize returns the smallest number of bytes a value of type t is encoded in
+func encodedSize(t reflect.Type) int {
+	t = derefType(t)
+	if t.Kind() == reflect.Struct {
+		return getStructInfo(t).encodedSize()
+	}
+	return minEncodedSize(t, nil)
+}
+
+// minEncodedSize returns the smallest encoded size of the type.
+// Structs which are already being measured count as empty, since they can only be nested through pointers.
+func minEncodedSize(t reflect.Type, seen map[reflect.Type]bool) int {
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Struct:
+		if seen[t] {
+			return 0
+		}
+		if seen == nil {
+			seen = make(map[reflect.Type]bool)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		var size int
+		var info = getStructInfo(t)
+		for i := range info.fields {
+			var fi = &info.fields[i]
+			switch {
+			case fi.omitEmpty:
+				size++
+			case fi.packing != PackNone:
+				size += 4
+			default:
+				size += minEncodedSize(fi.typ, seen)
+			}
+		}
+		return size
+	case reflect.Slice, reflect.Map:
+		return 4
+	case reflect.Array:
+		return 4 + t.Len()*minEncodedSize(t.Elem(), seen)
+	default:
+		return 2
+	}
+}
+
+// read returns the next n bytes of the data, without copying them
+func (s *reader) read(n int) ([]byte, error) {
+	if len(s.data)-s.pos < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	var data = s.data[s.pos : s.pos+n : s.pos+n]
+	s.pos += n
+	return data, nil
+}
+
+// unsafeString returns a string sharing the memory of b
+func unsafeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
@@ -0,0 +1,206 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type BlobStruct struct {
+	Name  string            `tiny:"name"`
+	Data  []byte            `tiny:"data"`
+	Attrs map[string]string `tiny:"attrs"`
+}
+
+func TestDeserializeZeroCopy(t *testing.T) {
+	var blob = BlobStruct{
+		Name:  "original",
+		Data:  []byte("payload"),
+		Attrs: map[string]string{"key": "value"},
+	}
+	serialized, err := NewSerializer().AppendSerialize(nil, &blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var copied, aliased BlobStruct
+	if err = NewSerializer().Deserialize(serialized, &copied); err != nil {
+		t.Fatal(err)
+	}
+	if err = NewSerializer().SetZeroCopy(true).Deserialize(serialized, &aliased); err != nil {
+		t.Fatal(err)
+	}
+	if aliased.Name != blob.Name || !bytes.Equal(aliased.Data, blob.Data) || aliased.Attrs["key"] != "value" {
+		t.Fatalf("expected %+v, got %+v", blob, aliased)
+	}
+
+	// Modifying the input is visible through aliased values only
+	for _, s := range [][]byte{[]byte("original"), []byte("payload"), []byte("value")} {
+		var i = bytes.Index(serialized, s)
+		copy(serialized[i:], bytes.ToUpper(s))
+	}
+	if copied.Name != "original" || string(copied.Data) != "payload" || copied.Attrs["key"] != "value" {
+		t.Fatalf("expected copied values to be unaffected, got %+v", copied)
+	}
+	if aliased.Name != "ORIGINAL" || string(aliased.Data) != "PAYLOAD" || aliased.Attrs["key"] != "VALUE" {
+		t.Fatalf("expected aliased values to share the input, got %+v", aliased)
+	}
+
+	// Appending to an aliased slice must not overwrite the data following it
+	aliased.Data = append(aliased.Data, '!')
+	if bytes.Contains(serialized, []byte("PAYLOAD!")) {
+		t.Fatal("expected aliased slice capacity to be limited to its length")
+	}
+}
+
+func TestDeserializeZeroCopyCompressed(t *testing.T) {
+	var s = NewSerializer().SetCompress(true).SetCompressThreshold(0).SetCompressIfSmaller(false).SetZeroCopy(true)
+	first, err := s.AppendSerialize(nil, &BlobStruct{Name: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.AppendSerialize(nil, &BlobStruct{Name: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decompressed data must not be reused while values alias it
+	var a, b BlobStruct
+	if err = s.Deserialize(first, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Deserialize(second, &b); err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "first" || b.Name != "second" {
+		t.Fatalf("expected first and second, got %q and %q", a.Name, b.Name)
+	}
+}
+
+func TestDeserializeTruncated(t *testing.T) {
+	serialized, err := NewSerializer().AppendSerialize(nil, &testStruct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, 3, len(serialized) / 2, len(serialized) - 1} {
+		var deserialized Testie
+		err = NewSerializer().Deserialize(serialized[:n], &deserialized)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected %v for %d bytes, got %v", io.ErrUnexpectedEOF, n, err)
+		}
+	}
+}
+
+// Nil pointers are encoded as their zero value, so the next node must be omitempty to end the list
+type listNode struct {
+	Name string    `tiny:"name"`
+	Next *listNode `tiny:"next,omitempty"`
+}
+
+var linkedList = listNode{Name: "a", Next: &listNode{Name: "b", Next: &listNode{Name: "c"}}}
+
+func TestDeserializeLinkedList(t *testing.T) {
+	var s = NewSerializer()
+	data, err := s.Serialize(&linkedList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded listNode
+	if err = s.Deserialize(data, &decoded); err != nil || !reflect.DeepEqual(decoded, linkedList) {
+		t.Fatalf("expected %+v, got %+v (%v)", linkedList, decoded, err)
+	}
+
+	// Recursive fields which are not omitempty are rejected, instead of recursing without end
+	type recursive struct {
+		Name string     `tiny:"name"`
+		Next *recursive `tiny:"next"`
+	}
+	type viaArray struct {
+		Items [1]struct {
+			Back *viaArray `tiny:"back"`
+		} `tiny:"items"`
+	}
+	for _, v := range []interface{}{&recursive{Name: "a", Next: &recursive{Name: "b"}}, &viaArray{}} {
+		if _, err = s.Serialize(v); err == nil || !strings.Contains(err.Error(), "omitempty") {
+			t.Fatalf("%T: expected an error for the recursive field, got %v", v, err)
+		}
+		if err = s.Deserialize(data, v); err == nil || !strings.Contains(err.Error(), "omitempty") {
+			t.Fatalf("%T: expected an error for the recursive field, got %v", v, err)
+		}
+	}
+}
+
+func TestDeserializeOmitEmpty(t *testing.T) {
+	type omitStruct struct {
+		A string `tiny:"a,omitempty"`
+		B int64  `tiny:"b"`
+		C []int  `tiny:"omitempty"`
+	}
+	var s = NewSerializer()
+	for _, v := range []omitStruct{{A: "x", B: 5}, {B: 5, C: []int{1}}, {}} {
+		data, err := s.AppendSerialize(nil, &v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Presence is read from the data, not from the value decoded into
+		var into, over = omitStruct{}, omitStruct{A: "old", C: []int{9}}
+		for _, decoded := range []*omitStruct{&into, &over} {
+			if err = s.Deserialize(data, decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*decoded, v) {
+				t.Fatalf("expected %+v, got %+v", v, *decoded)
+			}
+		}
+	}
+
+	if err := s.Deserialize([]byte{2}, &omitStruct{}); err == nil {
+		t.Fatal("expected an error for an invalid presence byte")
+	}
+}
+
+func TestDeserializeHostileLength(t *testing.T) {
+	type inner struct {
+		Name  string `tiny:"name"`
+		Count int64  `tiny:"count"`
+	}
+	type empty struct{}
+	var s = NewSerializer()
+	for _, data := range [][]byte{{0xff, 0xff, 0xff, 0xff}, {0x00, 0x00, 0x00, 0x10}, {0x02, 0x00, 0x00, 0x00, 0x00, 0x00}} {
+		var items struct {
+			Items []inner `tiny:"items"`
+		}
+		if err := s.Deserialize(data, &items); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected %v for % x, got %v", io.ErrUnexpectedEOF, data, err)
+		}
+		var pointers struct {
+			Items []*inner `tiny:"items"`
+		}
+		if err := s.Deserialize(data, &pointers); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected %v for % x, got %v", io.ErrUnexpectedEOF, data, err)
+		}
+		var byKey struct {
+			ByKey map[string]inner `tiny:"by_key"`
+		}
+		if err := s.Deserialize(data, &byKey); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected %v for % x, got %v", io.ErrUnexpectedEOF, data, err)
+		}
+		if err := s.DeserializeFields(data, &items, "items"); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected %v projecting % x, got %v", io.ErrUnexpectedEOF, data, err)
+		}
+	}
+
+	// Elements without data can not be checked against the input, their number is limited instead
+	var empties struct {
+		Items []empty `tiny:"items"`
+	}
+	if err := s.Deserialize([]byte{0xff, 0xff, 0xff, 0xff}, &empties); err == nil {
+		t.Fatal("expected an error for too many empty elements")
+	}
+	if err := s.Deserialize([]byte{0x03, 0x00, 0x00, 0x00}, &empties); err != nil || len(empties.Items) != 3 {
+		t.Fatalf("expected 3 empty elements, got %d, %v", len(empties.Items), err)
+	}
+}
@@ -0,0 +1,110 @@
+package tinyserializer
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Defaulter is implemented by structs which set defaults for fields missing from the payload,
+// for defaults which can not be written in a default tag option.
+//
+// Fields are missing when the payload ends before them, which happens when fields were added to
+// the end of the struct after the payload was written. Any field of a Defaulter can be missing,
+// other structs only allow fields with a default=value tag option to be missing.
+// Elements of slices, arrays and maps can not be cut short, defaults are not applied to them.
+//
+// SetDefaults is called on a new value with the default tag options already applied.
+// The missing fields are copied from it, the fields which were decoded are left as they are.
+type Defaulter interface {
+	SetDefaults()
+}
+
+var (
+	defaulterType = reflect.TypeOf((*Defaulter)(nil)).Elem()
+	durationType  = reflect.TypeOf(time.Duration(0))
+)
+
+// parseDefault parses the value of a default tag option for a field of type t.
+// Pointers are dereferenced, the default is set on the value they point to.
+func parseDefault(t reflect.Type, text string) (reflect.Value, error) {
+	t = derefType(t)
+	var value = reflect.New(t).Elem()
+	var err error
+	switch t.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(text)
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if t == durationType {
+			var d time.Duration
+			d, err = time.ParseDuration(text)
+			i = int64(d)
+		} else {
+			i, err = strconv.ParseInt(text, 0, t.Bits())
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		u, err = strconv.ParseUint(text, 0, t.Bits())
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(text, t.Bits())
+		value.SetFloat(f)
+	case reflect.Complex64, reflect.Complex128:
+		var c complex128
+		c, err = strconv.ParseComplex(text, t.Bits())
+		value.SetComplex(c)
+	default:
+		return value, fmt.Errorf("default option is not supported for %s", t)
+	}
+	if err != nil {
+		return value, fmt.Errorf("invalid default %q for %s: %w", text, t, err)
+	}
+	return value, nil
+}
+
+// setDefaults sets the fields of the struct from the field at index from on to their defaults,
+// because the payload ended before them. If p is not nil, only the fields in the projection are set.
+func setDefaults(value reflect.Value, info *structInfo, from int, p projection) {
+	// Defaults are set on a new value when only some of them are copied into value
+	var defaults = value
+	var copied = info.defaulter || p != nil
+	if copied {
+		defaults = reflect.New(value.Type()).Elem()
+	}
+	for i := from; i < len(info.fields); i++ {
+		var fi = &info.fields[i]
+		var field = defaults.Field(fi.index)
+		if !fi.defaultValue.IsValid() {
+			// Only required fields can be missing without a default, and fail validation when empty
+			field.Set(reflect.Zero(field.Type()))
+			continue
+		}
+		for field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			field = field.Elem()
+		}
+		field.Set(fi.defaultValue)
+	}
+	if info.defaulter {
+		defaults.Addr().Interface().(Defaulter).SetDefaults()
+	}
+	if !copied {
+		return
+	}
+	for i := from; i < len(info.fields); i++ {
+		var fi = &info.fields[i]
+		if _, ok := p[fi.name]; ok || p == nil {
+			value.Field(fi.index).Set(defaults.Field(fi.index))
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package tinyserializer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+type defaultsV1 struct {
+	Name string `tiny:"name"`
+}
+
+type defaultsItem struct {
+	Name  string `tiny:"name"`
+	Limit *int32 `tiny:"limit,default=10"`
+}
+
+type defaultsV2 struct {
+	Name    string        `tiny:"name"`
+	Retries int32         `tiny:"retries,default=3"`
+	Ratio   float64       `tiny:"ratio,default=0.5"`
+	Enabled bool          `tiny:"enabled,default=true"`
+	Mask    uint16        `tiny:"mask,default=0xff"`
+	Label   string        `tiny:"label,default=none"`
+	Timeout time.Duration `tiny:"timeout,default=1m30s"`
+	Scale   complex64     `tiny:"scale,default=1+2i"`
+}
+
+func TestDefaults(t *testing.T) {
+	var s = NewSerializer()
+	data, err := s.AppendSerialize(nil, &defaultsV1{Name: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v = defaultsV2{Retries: 1, Label: "overwritten"}
+	if err = s.Deserialize(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	var expected = defaultsV2{Name: "old", Retries: 3, Ratio: 0.5, Enabled: true, Mask: 0xff, Label: "none", Timeout: 90 * time.Second, Scale: 1 + 2i}
+	if v != expected {
+		t.Fatalf("expected %+v, got %+v", expected, v)
+	}
+
+	// Only the fields after the end of the payload get their defaults
+	data, err = s.AppendSerialize(nil, &struct {
+		Name    string `tiny:"name"`
+		Retries int32  `tiny:"retries"`
+	}{Name: "old", Retries: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v = defaultsV2{}
+	if err = s.Deserialize(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if expected.Retries = 5; v != expected {
+		t.Fatalf("expected %+v, got %+v", expected, v)
+	}
+
+	// Fields in the payload are decoded, even if they are zero
+	data, err = s.AppendSerialize(nil, &defaultsV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v = defaultsV2{}
+	if err = s.Deserialize(data, &v); err != nil || v != (defaultsV2{}) {
+		t.Fatalf("expected zero values from the payload, got %+v, %v", v, err)
+	}
+}
+
+func TestDefaultsNested(t *testing.T) {
+	type outer struct {
+		Name string       `tiny:"name"`
+		Item defaultsItem `tiny:"item"`
+	}
+	var s = NewSerializer()
+	data, err := s.AppendSerialize(nil, &struct {
+		Name string     `tiny:"name"`
+		Item defaultsV1 `tiny:"item"`
+	}{Name: "old", Item: defaultsV1{Name: "item"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v outer
+	if err = s.Deserialize(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Item.Name != "item" || v.Item.Limit == nil || *v.Item.Limit != 10 {
+		t.Fatalf("expected the default of the nested limit, got %+v", v.Item)
+	}
+
+	// The item has no default, so the payload can not end before it
+	data, err = s.AppendSerialize(nil, &defaultsV1{Name: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Deserialize(data, &v); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestDefaultsProjection(t *testing.T) {
+	var s = NewSerializer()
+	data, err := s.AppendSerialize(nil, &defaultsV1{Name: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the requested fields get their defaults, the others are left untouched
+	var v = defaultsV2{Name: "untouched", Label: "untouched"}
+	if err = s.DeserializeFields(data, &v, "retries", "timeout"); err != nil {
+		t.Fatal(err)
+	}
+	var expected = defaultsV2{Name: "untouched", Retries: 3, Label: "untouched", Timeout: 90 * time.Second}
+	if v != expected {
+		t.Fatalf("expected %+v, got %+v", expected, v)
+	}
+
+	// A nested struct which ends early is skipped like it is decoded, with its missing fields defaulted
+	type outer struct {
+		Item  defaultsItem `tiny:"item"`
+		Count int32        `tiny:"count,default=5"`
+	}
+	data, err = s.AppendSerialize(nil, &struct {
+		Item defaultsV1 `tiny:"item"`
+	}{Item: defaultsV1{Name: "item"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded, projected outer
+	if err = s.Deserialize(data, &decoded); err != nil || decoded.Count != 5 {
+		t.Fatalf("expected the default count, got %+v (%v)", decoded, err)
+	}
+	if err = s.DeserializeFields(data, &projected, "count"); err != nil || projected.Count != 5 || projected.Item.Name != "" {
+		t.Fatalf("expected only the default count, got %+v (%v)", projected, err)
+	}
+}
+
+func TestInvalidDefaults(t *testing.T) {
+	for _, v := range []interface{}{
+		&struct {
+			Retries int32 `tiny:"retries,default=many"`
+		}{},
+		&struct {
+			Small int8 `tiny:"small,default=300"`
+		}{},
+		&struct {
+			Limits []int32 `tiny:"limits,default=1"`
+		}{},
+	} {
+		if err := NewSerializer().Deserialize(nil, v); err == nil || !strings.Contains(err.Error(), "field ") {
+			t.Errorf("%T: expected a field error for the invalid default, got %v", v, err)
+		}
+	}
+}
+
+type defaultedStruct struct {
+	Name  string            `tiny:"name"`
+	Tags  map[string]string `tiny:"tags"`
+	Count int32             `tiny:"count,default=2"`
+}
+
+func (d *defaultedStruct) SetDefaults() {
+	d.Tags = map[string]string{"env": "dev"}
+	d.Count *= 10
+}
+
+func TestDefaulter(t *testing.T) {
+	var s = NewSerializer()
+	data, err := s.AppendSerialize(nil, &defaultsV1{Name: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v = defaultedStruct{Name: "overwritten", Tags: map[string]string{"a": "b"}}
+	if err = s.Deserialize(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "old" || len(v.Tags) != 1 || v.Tags["env"] != "dev" || v.Count != 20 {
+		t.Fatalf("expected defaults from SetDefaults, got %+v", v)
+	}
+
+	// Any field of a Defaulter can be missing
+	v = defaultedStruct{}
+	if err = s.Deserialize(nil, &v); err != nil || v.Name != "" || v.Count != 20 {
+		t.Fatalf("expected an empty payload to decode into defaults, got %+v, %v", v, err)
+	}
+
+	// Projections set defaults as well
+	v = defaultedStruct{}
+	if err = s.DeserializeFields(data, &v, "tags"); err != nil || v.Tags["env"] != "dev" {
+		t.Fatalf("expected defaults from DeserializeFields, got %+v, %v", v, err)
+	}
+}
+
+func TestDefaultsTruncatedElements(t *testing.T) {
+	var s = NewSerializer()
+	var first, last = defaultedStruct{Name: "a longer name", Count: 1}, defaultedStruct{Name: "b", Count: 2}
+	type items struct {
+		Items []defaultedStruct `tiny:"items"`
+	}
+	if err := s.Deserialize([]byte{0x03, 0x00, 0x00, 0x00}, &items{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	// The payload can end inside a struct field, but not inside a slice, array or map element
+	for _, v := range []interface{}{
+		&items{Items: []defaultedStruct{first, last}},
+		&struct {
+			Items [2]defaultedStruct `tiny:"items"`
+		}{Items: [2]defaultedStruct{first, last}},
+		&struct {
+			Items map[string]defaultedStruct `tiny:"items"`
+		}{Items: map[string]defaultedStruct{"k": first}},
+	} {
+		data, err := s.AppendSerialize(nil, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Cut off the tags and count of the last element
+		data = data[:len(data)-10]
+		if err = s.Deserialize(data, v); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("%T: expected %v, got %v", v, io.ErrUnexpectedEOF, err)
+		}
+		if err = s.DeserializeFields(data, v, "items"); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("%T: expected %v from DeserializeFields, got %v", v, io.ErrUnexpectedEOF, err)
+		}
+	}
+}
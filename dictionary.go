@@ -0,0 +1,272 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// MaxDictionarySize is the maximum useful size of a preset dictionary,
+// deflate can only refer back to the last 32KB of data.
+const MaxDictionarySize = 32 * 1024
+
+// ErrUnknownDictionary is returned when a payload was compressed with a dictionary which is not registered
+var ErrUnknownDictionary = errors.New("unknown compression dictionary")
+
+// Dictionary is a preset dictionary for compressing small, repetitive payloads.
+//
+// Payloads compressed with a dictionary record its id in the header,
+// the reader looks the dictionary up by id in its DictionaryRegistry.
+type Dictionary struct {
+	id   uint32
+	data []byte
+
+	writers sync.Pool
+	readers sync.Pool
+}
+
+// NewDictionary creates a new dictionary with the given id.
+// Only the last MaxDictionarySize bytes of the data are used.
+func NewDictionary(id uint32, data []byte) *Dictionary {
+	if len(data) > MaxDictionarySize {
+		data = data[len(data)-MaxDictionarySize:]
+	}
+	var d = &Dictionary{
+		id:   id,
+		data: append([]byte(nil), data...),
+	}
+	d.writers.New = func() interface{} {
+		var w = new(flateWriter)
+		// The error is only returned for invalid compression levels
+		w.w, _ = flate.NewWriterDict(&w.dst, flate.DefaultCompression, d.data)
+		return w
+	}
+	d.readers.New = func() interface{} {
+		var r = new(flateReader)
+		r.r = flate.NewReaderDict(&r.src, d.data)
+		return r
+	}
+	return d
+}
+
+// ID returns the id of the dictionary
+func (d *Dictionary) ID() uint32 {
+	return d.id
+}
+
+// Bytes returns the contents of the dictionary
+func (d *Dictionary) Bytes() []byte {
+	return d.data
+}
+
+type flateWriter struct {
+	dst sliceWriter
+	w   *flate.Writer
+}
+
+type flateReader struct {
+	src bytes.Reader
+	r   io.ReadCloser
+}
+
+// appendCompress compresses the data with the dictionary and appends it to dst
+func (d *Dictionary) appendCompress(dst, data []byte) ([]byte, error) {
+	var w = d.writers.Get().(*flateWriter)
+	defer d.writers.Put(w)
+
+	// Reset keeps the dictionary of the writer
+	w.dst.b = dst
+	w.w.Reset(&w.dst)
+
+	if _, err := w.w.Write(data); err != nil {
+		w.dst.b = nil
+		return nil, err
+	}
+	if err := w.w.Close(); err != nil {
+		w.dst.b = nil
+		return nil, err
+	}
+
+	dst = w.dst.b
+	w.dst.b = nil
+	return dst, nil
+}
+
+// appendDecompress decompresses the data with the dictionary and appends it to dst
+func (d *Dictionary) appendDecompress(dst, data []byte, sizeHint int) ([]byte, error) {
+	var r = d.readers.Get().(*flateReader)
+	defer func() {
+		r.src.Reset(nil)
+		d.readers.Put(r)
+	}()
+
+	r.src.Reset(data)
+	if err := r.r.(flate.Resetter).Reset(&r.src, d.data); err != nil {
+		return nil, err
+	}
+
+	dst, err := readAll(dst, r.r, sizeHint)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.r.Close(); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// DictionaryRegistry holds the dictionaries available for decompression
+type DictionaryRegistry struct {
+	mu    sync.RWMutex
+	dicts map[uint32]*Dictionary
+}
+
+// DefaultDictionaries is the registry used by serializers unless another one is set
+var DefaultDictionaries = NewDictionaryRegistry()
+
+// NewDictionaryRegistry creates a new, empty dictionary registry
+func NewDictionaryRegistry() *DictionaryRegistry {
+	return &DictionaryRegistry{
+		dicts: make(map[uint32]*Dictionary),
+	}
+}
+
+// Register adds the dictionary to the registry.
+// Registering a different dictionary under an id which is already in use is an error,
+// since payloads compressed with the old dictionary could no longer be read.
+func (r *DictionaryRegistry) Register(d *Dictionary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.dicts[d.id]; ok && !bytes.Equal(existing.data, d.data) {
+		return fmt.Errorf("dictionary with id %d is already registered", d.id)
+	}
+	r.dicts[d.id] = d
+	return nil
+}
+
+// Lookup returns the dictionary with the given id
+func (r *DictionaryRegistry) Lookup(id uint32) (*Dictionary, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.dicts[id]
+	return d, ok
+}
+
+// RegisterDictionary adds the dictionary to the default registry
+func RegisterDictionary(d *Dictionary) error {
+	return DefaultDictionaries.Register(d)
+}
+
+// SetDictionary sets the preset dictionary to compress payloads with.
+// The dictionary must also be available to the reader, either through
+// its registry or by setting the same dictionary.
+func (s *Serializer) SetDictionary(d *Dictionary) *Serializer {
+	s.dictionary = d
+	return s
+}
+
+// SetDictionaryRegistry sets the registry to look up dictionaries in when decompressing
+func (s *Serializer) SetDictionaryRegistry(r *DictionaryRegistry) *Serializer {
+	s.dictionaries = r
+	return s
+}
+
+// lookupDictionary returns the dictionary with the given id,
+// preferring the dictionary set on the serializer.
+func (s *Serializer) lookupDictionary(id uint32) (*Dictionary, error) {
+	if s.dictionary != nil && s.dictionary.id == id {
+		return s.dictionary, nil
+	}
+	if s.dictionaries != nil {
+		if d, ok := s.dictionaries.Lookup(id); ok {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d", ErrUnknownDictionary, id)
+}
+
+// TrainDictionary builds a preset dictionary of at most size bytes from sample payloads.
+//
+// Byte sequences which occur in several samples (map keys, repeated strings, common prefixes)
+// are collected and ranked by how much data they would save, the most valuable ones
+// are placed at the end of the dictionary where deflate can refer to them most cheaply.
+func TrainDictionary(samples [][]byte, size int) []byte {
+	if size <= 0 || size > MaxDictionarySize {
+		size = MaxDictionarySize
+	}
+
+	// Sequences must be shared by at least two samples to be worth storing
+	var minCount = 2
+	if len(samples) < 2 {
+		minCount = 1
+	}
+
+	// Count in how many samples each sequence of trainGramSize bytes occurs
+	var counts = make(map[string]int)
+	for _, sample := range samples {
+		var seen = make(map[string]struct{})
+		for i := 0; i+trainGramSize <= len(sample); i++ {
+			var gram = string(sample[i : i+trainGramSize])
+			if _, ok := seen[gram]; ok {
+				continue
+			}
+			seen[gram] = struct{}{}
+			counts[gram]++
+		}
+	}
+
+	// Collect the longest runs of common sequences as segments
+	var segments = make(map[string]int)
+	for _, sample := range samples {
+		for i := 0; i+trainGramSize <= len(sample); {
+			if counts[string(sample[i:i+trainGramSize])] < minCount {
+				i++
+				continue
+			}
+			var end = i + trainGramSize
+			for end < len(sample) && counts[string(sample[end+1-trainGramSize:end+1])] >= minCount {
+				end++
+			}
+			segments[string(sample[i:end])]++
+			i = end
+		}
+	}
+
+	// Rank the segments by the number of bytes they cover
+	var ranked = make([]string, 0, len(segments))
+	for segment := range segments {
+		ranked = append(ranked, segment)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		var si, sj = segments[ranked[i]] * len(ranked[i]), segments[ranked[j]] * len(ranked[j])
+		if si != sj {
+			return si > sj
+		}
+		return ranked[i] < ranked[j]
+	})
+
+	// Select the most valuable segments which fit
+	var selected = make([]string, 0, len(ranked))
+	var total int
+	for _, segment := range ranked {
+		if total+len(segment) > size {
+			continue
+		}
+		selected = append(selected, segment)
+		total += len(segment)
+	}
+
+	// Place the most valuable segments last
+	var dict = make([]byte, 0, total)
+	for i := len(selected) - 1; i >= 0; i-- {
+		dict = append(dict, selected[i]...)
+	}
+	return dict
+}
+
+// Minimum length of a sequence considered by TrainDictionary
+const trainGramSize = 4
@@ -0,0 +1,103 @@
+package tinyserializer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func dictionarySamples(t testing.TB, n int) [][]byte {
+	var samples = make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		data, err := NewSerializer().Serialize(&AllStruct{
+			StringField: fmt.Sprintf("record-%d", i),
+			IntField:    int64(i),
+			MapString:   map[string]string{"username": fmt.Sprintf("user%d", i), "location": "Amsterdam"},
+			MapInt:      map[string]int64{"visits": int64(i * 3)},
+			ListString:  []string{"administrator", "moderator"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples = append(samples, data)
+	}
+	return samples
+}
+
+func TestDictionaryCompression(t *testing.T) {
+	var dict = NewDictionary(1, TrainDictionary(dictionarySamples(t, 32), 1024))
+	if len(dict.Bytes()) == 0 || len(dict.Bytes()) > 1024 {
+		t.Fatalf("unexpected dictionary size %d", len(dict.Bytes()))
+	}
+
+	var registry = NewDictionaryRegistry()
+	if err := registry.Register(dict); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(NewDictionary(1, []byte("other"))); err == nil {
+		t.Fatal("expected error registering a different dictionary with the same id")
+	}
+
+	var record = AllStruct{
+		StringField: "record-100",
+		MapString:   map[string]string{"username": "user100", "location": "Amsterdam"},
+		MapInt:      map[string]int64{"visits": 300},
+		ListString:  []string{"administrator", "moderator"},
+	}
+
+	var plain = NewSerializer().SetCompress(true).SetCompressThreshold(0).SetCompressIfSmaller(false)
+	plainData, err := plain.Serialize(&record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var writer = NewSerializer().SetCompress(true).SetCompressThreshold(0).SetDictionary(dict)
+	dictData, err := writer.Serialize(&record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dictData[2] != flagCompressed|flagDictionary {
+		t.Fatalf("expected dictionary compressed payload, got flags %b", dictData[2])
+	}
+	if len(dictData) >= len(plainData) {
+		t.Fatalf("expected dictionary to improve compression: %d >= %d", len(dictData), len(plainData))
+	}
+	t.Logf("gzip: %d bytes, dictionary: %d bytes", len(plainData), len(dictData))
+
+	// The reader finds the dictionary through its registry
+	var deserialized AllStruct
+	var reader = NewSerializer().SetCompress(true).SetDictionaryRegistry(registry)
+	if err = reader.Deserialize(dictData, &deserialized); err != nil {
+		t.Fatal(err)
+	}
+	if deserialized.StringField != record.StringField || deserialized.MapString["location"] != "Amsterdam" {
+		t.Fatalf("deserialized struct is not equal to the original: %+v", deserialized)
+	}
+
+	// Readers without the dictionary fail
+	err = NewSerializer().SetCompress(true).SetDictionaryRegistry(NewDictionaryRegistry()).Deserialize(dictData, &deserialized)
+	if !errors.Is(err, ErrUnknownDictionary) {
+		t.Fatalf("expected %v, got %v", ErrUnknownDictionary, err)
+	}
+}
+
+func TestTrainDictionary(t *testing.T) {
+	var samples = [][]byte{
+		[]byte("first: shared-sequence, unique-aaaa"),
+		[]byte("second: shared-sequence, unique-bbbb"),
+		[]byte("third: shared-sequence, unique-cccc"),
+	}
+	var dict = string(TrainDictionary(samples, 0))
+	if dict == "" {
+		t.Fatal("expected a dictionary")
+	}
+	for _, unique := range []string{"aaaa", "bbbb", "cccc"} {
+		if strings.Contains(dict, unique) {
+			t.Fatalf("expected %q not to be in the dictionary %q", unique, dict)
+		}
+	}
+	if !strings.Contains(dict, "shared-sequence, unique-") {
+		t.Fatalf("expected the shared sequence in the dictionary %q", dict)
+	}
+}
@@ -0,0 +1,396 @@
+package tinyserializer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Number of bytes shown in the hex column of a dump line
+const dumpHexBytes = 8
+
+// Dump writes an annotated listing of serialized data to w, to inspect payloads which fail to decode.
+//
+// A payload header or legacy gzip stream at the start of the data is detected and decoded,
+// regardless of the settings of the serializer. Encrypted data is decrypted with the keyring of the serializer,
+// signed data is verified with its signer if it has one. Offsets after the header are relative to the
+// decompressed payload.
+//
+// If schema is not nil, the payload is walked as a value of its type: every line shows the offset
+// and bytes of a length prefix or value, followed by the field names and decoded values as a tree.
+// schema is only used for its type, it can be a nil pointer. The packing of the serializer is used
+// to read slices. Without a schema, the payload is written as a plain hex dump.
+//
+// Dump stops at the first value which can not be read, and returns its error after listing the
+// remaining bytes.
+func (s *Serializer) Dump(w io.Writer, data []byte, schema interface{}) error {
+	var d = dumper{w: w, packing: s.packing}
+
+	if isEncrypted(data) {
+		if s.keyring == nil {
+			return fmt.Errorf("%w: data is encrypted with key %d, but no keyring is set", ErrDecryption, binary.LittleEndian.Uint32(data[3:]))
+		}
+		decrypted, err := s.keyring.open(nil, data)
+		if err != nil {
+			return err
+		}
+		d.printf("encrypted with key %d, %d bytes decrypted to %d bytes\n", binary.LittleEndian.Uint32(data[3:]), len(data), len(decrypted))
+		data = decrypted
+	}
+
+	if isSigned(data) {
+		payload, err := s.dumpSigned(&d, data)
+		if err != nil {
+			return err
+		}
+		data = payload
+	}
+
+	var payload = data
+	switch {
+	case isGzip(data):
+		decompressed, err := Decompress(data)
+		if err != nil {
+			return fmt.Errorf("failed to decompress gzip stream: %w", err)
+		}
+		d.printf("gzip stream of %d bytes, decompressed to %d bytes\n", len(data), len(decompressed))
+		payload = decompressed
+	case hasHeader(data):
+		h, rest, err := parseHeader(data)
+		if err != nil {
+			// The magic may just as well be the start of a payload without a header
+			d.printf("data starts with the header magic, but is not a valid header: %v\n", err)
+			break
+		}
+		d.dumpHeader(h, data[:len(data)-len(rest)])
+		if h.flags&flagCompressed == 0 {
+			payload = rest
+			break
+		}
+
+		var dict *Dictionary
+		if h.flags&flagDictionary != 0 {
+			if dict, err = s.lookupDictionary(h.dictID); err != nil {
+				return err
+			}
+		}
+		if dict != nil {
+			payload, err = dict.appendDecompress(nil, rest, 0)
+		} else {
+			payload, err = Decompress(rest)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		d.printf("payload of %d bytes, decompressed to %d bytes\n", len(rest), len(payload))
+	}
+	d.data = payload
+
+	if schema == nil {
+		d.hexDump(0, payload)
+		return d.werr
+	}
+	var err = d.value(0, "", reflect.Zero(derefType(reflect.TypeOf(schema))), PackNone)
+	if err == nil && d.pos < len(d.data) {
+		err = fmt.Errorf("%d trailing bytes", len(d.data)-d.pos)
+	}
+	if err != nil {
+		d.printf("error at offset %#x: %v\n", d.pos, err)
+		d.hexDump(d.pos, d.data[d.pos:])
+		return err
+	}
+	return d.werr
+}
+
+// dumpSigned prints the signed envelope, and returns the payload in it
+func (s *Serializer) dumpSigned(d *dumper, data []byte) ([]byte, error) {
+	var prefix = signedPrefixSize
+	var text = fmt.Sprintf("signed with key %d", binary.LittleEndian.Uint32(data[4:]))
+	if data[3]&signedExpires != 0 && len(data) >= prefix+8 {
+		var expiry = time.UnixMilli(int64(binary.LittleEndian.Uint64(data[prefix:])))
+		text += fmt.Sprintf(", expires %s", expiry.UTC().Format(time.RFC3339))
+		prefix += 8
+	}
+	if s.signer != nil {
+		payload, err := s.signer.verify(data)
+		if err != nil {
+			return nil, err
+		}
+		d.printf("%s, signature verified\n", text)
+		return payload, nil
+	}
+	if len(data) < prefix+sha256.Size {
+		return nil, fmt.Errorf("%w: envelope is truncated", ErrSignature)
+	}
+	d.printf("%s, signature not verified\n", text)
+	return data[prefix : len(data)-sha256.Size], nil
+}
+
+// dumper walks encoded values and writes them as lines of a dump
+type dumper struct {
+	reader
+	w       io.Writer
+	packing Packing
+	// The first error writing to w
+	werr error
+}
+
+func (d *dumper) printf(format string, args ...interface{}) {
+	if d.werr == nil {
+		_, d.werr = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+// line writes a line for the bytes of the data starting at offset, with the text at the depth of the tree.
+// The text starts with the name of the value, which is left out for the top level value.
+func (d *dumper) line(offset, end, depth int, text string) {
+	text = strings.TrimPrefix(text, " ")
+	var hex = formatHex(d.data[offset:end], dumpHexBytes)
+	if end-offset > dumpHexBytes {
+		hex += " .."
+	}
+	d.printf("%06x  %-*s  %s%s\n", offset, dumpHexBytes*3+2, hex, strings.Repeat("  ", depth), text)
+}
+
+func (d *dumper) dumpHeader(h header, raw []byte) {
+	var flags []string
+	for _, flag := range []struct {
+		flag byte
+		name string
+	}{{flagCompressed, "compressed"}, {flagDictionary, "dictionary"}, {flagIndex, "index"}, {flagFingerprint, "fingerprint"}, {flagVersion, "version"}} {
+		if h.flags&flag.flag != 0 {
+			flags = append(flags, flag.name)
+		}
+	}
+	if len(flags) == 0 {
+		flags = append(flags, "none")
+	}
+	d.printf("header %s, flags %s\n", formatHex(raw[:headerSize], headerSize), strings.Join(flags, "|"))
+	if h.flags&flagDictionary != 0 {
+		d.printf("  dictionary %d\n", h.dictID)
+	}
+	if h.flags&flagIndex != 0 {
+		var offsets = make([]string, 0, len(h.index)/4)
+		for i := 0; i < len(h.index); i += 4 {
+			offsets = append(offsets, fmt.Sprintf("%#x", binary.LittleEndian.Uint32(h.index[i:])))
+		}
+		d.printf("  index of %d fields: %s\n", len(offsets), strings.Join(offsets, " "))
+	}
+	if h.flags&flagFingerprint != 0 {
+		d.printf("  schema fingerprint %016x\n", h.fingerprint)
+	}
+	if h.flags&flagVersion != 0 {
+		d.printf("  version %d\n", h.version)
+	}
+}
+
+// value dumps the value of the type of value at the current position
+func (d *dumper) value(depth int, name string, value reflect.Value, packing Packing) error {
+	var start = d.pos
+	var t = value.Type()
+	switch t.Kind() {
+	case reflect.Ptr:
+		return d.value(depth, name, reflect.Zero(t.Elem()), packing)
+	case reflect.Struct:
+		d.line(start, start, depth, fmt.Sprintf("%s %s", name, t))
+		var info = getStructInfo(t)
+		if info.err != nil {
+			return info.err
+		}
+		for i := range info.fields {
+			var fi = &info.fields[i]
+			var field = value.Field(fi.index)
+			if fi.omitEmpty {
+				var start = d.pos
+				present, err := d.readPresence()
+				if err != nil {
+					return WrapFieldError(fi.name, err)
+				}
+				if !present {
+					d.line(start, d.pos, depth+1, fmt.Sprintf("%s %s omitted", fi.name, fi.typ))
+					continue
+				}
+			}
+			if err := d.value(depth+1, fi.name, field, fi.packing); err != nil {
+				return WrapFieldError(fi.name, err)
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return d.bytes(depth, name, t)
+		}
+		if packing != PackNone || d.packing != PackNone && packable(t) {
+			return d.packed(depth, name, t)
+		}
+		return d.elements(depth, name, t)
+	case reflect.Array:
+		return d.elements(depth, name, t)
+	case reflect.Map:
+		length, err := d.readLength()
+		if err == nil {
+			err = d.checkLength(length, encodedSize(t.Key())+encodedSize(t.Elem()))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read map length: %w", err)
+		}
+		d.line(start, d.pos, depth, fmt.Sprintf("%s %s len=%d", name, t, length))
+		var key, elem = reflect.Zero(t.Key()), reflect.Zero(t.Elem())
+		for i := 0; i < length; i++ {
+			if err = d.value(depth+1, fmt.Sprintf("key[%d]", i), key, PackNone); err != nil {
+				return err
+			}
+			if err = d.value(depth+1, fmt.Sprintf("value[%d]", i), elem, PackNone); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		data, err := d.readScalar()
+		if err != nil {
+			return err
+		}
+		text, err := formatScalar(t, data)
+		if err != nil {
+			return err
+		}
+		d.line(start, d.pos, depth, fmt.Sprintf("%s %s size=%d = %s", name, t, len(data), text))
+		return nil
+	}
+}
+
+// elements dumps the length of a slice or array, followed by its elements
+func (d *dumper) elements(depth int, name string, t reflect.Type) error {
+	var start = d.pos
+	length, err := d.readLength()
+	if err == nil {
+		err = d.checkLength(length, encodedSize(t.Elem()))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read slice length: %w", err)
+	}
+	d.line(start, d.pos, depth, fmt.Sprintf("%s %s len=%d", name, t, length))
+	var elem = reflect.Zero(t.Elem())
+	for i := 0; i < length; i++ {
+		if err = d.value(depth+1, fmt.Sprintf("[%d]", i), elem, PackNone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bytes dumps the length of a byte slice, followed by a hex dump of its contents
+func (d *dumper) bytes(depth int, name string, t reflect.Type) error {
+	var start = d.pos
+	length, err := d.readLength()
+	if err != nil {
+		return err
+	}
+	d.line(start, d.pos, depth, fmt.Sprintf("%s %s len=%d", name, t, length))
+	data, err := d.read(length)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(data); i += dumpHexBytes {
+		var end = i + dumpHexBytes
+		if end > len(data) {
+			end = len(data)
+		}
+		d.line(start+4+i, start+4+end, depth+1, fmt.Sprintf("|%s|", printable(data[i:end])))
+	}
+	return nil
+}
+
+// packed dumps the header of a packed block, followed by its elements
+func (d *dumper) packed(depth int, name string, t reflect.Type) error {
+	var start = d.pos
+	var elem = packedElemOf(t.Elem())
+	block, err := d.readPackedBlock(elem)
+	if err != nil {
+		return fmt.Errorf("failed to read packed slice: %w", err)
+	}
+	var mode = [...]string{packModeRaw: "raw", packModeVarint: "varint", packModeDelta: "delta", packModeBits: "bits"}[block.mode]
+	if block.mode == packModeRaw {
+		mode += fmt.Sprintf(" size=%d", block.size)
+	}
+	d.line(start, d.pos, depth, fmt.Sprintf("%s %s packed len=%d mode=%s", name, t, block.n, mode))
+
+	var values = reflect.New(t).Elem()
+	var dataStart = d.pos
+	d.pos = start
+	var s = Serializer{reader: d.reader}
+	if err = s.decodePacked(values); err != nil {
+		return fmt.Errorf("failed to read packed slice: %w", err)
+	}
+	d.pos = s.pos
+	d.line(dataStart, d.pos, depth+1, fmt.Sprintf("= %v", values.Interface()))
+	return nil
+}
+
+// hexDump writes the data as lines of 16 bytes, starting at offset
+func (d *dumper) hexDump(offset int, data []byte) {
+	for i := 0; i < len(data); i += 16 {
+		var end = i + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		d.printf("%06x  %-47s  |%s|\n", offset+i, formatHex(data[i:end], 16), printable(data[i:end]))
+	}
+}
+
+// formatScalar formats the data of a scalar of type t
+func formatScalar(t reflect.Type, data []byte) (string, error) {
+	var v interface{}
+	var err error
+	switch t.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("%q", data), nil
+	case reflect.Bool:
+		v, err = scalarBool(data)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err = scalarInt(data)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v, err = scalarUint(data)
+	case reflect.Float32, reflect.Float64:
+		v, err = scalarFloat(data)
+	case reflect.Complex64, reflect.Complex128:
+		v, err = scalarComplex(data)
+	default:
+		return "", fmt.Errorf("cannot dump value of kind %s", t.Kind())
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(v), nil
+}
+
+// formatHex formats up to max bytes of data as space separated hex
+func formatHex(data []byte, max int) string {
+	if len(data) > max {
+		data = data[:max]
+	}
+	var b strings.Builder
+	for i, c := range data {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%02x", c)
+	}
+	return b.String()
+}
+
+// printable returns the data as text, with unprintable bytes replaced by dots
+func printable(data []byte) string {
+	var b = make([]byte, len(data))
+	for i, c := range data {
+		if c < 0x20 || c > 0x7e {
+			c = '.'
+		}
+		b[i] = c
+	}
+	return string(b)
+}
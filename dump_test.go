@@ -0,0 +1,142 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type dumpStruct struct {
+	ID    int8             `tiny:"id"`
+	Name  *string          `tiny:"name"`
+	Opt   string           `tiny:"opt,omitempty"`
+	Raw   []byte           `tiny:"raw"`
+	Ints  []int64          `tiny:"ints,packed=varint"`
+	Map   map[string]int32 `tiny:"map"`
+	Float []float64        `tiny:"float"`
+}
+
+func dumpString(t *testing.T, s *Serializer, data []byte, schema interface{}) (string, error) {
+	var out bytes.Buffer
+	var err = s.Dump(&out, data, schema)
+	return out.String(), err
+}
+
+func TestDump(t *testing.T) {
+	var name = "hello"
+	var v = dumpStruct{ID: -3, Name: &name, Raw: []byte("raw bytes"), Ints: []int64{1, -300}, Map: map[string]int32{"a": 1}, Float: []float64{1.5}}
+	data, err := NewSerializer().SetIndex(true).Serialize(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := dumpString(t, NewSerializer(), data, (*dumpStruct)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"header 74 79 04, flags index\n",
+		"index of 7 fields: 0x0 0x3 0xa 0xb",
+		"000000  01 00 fd                      id int8 size=1 = -3\n",
+		"000003  05 00 68 65 6c 6c 6f          name string size=5 = \"hello\"\n",
+		"00000a  00                            opt string omitted\n",
+		"00000b  09 00 00 00                   raw []uint8 len=9\n",
+		"00000f  72 61 77 20 62 79 74 65         |raw byte|\n000017  73                              |s|\n",
+		"ints []int64 packed len=2 mode=varint\n",
+		"= [1 -300]\n",
+		"key[0] string size=1 = \"a\"\n",
+		"value[0] int32 size=4 = 1\n",
+		"08 00 00 00 00 00 00 00 ..      [0] float64 size=8 = 1.5\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected dump to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestDumpLinkedList(t *testing.T) {
+	data, err := NewSerializer().Serialize(&linkedList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := dumpString(t, NewSerializer(), data, (*listNode)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"name string size=1 = \"c\"\n", "next *tinyserializer.listNode omitted\n"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected dump to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestDumpCompressed(t *testing.T) {
+	var dict = NewDictionary(7, bytes.Repeat([]byte("StringField"), 4))
+	var registry = NewDictionaryRegistry()
+	if err := registry.Register(dict); err != nil {
+		t.Fatal(err)
+	}
+	var serializers = []*Serializer{
+		NewSerializer().SetCompress(true).SetCompressThreshold(0),
+		NewSerializer().SetCompress(true).SetCompressThreshold(0).SetDictionary(dict),
+	}
+	var expected = []string{"flags compressed\n", "flags compressed|dictionary\n  dictionary 7\n"}
+	for i, s := range serializers {
+		data, err := s.Serialize(All_S)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := dumpString(t, NewSerializer().SetDictionaryRegistry(registry), data, All_S)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, expected[i]) || !strings.Contains(out, "decompressed to") || !strings.Contains(out, "stringfield string") {
+			t.Errorf("unexpected dump:\n%s", out)
+		}
+	}
+
+	// Payloads written before headers were introduced are plain gzip streams
+	data, err := NewSerializer().Serialize(All_S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := dumpString(t, NewSerializer(), compressed, All_S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "gzip stream of") {
+		t.Errorf("unexpected dump:\n%s", out)
+	}
+}
+
+func TestDumpHex(t *testing.T) {
+	out, err := dumpString(t, NewSerializer(), []byte("0123456789abcdef\x00\x01"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected = "000000  30 31 32 33 34 35 36 37 38 39 61 62 63 64 65 66  |0123456789abcdef|\n" +
+		"000010  00 01                                            |..|\n"
+	if out != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestDumpErrors(t *testing.T) {
+	data, err := NewSerializer().Serialize(All_S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := dumpString(t, NewSerializer(), data[:len(data)-3], All_S)
+	if err == nil || !strings.Contains(out, "error at offset") {
+		t.Fatalf("expected error dumping truncated data, got %v:\n%s", err, out)
+	}
+
+	// Trailing data is listed after the value
+	out, err = dumpString(t, NewSerializer(), append(data, 1, 2, 3), All_S)
+	if err == nil || !strings.Contains(out, "3 trailing bytes") || !strings.HasSuffix(out, "01 02 03                                         |...|\n") {
+		t.Fatalf("expected trailing bytes error, got %v:\n%s", err, out)
+	}
+}
@@ -0,0 +1,213 @@
+package tinyserializer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// ErrFieldTooLarge is returned when a single value does not fit its 2 byte size prefix
+var ErrFieldTooLarge = errors.New("field data exceeds 65535 bytes")
+
+// appendValue appends the encoded value to dst.
+//
+// Scalars are stored as [size (2 bytes)][data], slices and maps as
+// [length (4 bytes)][elements], and structs as their fields in order.
+// Byte slices are stored as [length (4 bytes)][bytes].
+// Omitempty fields are prefixed with a presence byte, which is 0 if the field is empty and left out.
+func (s *Serializer) appendValue(dst []byte, value reflect.Value) ([]byte, error) {
+	switch value.Kind() {
+	case reflect.Struct:
+		return s.appendStruct(dst, value)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return appendBytes(dst, value.Bytes()), nil
+		}
+		if s.packing != PackNone && packable(value.Type()) {
+			return appendPacked(dst, value, s.packing, s.canonical), nil
+		}
+		return s.appendSlice(dst, value)
+	case reflect.Array:
+		return s.appendSlice(dst, value)
+	case reflect.Map:
+		if s.canonical {
+			return s.appendCanonicalMap(dst, value)
+		}
+		return s.appendMap(dst, value)
+	case reflect.Ptr:
+		if value.IsNil() {
+			// Nil pointers are stored as the zero value of their element
+			return s.appendValue(dst, reflect.Zero(value.Type().Elem()))
+		}
+		return s.appendValue(dst, value.Elem())
+	default:
+		if s.canonical {
+			return appendCanonicalScalar(dst, value)
+		}
+		return appendScalar(dst, value)
+	}
+}
+
+// appendStruct appends all serialized fields of the struct to dst
+func (s *Serializer) appendStruct(dst []byte, value reflect.Value) ([]byte, error) {
+	var err error
+	var info = getStructInfo(value.Type())
+	if info.err != nil {
+		return nil, info.err
+	}
+	if info.beforeSerialize {
+		if value, err = beforeSerialize(value); err != nil {
+			return nil, err
+		}
+	}
+	if s.useMarshaler(info, value) {
+		return appendMarshaler(dst, value)
+	}
+
+	// Only the fields of the top level struct are indexed
+	var indexing = s.indexing
+	s.indexing = false
+	for i := range info.fields {
+		var fi = &info.fields[i]
+		var field = value.Field(fi.index)
+		if indexing {
+			s.fieldIndex = binary.LittleEndian.AppendUint32(s.fieldIndex, uint32(len(dst)))
+		}
+		if fi.omitEmpty {
+			if field.IsZero() {
+				dst = append(dst, 0)
+				continue
+			}
+			dst = append(dst, 1)
+		}
+		if fi.packing != PackNone {
+			dst = appendPacked(dst, field, fi.packing, s.canonical)
+			continue
+		}
+		if dst, err = s.appendValue(dst, field); err != nil {
+			return nil, err
+		}
+		dst = s.flushHash(dst)
+	}
+	return dst, nil
+}
+
+// appendSlice appends the length of the slice, followed by all of its elements
+func (s *Serializer) appendSlice(dst []byte, value reflect.Value) ([]byte, error) {
+	var err error
+	var length = value.Len()
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(length))
+	for i := 0; i < length; i++ {
+		if dst, err = s.appendValue(dst, value.Index(i)); err != nil {
+			return nil, err
+		}
+		dst = s.flushHash(dst)
+	}
+	return dst, nil
+}
+
+// appendBytes appends the length of the byte slice, followed by the bytes as a single run
+func appendBytes(dst []byte, b []byte) []byte {
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(b)))
+	return append(dst, b...)
+}
+
+// appendMap appends the length of the map, followed by all of its keys and values
+func (s *Serializer) appendMap(dst []byte, value reflect.Value) ([]byte, error) {
+	var err error
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(value.Len()))
+	if value.Len() == 0 {
+		return dst, nil
+	}
+
+	// Copy the keys and values into reusable values, instead of allocating a copy per entry
+	var mapType = value.Type()
+	var key = reflect.New(mapType.Key()).Elem()
+	var elem = reflect.New(mapType.Elem()).Elem()
+	var iter = value.MapRange()
+	for iter.Next() {
+		key.SetIterKey(iter)
+		elem.SetIterValue(iter)
+		if dst, err = s.appendValue(dst, key); err != nil {
+			return nil, fmt.Errorf("failed to serialize map key: %w", err)
+		}
+		if dst, err = s.appendValue(dst, elem); err != nil {
+			return nil, fmt.Errorf("failed to serialize map value: %w", err)
+		}
+	}
+	return dst, nil
+}
+
+// appendScalar appends the size of the scalar value followed by its data
+func appendScalar(dst []byte, value reflect.Value) ([]byte, error) {
+	switch value.Kind() {
+	case reflect.String:
+		var str = value.String()
+		if len(str) > math.MaxUint16 {
+			return nil, ErrFieldTooLarge
+		}
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(len(str)))
+		return append(dst, str...), nil
+	case reflect.Bool:
+		dst = binary.LittleEndian.AppendUint16(dst, 1)
+		if value.Bool() {
+			return append(dst, 1), nil
+		}
+		return append(dst, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendUint(dst, uint64(value.Int()), int(value.Type().Size())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendUint(dst, value.Uint(), int(value.Type().Size())), nil
+	case reflect.Float32:
+		return appendUint(dst, uint64(math.Float32bits(float32(value.Float()))), 4), nil
+	case reflect.Float64:
+		return appendUint(dst, math.Float64bits(value.Float()), 8), nil
+	case reflect.Complex64:
+		var c = value.Complex()
+		dst = binary.LittleEndian.AppendUint16(dst, 8)
+		dst = binary.LittleEndian.AppendUint32(dst, math.Float32bits(float32(real(c))))
+		return binary.LittleEndian.AppendUint32(dst, math.Float32bits(float32(imag(c)))), nil
+	case reflect.Complex128:
+		var c = value.Complex()
+		dst = binary.LittleEndian.AppendUint16(dst, 16)
+		dst = binary.LittleEndian.AppendUint64(dst, math.Float64bits(real(c)))
+		return binary.LittleEndian.AppendUint64(dst, math.Float64bits(imag(c))), nil
+	default:
+		return nil, fmt.Errorf("cannot serialize value of kind %s", value.Kind())
+	}
+}
+
+// appendUint appends the size followed by the lowest size bytes of v in little endian order
+func appendUint(dst []byte, v uint64, size int) []byte {
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(size))
+	switch size {
+	case 1:
+		return append(dst, byte(v))
+	case 2:
+		return binary.LittleEndian.AppendUint16(dst, uint16(v))
+	case 4:
+		return binary.LittleEndian.AppendUint32(dst, uint32(v))
+	default:
+		return binary.LittleEndian.AppendUint64(dst, v)
+	}
+}
+
+// decodeUint decodes a little endian unsigned integer of up to 8 bytes
+func decodeUint(data []byte) uint64 {
+	var v uint64
+	for i := len(data) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(data[i])
+	}
+	return v
+}
+
+// signExtend extends the sign of a signed integer decoded from size bytes to 64 bits
+func signExtend(v uint64, size int) int64 {
+	if size == 0 || size >= 8 {
+		return int64(v)
+	}
+	var shift = uint(64 - 8*size)
+	return int64(v<<shift) >> shift
+}
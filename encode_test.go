@@ -0,0 +1,114 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"testing"
+)
+
+type SizedStruct struct {
+	Int8       int8       `tiny:"int8"`
+	Int16      int16      `tiny:"int16"`
+	Int32      int32      `tiny:"int32"`
+	Uint8      uint8      `tiny:"uint8"`
+	Uint16     uint16     `tiny:"uint16"`
+	Uint32     uint32     `tiny:"uint32"`
+	Float32    float32    `tiny:"float32"`
+	Complex64  complex64  `tiny:"complex64"`
+	Complex128 complex128 `tiny:"complex128"`
+	Array      [3]int16   `tiny:"array"`
+	Bytes      []byte     `tiny:"bytes"`
+	Ptr        *Structie  `tiny:"ptr"`
+}
+
+func TestAppendSerialize(t *testing.T) {
+	var s = NewSerializer()
+	serialized, err := s.Serialize(&testStruct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected = append([]byte(nil), serialized...)
+
+	appended, err := s.AppendSerialize([]byte("prefix"), &testStruct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(appended, []byte("prefix")) {
+		t.Fatal("expected data to be appended to dst")
+	}
+	if len(appended) != len("prefix")+len(expected) {
+		t.Fatalf("expected %d bytes, got %d", len("prefix")+len(expected), len(appended))
+	}
+
+	// Appending compressed data writes the header and payload after dst
+	s.SetCompress(true)
+	appended, err = s.AppendSerialize([]byte("prefix"), &testStruct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deserialized Testie
+	if err = s.Deserialize(appended[len("prefix"):], &deserialized); err != nil {
+		t.Fatal(err)
+	}
+	if deserialized.All == nil || deserialized.All.StringField != testStruct.All.StringField {
+		t.Fatal("deserialized struct is not equal to the original")
+	}
+}
+
+func TestAppendSerializeAllocs(t *testing.T) {
+	var s = NewSerializer()
+	var buf = make([]byte, 0, 1024)
+	var allocs = testing.AllocsPerRun(100, func() {
+		var err error
+		if buf, err = s.AppendSerialize(buf[:0], &ASTRUCT); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+}
+
+func TestSerializeSizedFields(t *testing.T) {
+	var sized = SizedStruct{
+		Int8:       -8,
+		Int16:      -1600,
+		Int32:      -320000,
+		Uint8:      8,
+		Uint16:     1600,
+		Uint32:     320000,
+		Float32:    3.25,
+		Complex64:  complex(1.5, -2.5),
+		Complex128: complex(1.25, -2.25),
+		Array:      [3]int16{1, -2, 3},
+		Bytes:      []byte("bytes"),
+	}
+
+	var s = NewSerializer()
+	serialized, err := s.Serialize(&sized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deserialized SizedStruct
+	if err = s.Deserialize(serialized, &deserialized); err != nil {
+		t.Fatal(err)
+	}
+	if deserialized.Ptr == nil {
+		t.Fatal("expected nil pointer to be deserialized as its zero value")
+	}
+	deserialized.Ptr = nil
+	if deserialized.Int8 != sized.Int8 || deserialized.Int16 != sized.Int16 || deserialized.Int32 != sized.Int32 ||
+		deserialized.Uint8 != sized.Uint8 || deserialized.Uint16 != sized.Uint16 || deserialized.Uint32 != sized.Uint32 ||
+		deserialized.Float32 != sized.Float32 || deserialized.Complex64 != sized.Complex64 ||
+		deserialized.Complex128 != sized.Complex128 || deserialized.Array != sized.Array ||
+		!bytes.Equal(deserialized.Bytes, sized.Bytes) {
+		t.Fatalf("expected %+v, got %+v", sized, deserialized)
+	}
+}
+
+func TestSerializeFieldTooLarge(t *testing.T) {
+	var large = AllStruct{StringField: string(make([]byte, 1<<16))}
+	if _, err := NewSerializer().Serialize(&large); err != ErrFieldTooLarge {
+		t.Fatalf("expected %v, got %v", ErrFieldTooLarge, err)
+	}
+}
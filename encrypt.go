@@ -0,0 +1,183 @@
+package tinyserializer
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Encrypted payloads are wrapped in an envelope holding everything needed to open them:
+// [magic (2 bytes)][version (1 byte)][key id (4 bytes)][nonce (12 bytes)][ciphertext][tag (16 bytes)]
+//
+// The payload inside is the complete output of the serializer, including its header.
+// The magic, version and key id are authenticated along with the ciphertext.
+const (
+	envelopeMagic0 byte = 't'
+	envelopeMagic1 byte = 'e'
+
+	envelopeVersion = 1
+	// Size of the authenticated prefix before the nonce
+	envelopePrefixSize = 7
+)
+
+// ErrDecryption is returned when a payload can not be decrypted,
+// because it is not encrypted, was tampered with or was encrypted with an unknown key
+var ErrDecryption = errors.New("payload can not be decrypted")
+
+// Keyring holds the AES keys to encrypt and decrypt payloads with, by their id.
+//
+// Payloads are encrypted with the primary key, and record its id so they can be decrypted
+// with any key of the ring. To rotate keys, add a new key and make it the primary key,
+// and remove the old key once no payloads encrypted with it are left.
+//
+// Every payload is encrypted with a new random nonce, a single key should not encrypt more than 2^32 payloads.
+type Keyring struct {
+	mu         sync.RWMutex
+	keys       map[uint32]cipher.AEAD
+	primary    uint32
+	hasPrimary bool
+}
+
+// NewKeyring creates a new, empty keyring
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: make(map[uint32]cipher.AEAD),
+	}
+}
+
+// Add adds an AES key of 16, 24 or 32 bytes to the keyring.
+// The first key added becomes the primary key.
+// Adding a key under an id which is already in use is an error,
+// since payloads encrypted with the old key could no longer be read.
+func (k *Keyring) Add(id uint32, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("key with id %d is already in the keyring", id)
+	}
+	k.keys[id] = aead
+	if !k.hasPrimary {
+		k.primary, k.hasPrimary = id, true
+	}
+	return nil
+}
+
+// SetPrimary sets the key to encrypt payloads with
+func (k *Keyring) SetPrimary(id uint32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("key with id %d is not in the keyring", id)
+	}
+	k.primary, k.hasPrimary = id, true
+	return nil
+}
+
+// Remove removes the key from the keyring, payloads encrypted with it can no longer be decrypted.
+// The primary key can not be removed.
+func (k *Keyring) Remove(id uint32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.hasPrimary && k.primary == id {
+		return fmt.Errorf("key with id %d is the primary key", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// seal encrypts the payload with the primary key, and appends the envelope to dst
+func (k *Keyring) seal(dst, payload []byte) ([]byte, error) {
+	k.mu.RLock()
+	var id, aead = k.primary, k.keys[k.primary]
+	k.mu.RUnlock()
+	if aead == nil {
+		return nil, errors.New("keyring has no primary key")
+	}
+
+	var start = len(dst)
+	dst = append(dst, envelopeMagic0, envelopeMagic1, envelopeVersion)
+	dst = binary.LittleEndian.AppendUint32(dst, id)
+	for i := 0; i < aead.NonceSize(); i++ {
+		dst = append(dst, 0)
+	}
+	var nonce = dst[start+envelopePrefixSize:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(dst, nonce, payload, dst[start:start+envelopePrefixSize]), nil
+}
+
+// isEncrypted reports whether data starts with the envelope magic
+func isEncrypted(data []byte) bool {
+	return len(data) >= envelopePrefixSize && data[0] == envelopeMagic0 && data[1] == envelopeMagic1
+}
+
+// open decrypts the payload in the envelope, and appends it to dst
+func (k *Keyring) open(dst, data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		return nil, fmt.Errorf("%w: data is not encrypted", ErrDecryption)
+	}
+	if data[2] != envelopeVersion {
+		return nil, fmt.Errorf("%w: unknown envelope version %d", ErrDecryption, data[2])
+	}
+	var id = binary.LittleEndian.Uint32(data[3:])
+	k.mu.RLock()
+	var aead = k.keys[id]
+	k.mu.RUnlock()
+	if aead == nil {
+		return nil, fmt.Errorf("%w: unknown key %d", ErrDecryption, id)
+	}
+
+	var rest = data[envelopePrefixSize:]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("%w: envelope is truncated", ErrDecryption)
+	}
+	dst, err := aead.Open(dst, rest[:aead.NonceSize()], rest[aead.NonceSize():], data[:envelopePrefixSize])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryption, err)
+	}
+	return dst, nil
+}
+
+// SetEncryption sets the keyring to encrypt serialized payloads with, using AES-GCM.
+//
+// Serialized payloads are wrapped in an envelope holding the key id and nonce, Deserialize opens it with the key from the keyring.
+// With a keyring set, payloads which are not encrypted are rejected with ErrDecryption, like tampered payloads.
+// Setting a nil keyring disables encryption.
+func (s *Serializer) SetEncryption(keyring *Keyring) *Serializer {
+	s.keyring = keyring
+	return s
+}
+
+// openPayload decrypts the data if encryption is enabled, and verifies its signature if signing is enabled.
+// If keep is set, the decrypted data is kept alive by the decoded values, so the buffer is not reused.
+func (s *Serializer) openPayload(data []byte, keep bool) ([]byte, error) {
+	if s.keyring != nil {
+		if keep {
+			s.openBuffer = nil
+		}
+		var err error
+		if s.openBuffer, err = s.keyring.open(s.openBuffer[:0], data); err != nil {
+			return nil, err
+		}
+		data = s.openBuffer
+	}
+	if s.signer != nil {
+		return s.signer.verify(data)
+	}
+	return data, nil
+}
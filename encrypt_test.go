@@ -0,0 +1,181 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type encryptSession struct {
+	User  string   `tiny:"user"`
+	Roles []string `tiny:"roles"`
+	Admin bool     `tiny:"admin"`
+}
+
+func testKeyring(t *testing.T, ids ...uint32) *Keyring {
+	var keyring = NewKeyring()
+	for _, id := range ids {
+		if err := keyring.Add(id, bytes.Repeat([]byte{byte(id)}, 32)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return keyring
+}
+
+func TestEncryption(t *testing.T) {
+	var s = NewSerializer().SetEncryption(testKeyring(t, 1))
+	var session = encryptSession{User: "ada@example.com", Roles: []string{"editor"}}
+	data, err := s.AppendSerialize(nil, &session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("ada@example.com")) {
+		t.Fatal("expected the payload to be encrypted")
+	}
+	again, err := s.AppendSerialize(nil, &session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(data, again) {
+		t.Fatal("expected every payload to use a new nonce")
+	}
+
+	var decoded encryptSession
+	if err = s.Deserialize(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.User != session.User || len(decoded.Roles) != 1 {
+		t.Fatalf("expected %+v, got %+v", session, decoded)
+	}
+
+	// Views and projections decrypt the payload as well
+	if user, err := s.View(data, &decoded).Field("user").Text(); err != nil || user != session.User {
+		t.Fatalf("expected to view the user, got %q, %v", user, err)
+	}
+	decoded = encryptSession{}
+	if err = s.DeserializeFields(data, &decoded, "roles"); err != nil || decoded.Roles[0] != "editor" {
+		t.Fatalf("expected to decode the roles, got %+v, %v", decoded, err)
+	}
+
+	// Encryption wraps compressed payloads
+	s.SetCompress(true).SetCompressThreshold(0)
+	data, err = s.Serialize(&encryptSession{User: string(bytes.Repeat([]byte("a"), 1000))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > 200 {
+		t.Fatalf("expected the payload to be compressed before encryption, got %d bytes", len(data))
+	}
+	if err = s.Deserialize(data, &decoded); err != nil || len(decoded.User) != 1000 {
+		t.Fatalf("expected to decode the compressed payload, got %v", err)
+	}
+}
+
+func TestEncryptionTampering(t *testing.T) {
+	var s = NewSerializer().SetEncryption(testKeyring(t, 1, 2))
+	data, err := s.AppendSerialize(nil, &encryptSession{User: "ada", Admin: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flipping any bit of the envelope, including the key id, fails authentication
+	for i := range data {
+		var tampered = append([]byte(nil), data...)
+		tampered[i] ^= 1
+		var decoded encryptSession
+		if err = s.Deserialize(tampered, &decoded); !errors.Is(err, ErrDecryption) {
+			t.Fatalf("expected %v after changing byte %d, got %v", ErrDecryption, i, err)
+		}
+	}
+	for _, truncated := range [][]byte{nil, data[:5], data[:len(data)-1], data[:20]} {
+		if err = s.Deserialize(truncated, &encryptSession{}); !errors.Is(err, ErrDecryption) {
+			t.Fatalf("expected %v for %d bytes, got %v", ErrDecryption, len(truncated), err)
+		}
+	}
+
+	// Payloads which are not encrypted are rejected
+	plain, err := NewSerializer().AppendSerialize(nil, &encryptSession{User: "ada", Admin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Deserialize(plain, &encryptSession{}); !errors.Is(err, ErrDecryption) {
+		t.Fatalf("expected %v for a plain payload, got %v", ErrDecryption, err)
+	}
+}
+
+func TestEncryptionWrongKey(t *testing.T) {
+	data, err := NewSerializer().SetEncryption(testKeyring(t, 1)).AppendSerialize(nil, &encryptSession{User: "ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A different key under the same id
+	var wrong = NewKeyring()
+	if err = wrong.Add(1, bytes.Repeat([]byte{9}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if err = NewSerializer().SetEncryption(wrong).Deserialize(data, &encryptSession{}); !errors.Is(err, ErrDecryption) {
+		t.Fatalf("expected %v for the wrong key, got %v", ErrDecryption, err)
+	}
+	// An unknown key id
+	if err = NewSerializer().SetEncryption(testKeyring(t, 2)).Deserialize(data, &encryptSession{}); !errors.Is(err, ErrDecryption) {
+		t.Fatalf("expected %v for an unknown key, got %v", ErrDecryption, err)
+	}
+	// No keyring at all
+	if err = NewSerializer().Deserialize(data, &encryptSession{}); err == nil {
+		t.Fatal("expected an error without a keyring")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	var keyring = testKeyring(t, 1)
+	var s = NewSerializer().SetEncryption(keyring)
+	old, err := s.AppendSerialize(nil, &encryptSession{User: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = keyring.Add(2, bytes.Repeat([]byte{2}, 16)); err != nil {
+		t.Fatal(err)
+	}
+	if err = keyring.SetPrimary(2); err != nil {
+		t.Fatal(err)
+	}
+	current, err := s.AppendSerialize(nil, &encryptSession{User: "current"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded encryptSession
+	for _, data := range [][]byte{old, current} {
+		if err = s.Deserialize(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if decoded.User != "current" {
+		t.Fatalf("expected current, got %q", decoded.User)
+	}
+
+	if err = keyring.Remove(2); err == nil {
+		t.Fatal("expected an error removing the primary key")
+	}
+	if err = keyring.Remove(1); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Deserialize(old, &decoded); !errors.Is(err, ErrDecryption) {
+		t.Fatalf("expected %v for a removed key, got %v", ErrDecryption, err)
+	}
+
+	if err = keyring.Add(2, bytes.Repeat([]byte{3}, 32)); err == nil {
+		t.Fatal("expected an error adding a key under an id in use")
+	}
+	if err = keyring.Add(3, []byte("short")); err == nil {
+		t.Fatal("expected an error for an invalid key size")
+	}
+	if err = keyring.SetPrimary(5); err == nil {
+		t.Fatal("expected an error for an unknown primary key")
+	}
+	if _, err = NewSerializer().SetEncryption(NewKeyring()).Serialize(&decoded); err == nil {
+		t.Fatal("expected an error encrypting without keys")
+	}
+}
@@ -0,0 +1,259 @@
+package tinyserializer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldInfo describes a serialized field of a struct
+type fieldInfo struct {
+	// Index of the field in the struct
+	index int
+	// Name of the field, taken from the tiny tag
+	name string
+	// Type of the field
+	typ reflect.Type
+
+	omitEmpty bool
+	// Packing of the field, set by the packed option for slices of numbers and bools
+	packing Packing
+	// Value of the default option, set when the payload ends before the field
+	defaultValue reflect.Value
+	defaultText  string
+	// Whether the field must be present and not empty
+	required bool
+}
+
+// optional reports whether the field can be missing from the payload.
+// Missing required fields are reported by validation, instead of as a truncated payload.
+func (fi *fieldInfo) optional() bool {
+	return fi.defaultValue.IsValid() || fi.required
+}
+
+// structInfo describes how a struct type is serialized
+type structInfo struct {
+	fields []fieldInfo
+
+	// Whether the pointer to the struct implements Marshaler or Unmarshaler
+	marshaler   bool
+	unmarshaler bool
+	// Whether the pointer to the struct implements Defaulter, Validator, BeforeSerializer or AfterDeserializer
+	defaulter        bool
+	validator        bool
+	beforeSerialize  bool
+	afterDeserialize bool
+	// Whether any field has the required option
+	required bool
+
+	// Index of the first field from which on all fields can be missing from the payload
+	optionalFrom int
+	// First invalid option or recursive field, returned when encoding or decoding the struct
+	err error
+
+	// Whether encoding or decoding the struct or the values nested in it runs code
+	// which generated methods do not run, such as defaults, validation and hooks
+	typ         reflect.Type
+	hooksOnce   sync.Once
+	encodeHooks bool
+	decodeHooks bool
+
+	// Smallest encoded size of the struct, checked against slice and map lengths
+	sizeOnce sync.Once
+	size     int
+}
+
+// encodedSize returns the smallest number of bytes the struct is encoded in
+func (info *structInfo) encodedSize() int {
+	info.sizeOnce.Do(func() {
+		info.size = minEncodedSize(info.typ, nil)
+	})
+	return info.size
+}
+
+// hasEncodeHooks reports whether encoding the struct or the values nested in it calls BeforeSerialize
+func (info *structInfo) hasEncodeHooks() bool {
+	info.findHooks()
+	return info.encodeHooks
+}
+
+// hasDecodeHooks reports whether decoding the struct or the values nested in it sets defaults, validates or calls AfterDeserialize
+func (info *structInfo) hasDecodeHooks() bool {
+	info.findHooks()
+	return info.decodeHooks
+}
+
+func (info *structInfo) findHooks() {
+	info.hooksOnce.Do(func() {
+		info.encodeHooks = nestedHooks(info.typ, make(map[reflect.Type]bool), func(info *structInfo) bool {
+			return info.beforeSerialize
+		})
+		info.decodeHooks = nestedHooks(info.typ, make(map[reflect.Type]bool), func(info *structInfo) bool {
+			return info.err != nil || info.optionalFrom < len(info.fields) || info.required || info.validator || info.afterDeserialize
+		})
+	})
+}
+
+// nestedHooks reports whether hooks reports true for any struct in the type t
+func nestedHooks(t reflect.Type, seen map[reflect.Type]bool, hooks func(*structInfo) bool) bool {
+	t = derefType(t)
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return nestedHooks(t.Elem(), seen, hooks)
+	case reflect.Map:
+		return nestedHooks(t.Key(), seen, hooks) || nestedHooks(t.Elem(), seen, hooks)
+	case reflect.Struct:
+		var info = getStructInfo(t)
+		if hooks(info) {
+			return true
+		}
+		for i := range info.fields {
+			if nestedHooks(info.fields[i].typ, seen, hooks) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// field returns the field with the given tag name, or nil if the struct has no such field
+func (info *structInfo) field(name string) *fieldInfo {
+	for i := range info.fields {
+		if info.fields[i].name == name {
+			return &info.fields[i]
+		}
+	}
+	return nil
+}
+
+// Cache of reflect.Type -> *structInfo
+var structInfoCache sync.Map
+
+// getStructInfo returns the cached serialization info for the given struct type.
+//
+// Only exported fields with a tiny tag are serialized, in the order they are declared.
+// The tag holds the name of the field, optionally followed by comma separated options.
+//
+// The default=value option sets the field when it is missing from the payload, which happens when
+// fields were added to the end of a struct after the payload was written. A field can only be missing
+// if the payload ends before it, so defaults apply to the top level struct and the last fields nested in it.
+// Fields without a default can not be missing, unless the struct implements Defaulter.
+//
+// The required option makes decoding fail with a ValidationError when the field is missing or empty.
+//
+// Nil pointers are encoded as the zero value of their element, so a pointer back to the struct itself
+// must have the omitempty option: otherwise the zero value would hold another nil pointer, without end.
+func getStructInfo(t reflect.Type) *structInfo {
+	if info, ok := structInfoCache.Load(t); ok {
+		return info.(*structInfo)
+	}
+
+	var info = &structInfo{
+		marshaler:   reflect.PtrTo(t).Implements(marshalerType),
+		unmarshaler: reflect.PtrTo(t).Implements(unmarshalerType),
+		defaulter:   reflect.PtrTo(t).Implements(defaulterType),
+		validator:   reflect.PtrTo(t).Implements(validatorType),
+		typ:         t,
+
+		beforeSerialize:  reflect.PtrTo(t).Implements(beforeSerializerType),
+		afterDeserialize: reflect.PtrTo(t).Implements(afterDeserializerType),
+	}
+	for i := 0; i < t.NumField(); i++ {
+		var field = t.Field(i)
+		var options, ok = tagOptions(field)
+		if !ok {
+			continue
+		}
+
+		var fi = fieldInfo{
+			index:     i,
+			name:      options[0],
+			typ:       field.Type,
+			omitEmpty: omitsEmpty(options),
+		}
+		for _, option := range options[1:] {
+			switch {
+			case option == "required":
+				fi.required = true
+				info.required = true
+			case (option == "packed" || strings.HasPrefix(option, "packed=")) && packable(field.Type):
+				fi.packing = parsePacking(option)
+			case strings.HasPrefix(option, "default="):
+				fi.defaultText = strings.TrimPrefix(option, "default=")
+				value, err := parseDefault(field.Type, fi.defaultText)
+				if err != nil && info.err == nil {
+					info.err = WrapFieldError(fi.name, err)
+				}
+				if err == nil {
+					fi.defaultValue = value
+				}
+			}
+		}
+		if !fi.omitEmpty && info.err == nil && zeroHolds(fi.typ, t, make(map[reflect.Type]bool)) {
+			info.err = WrapFieldError(fi.name, fmt.Errorf("recursive field of %s requires the omitempty option", t))
+		}
+		info.fields = append(info.fields, fi)
+	}
+
+	info.optionalFrom = len(info.fields)
+	for info.optionalFrom > 0 && (info.defaulter || info.fields[info.optionalFrom-1].optional()) {
+		info.optionalFrom--
+	}
+
+	actual, _ := structInfoCache.LoadOrStore(t, info)
+	return actual.(*structInfo)
+}
+
+// tagOptions returns the name and options in the tiny tag of the field, if the field is serialized
+func tagOptions(field reflect.StructField) ([]string, bool) {
+	var tag = field.Tag.Get("tiny")
+	if tag == "" || tag == "-" || field.PkgPath != "" {
+		return nil, false
+	}
+	return strings.Split(tag, ","), true
+}
+
+// omitsEmpty reports whether the tag options include omitempty
+func omitsEmpty(options []string) bool {
+	// A bare omitempty tag has always been supported
+	if len(options) == 1 {
+		return options[0] == "omitempty"
+	}
+	for _, option := range options[1:] {
+		if option == "omitempty" {
+			return true
+		}
+	}
+	return false
+}
+
+// zeroHolds reports whether the encoded zero value of t holds a struct of type target.
+// It parses the tags itself, as the structs it walks may be part of a cycle through target.
+func zeroHolds(t, target reflect.Type, seen map[reflect.Type]bool) bool {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return zeroHolds(t.Elem(), target, seen)
+	case reflect.Array:
+		return t.Len() > 0 && zeroHolds(t.Elem(), target, seen)
+	case reflect.Struct:
+		if t == target {
+			return true
+		}
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			var field = t.Field(i)
+			if options, ok := tagOptions(field); ok && !omitsEmpty(options) && zeroHolds(field.Type, target, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,329 @@
+package tinyserializer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Frames delimit serialized values in a stream, such as a net.Conn:
+// [magic (2 bytes)][flags (1 byte)][payload length (4 bytes)][payload][checksum (4 bytes)]
+//
+// The checksum is only present if flagged, it is the CRC-32C of the frame header and payload.
+const (
+	frameMagic0 byte = 't'
+	frameMagic1 byte = 'f'
+
+	frameHeaderSize   = 7
+	frameChecksumSize = 4
+)
+
+// Frame flags
+const (
+	// The payload is gzip compressed
+	frameCompressed byte = 1 << iota
+	// The payload is followed by a checksum
+	frameChecksum
+
+	knownFrameFlags = frameCompressed | frameChecksum
+)
+
+// DefaultMaxFrameSize is the default maximum size of a frame payload
+const DefaultMaxFrameSize = 16 << 20
+
+var (
+	// ErrFrameTooLarge is returned when a frame payload exceeds the maximum frame size
+	ErrFrameTooLarge = errors.New("frame exceeds maximum size")
+	// ErrFrameCorrupt is returned when a frame is malformed
+	ErrFrameCorrupt = errors.New("corrupt frame")
+	// ErrFrameChecksum is returned when the checksum of a frame does not match its contents
+	ErrFrameChecksum = errors.New("frame checksum mismatch")
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// FrameWriter writes serialized values to a stream, each in its own length delimited frame.
+// It is not safe for concurrent use.
+type FrameWriter struct {
+	w io.Writer
+	s *Serializer
+
+	compress bool
+	checksum bool
+	maxSize  int
+
+	// Reused buffers for the frame and compressed payloads
+	buf        []byte
+	compressed []byte
+}
+
+// NewFrameWriter returns a writer writing frames to w, serializing values with s.
+// If s is nil, a new serializer is used.
+func NewFrameWriter(w io.Writer, s *Serializer) *FrameWriter {
+	if s == nil {
+		s = NewSerializer()
+	}
+	return &FrameWriter{w: w, s: s, maxSize: DefaultMaxFrameSize}
+}
+
+// SetCompress sets whether frame payloads are compressed.
+// Payloads are only stored compressed if that makes them smaller,
+// the reader does not need to be configured for it.
+func (fw *FrameWriter) SetCompress(compress bool) *FrameWriter {
+	fw.compress = compress
+	return fw
+}
+
+// SetChecksum sets whether frames are followed by a checksum,
+// which lets the reader detect corrupted frames.
+func (fw *FrameWriter) SetChecksum(checksum bool) *FrameWriter {
+	fw.checksum = checksum
+	return fw
+}
+
+// SetMaxFrameSize sets the maximum size of a frame payload, larger values are rejected
+func (fw *FrameWriter) SetMaxFrameSize(size int) *FrameWriter {
+	fw.maxSize = size
+	return fw
+}
+
+// WriteFrame serializes the value and writes it as a single frame, with a single call to Write
+func (fw *FrameWriter) WriteFrame(v interface{}) error {
+	var err error
+	var flags byte
+	fw.buf = append(fw.buf[:0], make([]byte, frameHeaderSize)...)
+	if fw.buf, err = fw.s.AppendSerialize(fw.buf, v); err != nil {
+		return err
+	}
+
+	if fw.compress && len(fw.buf)-frameHeaderSize >= DefaultCompressThreshold {
+		fw.compressed, err = AppendCompress(fw.compressed[:0], fw.buf[frameHeaderSize:])
+		if err != nil {
+			return err
+		}
+		if len(fw.compressed) < len(fw.buf)-frameHeaderSize {
+			flags |= frameCompressed
+			fw.buf = append(fw.buf[:frameHeaderSize], fw.compressed...)
+		}
+	}
+
+	var size = len(fw.buf) - frameHeaderSize
+	if size > fw.maxSize {
+		return fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+	if fw.checksum {
+		flags |= frameChecksum
+	}
+	fw.buf[0], fw.buf[1], fw.buf[2] = frameMagic0, frameMagic1, flags
+	binary.LittleEndian.PutUint32(fw.buf[3:], uint32(size))
+	if fw.checksum {
+		fw.buf = binary.LittleEndian.AppendUint32(fw.buf, crc32.Checksum(fw.buf, castagnoli))
+	}
+
+	_, err = fw.w.Write(fw.buf)
+	return err
+}
+
+// FrameReader reads serialized values from a stream of frames written by a FrameWriter.
+// It is not safe for concurrent use.
+//
+// By default, the reader fails on the first corrupt frame and keeps returning that error.
+// With resynchronization enabled, corrupt frames are skipped instead,
+// and the reader scans ahead for the start of the next valid frame.
+type FrameReader struct {
+	r io.Reader
+	s *Serializer
+
+	maxSize int
+	resync  bool
+	skipped int
+	err     error
+
+	// Data read from r, the current frame starts at off.
+	// The buffer grows as data arrives, not by the size in a frame header which may be corrupt.
+	buf []byte
+	off int
+
+	// Reused buffer for decompressed payloads
+	decompressed []byte
+}
+
+// Initial size of the read buffer of a FrameReader
+const frameReadSize = 4096
+
+// NewFrameReader returns a reader reading frames from r, deserializing values with s.
+// If s is nil, a new serializer is used.
+func NewFrameReader(r io.Reader, s *Serializer) *FrameReader {
+	if s == nil {
+		s = NewSerializer()
+	}
+	return &FrameReader{r: r, s: s, maxSize: DefaultMaxFrameSize}
+}
+
+// SetMaxFrameSize sets the maximum size of a frame payload, larger frames are rejected
+func (fr *FrameReader) SetMaxFrameSize(size int) *FrameReader {
+	fr.maxSize = size
+	return fr
+}
+
+// SetResync sets whether corrupt frames are skipped, instead of failing the reader
+func (fr *FrameReader) SetResync(resync bool) *FrameReader {
+	fr.resync = resync
+	return fr
+}
+
+// Skipped returns the number of times corrupt data was skipped while resynchronizing
+func (fr *FrameReader) Skipped() int {
+	return fr.skipped
+}
+
+// ReadFrame reads the next frame and deserializes it into out.
+// It returns io.EOF when the stream ends between frames.
+//
+// If the serializer decodes in zero-copy mode, the values alias a buffer which is reused by the next call.
+func (fr *FrameReader) ReadFrame(out interface{}) error {
+	payload, err := fr.Next()
+	if err != nil {
+		return err
+	}
+	return fr.s.Deserialize(payload, out)
+}
+
+// Next reads the next frame and returns its payload, which is valid until the next call.
+// It returns io.EOF when the stream ends between frames.
+func (fr *FrameReader) Next() ([]byte, error) {
+	if fr.err != nil {
+		return nil, fr.err
+	}
+	for {
+		payload, err := fr.readFrame()
+		switch {
+		case err == nil:
+			return payload, nil
+		case fr.resync && len(fr.buf) > fr.off && (errors.Is(err, ErrFrameCorrupt) || errors.Is(err, ErrFrameChecksum) ||
+			errors.Is(err, ErrFrameTooLarge) || err == io.ErrUnexpectedEOF):
+			// Read the frame again from its second byte, looking for the next frame.
+			// A truncated frame may have a corrupt length which runs into the frames after it.
+			fr.skipped++
+			fr.off++
+			continue
+		}
+		fr.err = err
+		return nil, err
+	}
+}
+
+// readFrame reads the frame at fr.off, and returns its payload.
+// The frame is only consumed if it is valid.
+func (fr *FrameReader) readFrame() ([]byte, error) {
+	if err := fr.readMagic(); err != nil {
+		return nil, err
+	}
+	if err := fr.fill(frameHeaderSize); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	var flags = fr.buf[fr.off+2]
+	var size = int(binary.LittleEndian.Uint32(fr.buf[fr.off+3:]))
+	if flags&^knownFrameFlags != 0 {
+		return nil, fmt.Errorf("%w: unknown flags %#x", ErrFrameCorrupt, flags)
+	}
+	if size > fr.maxSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+
+	var total = frameHeaderSize + size
+	if flags&frameChecksum != 0 {
+		total += frameChecksumSize
+	}
+	if err := fr.fill(total); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	var frame = fr.buf[fr.off : fr.off+total]
+	var payload = frame[frameHeaderSize : frameHeaderSize+size]
+	if flags&frameChecksum != 0 {
+		var end = frameHeaderSize + size
+		if crc32.Checksum(frame[:end], castagnoli) != binary.LittleEndian.Uint32(frame[end:]) {
+			return nil, ErrFrameChecksum
+		}
+	}
+
+	if flags&frameCompressed != 0 {
+		var err error
+		fr.decompressed, err = appendDecompressLimit(fr.decompressed[:0], payload, 0, fr.maxSize)
+		if errors.Is(err, errDecompressLimit) {
+			return nil, fmt.Errorf("%w: decompressed payload", ErrFrameTooLarge)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to decompress: %v", ErrFrameCorrupt, err)
+		}
+		payload = fr.decompressed
+	}
+	fr.off += total
+	return payload, nil
+}
+
+// readMagic reads up to the magic at the start of a frame.
+// While resynchronizing, bytes are skipped until the magic is found.
+func (fr *FrameReader) readMagic() error {
+	var skipped bool
+	for {
+		if err := fr.fill(2); err != nil {
+			return err
+		}
+		if fr.buf[fr.off] == frameMagic0 && fr.buf[fr.off+1] == frameMagic1 {
+			return nil
+		}
+		if !fr.resync {
+			return fmt.Errorf("%w: invalid magic", ErrFrameCorrupt)
+		}
+		if !skipped {
+			skipped = true
+			fr.skipped++
+		}
+		// The second byte may be the first byte of the magic
+		fr.off++
+	}
+}
+
+// fill reads from the stream until the first n bytes of the current frame are buffered.
+// It returns io.EOF only if the stream ended before any of them could be read.
+func (fr *FrameReader) fill(n int) error {
+	for len(fr.buf)-fr.off < n {
+		if len(fr.buf) == cap(fr.buf) {
+			fr.grow()
+		}
+		read, err := fr.r.Read(fr.buf[len(fr.buf):cap(fr.buf)])
+		fr.buf = fr.buf[:len(fr.buf)+read]
+		if err != nil && len(fr.buf)-fr.off < n {
+			if err == io.EOF && len(fr.buf) > fr.off {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// grow makes room in the buffer, dropping the data before the current frame
+func (fr *FrameReader) grow() {
+	var unread = len(fr.buf) - fr.off
+	if fr.off > 0 && unread <= cap(fr.buf)/2 {
+		copy(fr.buf, fr.buf[fr.off:])
+	} else {
+		var grown = make([]byte, unread, 2*cap(fr.buf)+frameReadSize)
+		copy(grown, fr.buf[fr.off:])
+		fr.buf = grown
+	}
+	fr.buf, fr.off = fr.buf[:unread], 0
+}
+
+// unexpectedEOF converts io.EOF in the middle of a frame to io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
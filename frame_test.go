@@ -0,0 +1,172 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestFramesOverPipe(t *testing.T) {
+	var client, server = net.Pipe()
+	defer server.Close()
+
+	var values = []AllStruct{*All_S, {StringField: "small"}, {IntField: 42, ListString: BasicStringList}}
+	go func() {
+		defer client.Close()
+		var fw = NewFrameWriter(client, nil).SetCompress(true).SetChecksum(true)
+		for i := range values {
+			if err := fw.WriteFrame(&values[i]); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	var fr = NewFrameReader(server, nil)
+	for i := range values {
+		// Nil slices and maps are decoded as empty ones
+		var expected AllStruct
+		data, err := NewSerializer().Serialize(&values[i])
+		if err == nil {
+			err = NewSerializer().Deserialize(data, &expected)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded AllStruct
+		if err = fr.ReadFrame(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, decoded) {
+			t.Fatalf("frame %d: expected %+v, got %+v", i, values[i], decoded)
+		}
+	}
+	var decoded AllStruct
+	if err := fr.ReadFrame(&decoded); err != io.EOF {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+}
+
+// writeFrames writes the values as frames with checksums, and returns the stream and the offset of every frame
+func writeFrames(t *testing.T, values ...string) ([]byte, []int) {
+	var buf bytes.Buffer
+	var offsets []int
+	var fw = NewFrameWriter(&buf, nil).SetChecksum(true)
+	for _, v := range values {
+		offsets = append(offsets, buf.Len())
+		if err := fw.WriteFrame(&AllStruct{StringField: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes(), offsets
+}
+
+func readFrames(fr *FrameReader) ([]string, error) {
+	var values []string
+	for {
+		var v AllStruct
+		if err := fr.ReadFrame(&v); err != nil {
+			if err == io.EOF {
+				return values, nil
+			}
+			return values, err
+		}
+		values = append(values, v.StringField)
+	}
+}
+
+func TestFrameCorruption(t *testing.T) {
+	stream, offsets := writeFrames(t, "first", "second", "third")
+	// Corrupt the payload of the second frame
+	stream[offsets[1]+frameHeaderSize+2] ^= 0xff
+
+	values, err := readFrames(NewFrameReader(bytes.NewReader(stream), nil))
+	if !errors.Is(err, ErrFrameChecksum) || !reflect.DeepEqual(values, []string{"first"}) {
+		t.Fatalf("expected %v after the first frame, got %v %v", ErrFrameChecksum, values, err)
+	}
+
+	var fr = NewFrameReader(bytes.NewReader(stream), nil).SetResync(true)
+	values, err = readFrames(fr)
+	if err != nil || !reflect.DeepEqual(values, []string{"first", "third"}) {
+		t.Fatalf("expected the corrupt frame to be skipped, got %v %v", values, err)
+	}
+	if fr.Skipped() == 0 {
+		t.Fatal("expected skipped corruption to be counted")
+	}
+
+	// Garbage between frames, and a corrupted length
+	stream, offsets = writeFrames(t, "first", "second", "third")
+	stream[offsets[1]+3] = 0xff
+	stream = append(append([]byte("garbage t"), stream[:offsets[2]]...), append([]byte("tt"), stream[offsets[2]:]...)...)
+	values, err = readFrames(NewFrameReader(bytes.NewReader(stream), nil).SetResync(true).SetMaxFrameSize(1024))
+	if err != nil || !reflect.DeepEqual(values, []string{"first", "third"}) {
+		t.Fatalf("expected to resynchronize, got %v %v", values, err)
+	}
+}
+
+func TestFrameLimits(t *testing.T) {
+	var buf bytes.Buffer
+	var fw = NewFrameWriter(&buf, nil).SetMaxFrameSize(16)
+	if err := fw.WriteFrame(All_S); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expected %v, got %v", ErrFrameTooLarge, err)
+	}
+	if buf.Len() != 0 {
+		t.Fatal("expected nothing to be written for a frame which is too large")
+	}
+
+	stream, _ := writeFrames(t, "first")
+	var v AllStruct
+	if err := NewFrameReader(bytes.NewReader(stream), nil).SetMaxFrameSize(16).ReadFrame(&v); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expected %v, got %v", ErrFrameTooLarge, err)
+	}
+
+	// Compressed payloads are limited by their decompressed size
+	buf.Reset()
+	if err := NewFrameWriter(&buf, nil).SetCompress(true).WriteFrame(&AllStruct{StringField: string(make([]byte, 4096))}); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewFrameReader(&buf, nil).SetMaxFrameSize(1024).ReadFrame(&v); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expected %v, got %v", ErrFrameTooLarge, err)
+	}
+
+	if err := NewFrameReader(bytes.NewReader(stream[:len(stream)-1]), nil).ReadFrame(&v); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+}
+
+func TestFrameHostileInput(t *testing.T) {
+	// A header claiming the maximum size does not allocate it before the data arrives
+	var header = []byte{frameMagic0, frameMagic1, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(header[3:], DefaultMaxFrameSize)
+	var fr = NewFrameReader(bytes.NewReader(append(header, "short"...)), nil)
+	var v AllStruct
+	if err := fr.ReadFrame(&v); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+	if cap(fr.buf) > 1<<16 {
+		t.Fatalf("expected the buffer to grow with the data, got %d bytes", cap(fr.buf))
+	}
+
+	// Resynchronizing over a long run of corrupt frames skips them without copying the stream for every byte
+	var corrupt = bytes.Repeat([]byte{frameMagic0, frameMagic1, 0xff}, 1<<17)
+	stream, _ := writeFrames(t, "valid")
+	values, err := readFrames(NewFrameReader(bytes.NewReader(append(corrupt, stream...)), nil).SetResync(true))
+	if err != nil || !reflect.DeepEqual(values, []string{"valid"}) {
+		t.Fatalf("expected to resynchronize, got %v %v", values, err)
+	}
+
+	// A payload with a huge slice length fails to decode instead of allocating it
+	var payload = []byte{0xff, 0xff, 0xff, 0xff}
+	binary.LittleEndian.PutUint32(header[3:], uint32(len(payload)))
+	var items struct {
+		Items []AllStruct `tiny:"items"`
+	}
+	if err = NewFrameReader(bytes.NewReader(append(header, payload...)), nil).ReadFrame(&items); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+}
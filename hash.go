@@ -0,0 +1,62 @@
+package tinyserializer
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"reflect"
+	"sync"
+)
+
+// Encoded data is written to the hash whenever this many bytes are buffered
+const hashChunkSize = 4096
+
+// Pool of scratch buffers for hashing
+var hashBuffers = sync.Pool{
+	New: func() interface{} {
+		var buf = make([]byte, 0, 2*hashChunkSize)
+		return &buf
+	},
+}
+
+// Hash writes the canonical encoding of v to h.
+//
+// The encoding is streamed into h in small chunks, without materializing the whole payload.
+// Equal values always produce the same hash, see SetCanonical.
+func Hash(v interface{}, h hash.Hash) error {
+	var value = reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr && value.Kind() != reflect.Struct && value.Kind() != reflect.Slice && value.Kind() != reflect.Map {
+		return fmt.Errorf("data must be a pointer, struct, map or slice")
+	}
+
+	var buf = hashBuffers.Get().(*[]byte)
+	defer hashBuffers.Put(buf)
+
+	var s = Serializer{canonical: true, hash: h}
+	data, err := s.appendValue((*buf)[:0], value)
+	if err != nil {
+		return err
+	}
+	h.Write(data)
+	*buf = data[:0]
+	return nil
+}
+
+// Sum64 returns the 64 bit FNV-1a hash of the canonical encoding of v
+func Sum64(v interface{}) (uint64, error) {
+	var h = fnv.New64a()
+	if err := Hash(v, h); err != nil {
+		return 0, err
+	}
+	return h.Sum64(), nil
+}
+
+// flushHash writes the buffered data to the hash once enough has been buffered.
+// It is called between values, while no encoded data is held for sorting map entries.
+func (s *Serializer) flushHash(dst []byte) []byte {
+	if s.hash == nil || s.sortingMaps > 0 || len(dst) < hashChunkSize {
+		return dst
+	}
+	s.hash.Write(dst)
+	return dst[:0]
+}
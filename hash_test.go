@@ -0,0 +1,79 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+// recordingHash records the size of every write
+type recordingHash struct {
+	bytes.Buffer
+	writes []int
+}
+
+func (h *recordingHash) Write(p []byte) (int, error) {
+	h.writes = append(h.writes, len(p))
+	return h.Buffer.Write(p)
+}
+
+func (h *recordingHash) Sum(b []byte) []byte { return append(b, h.Bytes()...) }
+func (h *recordingHash) Size() int           { return h.Len() }
+func (h *recordingHash) BlockSize() int      { return 1 }
+
+func TestHashStreamsCanonicalEncoding(t *testing.T) {
+	var values = make([]*AllStruct, 200)
+	for i := range values {
+		values[i] = All_S
+	}
+	expected, err := NewSerializer().SetCanonical(true).AppendSerialize(nil, &values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var h recordingHash
+	if err = Hash(&values, &h); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(expected, h.Bytes()) {
+		t.Fatal("expected the hash to be written the canonical encoding")
+	}
+	if len(h.writes) < 2 {
+		t.Fatalf("expected the encoding to be streamed in chunks, got %d writes", len(h.writes))
+	}
+	for _, n := range h.writes {
+		if n > 2*hashChunkSize {
+			t.Fatalf("expected chunks of about %d bytes, got %d", hashChunkSize, n)
+		}
+	}
+}
+
+func TestSum64(t *testing.T) {
+	var keys = []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta", "iota", "kappa"}
+	var a, b = newCanonicalStruct(keys), newCanonicalStruct(keys)
+	sumA, err := Sum64(&a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		sumB, err := Sum64(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sumA != sumB {
+			t.Fatal("expected equal values to have the same hash")
+		}
+	}
+
+	b.Names["alpha"]++
+	if sumB, _ := Sum64(&b); sumA == sumB {
+		t.Fatal("expected different values to have different hashes")
+	}
+
+	if err = Hash(&testStruct, sha256.New()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Sum64(42); err == nil {
+		t.Fatal("expected error hashing a value which is not a pointer, struct, map or slice")
+	}
+}
@@ -0,0 +1,122 @@
+package tinyserializer
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// When compression, indexing, fingerprints or versioning are enabled, payloads are prefixed with a small header
+// so that the reader knows how the data following it was stored:
+// [magic (2 bytes)][flags (1 byte)][optional fields][payload]
+//
+// The optional fields are present depending on the flags, in the order of the flags:
+// flagDictionary: [dictionary id (4 bytes)]
+// flagIndex: [field count (4 bytes)][field offset (4 bytes)]...
+// flagFingerprint: [schema fingerprint (8 bytes)]
+// flagVersion: [version (4 bytes)]
+const (
+	headerMagic0 byte = 't'
+	headerMagic1 byte = 'y'
+
+	headerSize = 3
+)
+
+// Header flags
+const (
+	// The payload following the header is gzip compressed
+	flagCompressed byte = 1 << iota
+	// The payload was compressed with a preset dictionary
+	flagDictionary
+	// The header holds the offsets of the top level struct fields in the uncompressed payload
+	flagIndex
+	// The header holds the fingerprint of the schema of the serialized type
+	flagFingerprint
+	// The header holds the registered version of the serialized type
+	flagVersion
+
+	knownFlags = flagCompressed | flagDictionary | flagIndex | flagFingerprint | flagVersion
+)
+
+// ErrInvalidHeader is returned when a payload does not start with a valid header
+var ErrInvalidHeader = errors.New("invalid payload header")
+
+// header describes how the payload following it was stored
+type header struct {
+	flags  byte
+	dictID uint32
+	// Little endian 4 byte field offsets
+	index       []byte
+	fingerprint uint64
+	version     uint32
+}
+
+// appendTo appends the encoded header to dst
+func (h *header) appendTo(dst []byte) []byte {
+	dst = append(dst, headerMagic0, headerMagic1, h.flags)
+	if h.flags&flagDictionary != 0 {
+		dst = binary.LittleEndian.AppendUint32(dst, h.dictID)
+	}
+	if h.flags&flagIndex != 0 {
+		dst = binary.LittleEndian.AppendUint32(dst, uint32(len(h.index)/4))
+		dst = append(dst, h.index...)
+	}
+	if h.flags&flagFingerprint != 0 {
+		dst = binary.LittleEndian.AppendUint64(dst, h.fingerprint)
+	}
+	if h.flags&flagVersion != 0 {
+		dst = binary.LittleEndian.AppendUint32(dst, h.version)
+	}
+	return dst
+}
+
+// hasHeader reports whether data starts with the header magic
+func hasHeader(data []byte) bool {
+	return len(data) >= headerSize && data[0] == headerMagic0 && data[1] == headerMagic1
+}
+
+// parseHeader parses the header at the start of data,
+// and returns it along with the remaining payload.
+func parseHeader(data []byte) (header, []byte, error) {
+	var h header
+	if !hasHeader(data) {
+		return h, nil, ErrInvalidHeader
+	}
+	h.flags = data[2]
+	if h.flags&^knownFlags != 0 {
+		return h, nil, ErrInvalidHeader
+	}
+	data = data[headerSize:]
+	if h.flags&flagDictionary != 0 {
+		if len(data) < 4 {
+			return h, nil, ErrInvalidHeader
+		}
+		h.dictID = binary.LittleEndian.Uint32(data)
+		data = data[4:]
+	}
+	if h.flags&flagIndex != 0 {
+		if len(data) < 4 {
+			return h, nil, ErrInvalidHeader
+		}
+		var count = int(binary.LittleEndian.Uint32(data))
+		if count > (len(data)-4)/4 {
+			return h, nil, ErrInvalidHeader
+		}
+		h.index = data[4 : 4+count*4 : 4+count*4]
+		data = data[4+count*4:]
+	}
+	if h.flags&flagFingerprint != 0 {
+		if len(data) < 8 {
+			return h, nil, ErrInvalidHeader
+		}
+		h.fingerprint = binary.LittleEndian.Uint64(data)
+		data = data[8:]
+	}
+	if h.flags&flagVersion != 0 {
+		if len(data) < 4 {
+			return h, nil, ErrInvalidHeader
+		}
+		h.version = binary.LittleEndian.Uint32(data)
+		data = data[4:]
+	}
+	return h, data, nil
+}
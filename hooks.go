@@ -0,0 +1,57 @@
+package tinyserializer
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// BeforeSerializer is implemented by structs which prepare themselves for serialization,
+// for example by computing derived fields.
+//
+// BeforeSerialize is called on every struct before its fields are encoded, nested structs included.
+// Structs passed by value are copied first, so changes only end up in the payload.
+// An error aborts the serialization.
+type BeforeSerializer interface {
+	BeforeSerialize() error
+}
+
+// AfterDeserializer is implemented by structs which finish decoding themselves,
+// for example by rebuilding caches from the decoded fields.
+//
+// AfterDeserialize is called on every decoded struct after it has been validated, nested structs first.
+// Like validation, it is not called on the partially decoded structs of projections. An error aborts the deserialization.
+type AfterDeserializer interface {
+	AfterDeserialize() error
+}
+
+var (
+	beforeSerializerType  = reflect.TypeOf((*BeforeSerializer)(nil)).Elem()
+	afterDeserializerType = reflect.TypeOf((*AfterDeserializer)(nil)).Elem()
+)
+
+// beforeSerialize calls the BeforeSerialize method of the struct, and returns the value to encode.
+// Values which are not addressable are copied, so the method can be called on their pointer.
+func beforeSerialize(value reflect.Value) (reflect.Value, error) {
+	if !value.CanAddr() {
+		var copied = reflect.New(value.Type()).Elem()
+		copied.Set(value)
+		value = copied
+	}
+	if err := value.Addr().Interface().(BeforeSerializer).BeforeSerialize(); err != nil {
+		return value, fmt.Errorf("BeforeSerialize of %s failed: %w", value.Type(), err)
+	}
+	return value, nil
+}
+
+// decodedStruct validates a decoded struct, and calls its AfterDeserialize method
+func decodedStruct(value reflect.Value, info *structInfo) error {
+	if err := validateStruct(value, info); err != nil {
+		return err
+	}
+	if info.afterDeserialize && value.CanAddr() {
+		if err := value.Addr().Interface().(AfterDeserializer).AfterDeserialize(); err != nil {
+			return fmt.Errorf("AfterDeserialize of %s failed: %w", value.Type(), err)
+		}
+	}
+	return nil
+}
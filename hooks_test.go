@@ -0,0 +1,133 @@
+package tinyserializer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type hookLine struct {
+	Price    int64 `tiny:"price"`
+	Quantity int64 `tiny:"quantity"`
+	Total    int64 `tiny:"total"`
+
+	// Rebuilt after decoding
+	expensive bool
+}
+
+func (l *hookLine) BeforeSerialize() error {
+	if l.Quantity < 0 {
+		return errors.New("negative quantity")
+	}
+	l.Total = l.Price * l.Quantity
+	return nil
+}
+
+func (l *hookLine) AfterDeserialize() error {
+	if l.Total != l.Price*l.Quantity {
+		return errors.New("total does not match")
+	}
+	l.expensive = l.Total > 100
+	return nil
+}
+
+type hookOrder struct {
+	Lines   []hookLine          `tiny:"lines"`
+	ByName  map[string]hookLine `tiny:"by_name"`
+	Primary *hookLine           `tiny:"primary"`
+	Total   int64               `tiny:"total"`
+
+	// Set by AfterDeserialize, after the hooks of the lines
+	expensive int
+}
+
+func (o *hookOrder) BeforeSerialize() error {
+	o.Total = 0
+	for i := range o.Lines {
+		o.Total += o.Lines[i].Price * o.Lines[i].Quantity
+	}
+	return nil
+}
+
+func (o *hookOrder) AfterDeserialize() error {
+	o.expensive = 0
+	for _, line := range o.Lines {
+		if line.expensive {
+			o.expensive++
+		}
+	}
+	if o.Primary != nil && o.Primary.expensive {
+		o.expensive++
+	}
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	var order = hookOrder{
+		Lines:   []hookLine{{Price: 10, Quantity: 2}, {Price: 60, Quantity: 2}},
+		ByName:  map[string]hookLine{"pen": {Price: 3, Quantity: 3}},
+		Primary: &hookLine{Price: 200, Quantity: 1},
+	}
+	var s = NewSerializer()
+	data, err := s.AppendSerialize(nil, &order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Hooks of addressable values change the serialized value itself
+	if order.Total != 140 || order.Lines[1].Total != 120 || order.Primary.Total != 200 {
+		t.Fatalf("expected BeforeSerialize to set the totals, got %+v", order)
+	}
+
+	var decoded hookOrder
+	if err = s.Deserialize(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Total != 140 || decoded.ByName["pen"].Total != 9 || !decoded.Lines[1].expensive || decoded.Lines[0].expensive {
+		t.Fatalf("expected the totals to be serialized and caches rebuilt, got %+v", decoded)
+	}
+	if decoded.expensive != 2 {
+		t.Fatalf("expected the order hook to run after the hooks of its lines, got %d", decoded.expensive)
+	}
+
+	// Values passed by value are copied, the hooks still apply to the payload
+	var line = hookLine{Price: 5, Quantity: 5}
+	s.SetData(nil)
+	if err = s.WriteStruct(reflect.ValueOf(line), reflect.TypeOf(line)); err != nil {
+		t.Fatal(err)
+	}
+	var decodedLine hookLine
+	if err = s.Deserialize(s.buffer.Bytes(), &decodedLine); err != nil || decodedLine.Total != 25 || line.Total != 0 {
+		t.Fatalf("expected the copy to be serialized with its total, got %+v from %+v, %v", decodedLine, line, err)
+	}
+}
+
+func TestHookErrors(t *testing.T) {
+	var s = NewSerializer()
+	var order = hookOrder{Lines: []hookLine{{Price: 1, Quantity: -1}}}
+	if _, err := s.Serialize(&order); err == nil || !strings.Contains(err.Error(), "negative quantity") {
+		t.Fatalf("expected the BeforeSerialize error, got %v", err)
+	}
+
+	// A nested AfterDeserialize error aborts the deserialization
+	data, err := s.AppendSerialize(nil, &struct {
+		Lines []struct {
+			Price    int64 `tiny:"price"`
+			Quantity int64 `tiny:"quantity"`
+			Total    int64 `tiny:"total"`
+		} `tiny:"lines"`
+	}{Lines: []struct {
+		Price    int64 `tiny:"price"`
+		Quantity int64 `tiny:"quantity"`
+		Total    int64 `tiny:"total"`
+	}{{Price: 2, Quantity: 2, Total: 5}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Lines []hookLine `tiny:"lines"`
+	}
+	if err = s.Deserialize(data, &decoded); err == nil || !strings.Contains(err.Error(), "total does not match") {
+		t.Fatalf("expected the AfterDeserialize error, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package tinyserializer
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of serialized request and response bodies
+const ContentType = "application/x-tiny"
+
+// DefaultMaxRequestSize is the default maximum size of a request body read by DecodeRequest,
+// after decompression.
+const DefaultMaxRequestSize = 4 << 20
+
+// RequestError is returned when a request body can not be decoded,
+// Status is the HTTP status code to respond with.
+type RequestError struct {
+	Status int
+	Err    error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%d %s: %v", e.Status, http.StatusText(e.Status), e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorStatus returns the HTTP status code of a RequestError,
+// or http.StatusInternalServerError for any other error.
+func ErrorStatus(err error) int {
+	var reqErr *RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.Status
+	}
+	return http.StatusInternalServerError
+}
+
+// DecodeRequest deserializes the body of the request into v, which must be a pointer.
+// The body is limited to DefaultMaxRequestSize bytes, see DecodeRequestLimit.
+//
+//	if err := tinyserializer.DecodeRequest(r, &req); err != nil {
+//		http.Error(w, err.Error(), tinyserializer.ErrorStatus(err))
+//		return
+//	}
+func DecodeRequest(r *http.Request, v interface{}) error {
+	return DecodeRequestLimit(r, v, DefaultMaxRequestSize)
+}
+
+// DecodeRequestLimit is like DecodeRequest, but limits the body to limit bytes.
+// The limit applies both to the body as sent and to its decompressed size.
+//
+// The errors are of type *RequestError:
+// 415 if the content type or encoding is not supported, 413 if the body is too large,
+// and 400 if the body can not be decoded.
+func DecodeRequestLimit(r *http.Request, v interface{}, limit int) error {
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != ContentType {
+		return &RequestError{http.StatusUnsupportedMediaType, fmt.Errorf("content type must be %s", ContentType)}
+	}
+	var encoding = strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding != "" && encoding != "identity" && encoding != "gzip" {
+		return &RequestError{http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content encoding %s", encoding)}
+	}
+	if r.Body == nil {
+		return &RequestError{http.StatusBadRequest, errors.New("missing request body")}
+	}
+
+	var sizeHint int
+	if r.ContentLength > 0 && r.ContentLength <= int64(limit) {
+		sizeHint = int(r.ContentLength)
+	}
+	data, err := readAll(nil, http.MaxBytesReader(nil, r.Body, int64(limit)), sizeHint)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return &RequestError{http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", limit)}
+		}
+		return &RequestError{http.StatusBadRequest, fmt.Errorf("failed to read request body: %w", err)}
+	}
+
+	if encoding == "gzip" {
+		data, err = appendDecompressLimit(nil, data, gzipSizeHint(data), limit)
+		if errors.Is(err, errDecompressLimit) {
+			return &RequestError{http.StatusRequestEntityTooLarge, fmt.Errorf("decompressed request body exceeds %d bytes", limit)}
+		}
+		if err != nil {
+			return &RequestError{http.StatusBadRequest, fmt.Errorf("failed to decompress request body: %w", err)}
+		}
+	}
+
+	if err = NewSerializer().Deserialize(data, v); err != nil {
+		return &RequestError{http.StatusBadRequest, err}
+	}
+	return nil
+}
+
+// WriteResponse serializes v and writes it as the response body with the given status code.
+// If v can not be serialized, nothing is written and the error is returned.
+func WriteResponse(w http.ResponseWriter, status int, v interface{}) error {
+	return writeResponse(w, status, v, false)
+}
+
+// WriteResponseTo is like WriteResponse, but compresses the body with gzip
+// if the request accepts it and the body is large enough to benefit.
+func WriteResponseTo(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	return writeResponse(w, status, v, acceptsGzip(r))
+}
+
+func writeResponse(w http.ResponseWriter, status int, v interface{}, gzip bool) error {
+	data, err := NewSerializer().Serialize(v)
+	if err != nil {
+		return err
+	}
+
+	var header = w.Header()
+	if gzip {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if gzip && len(data) >= DefaultCompressThreshold {
+		compressed, err := Compress(data)
+		if err != nil {
+			return err
+		}
+		if len(compressed) < len(data) {
+			header.Set("Content-Encoding", "gzip")
+			data = compressed
+		}
+	}
+	header.Set("Content-Type", ContentType)
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	_, err = w.Write(data)
+	return err
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of the request allows gzip
+func acceptsGzip(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			var name, params, _ = strings.Cut(coding, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "gzip" && name != "*" {
+				continue
+			}
+			// A quality of zero means the coding is not acceptable
+			params = strings.ReplaceAll(params, " ", "")
+			if !strings.HasPrefix(params, "q=") {
+				return true
+			}
+			if quality, err := strconv.ParseFloat(params[2:], 64); err == nil && quality > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
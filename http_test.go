@@ -0,0 +1,205 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newTinyRequest(t *testing.T, body []byte) *http.Request {
+	var r = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", ContentType)
+	return r
+}
+
+func TestHTTPRoundTrip(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in AllStruct
+		if err := DecodeRequest(r, &in); err != nil {
+			http.Error(w, err.Error(), ErrorStatus(err))
+			return
+		}
+		if err := WriteResponseTo(w, r, http.StatusCreated, &in); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	data, err := NewSerializer().Serialize(All_S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(server.URL, ContentType+"; charset=binary", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Content-Type") != ContentType {
+		t.Fatalf("unexpected response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	// The transport asked for gzip, and decompresses the body itself
+	if !resp.Uncompressed {
+		t.Fatal("expected a compressed response")
+	}
+
+	var body bytes.Buffer
+	if _, err = body.ReadFrom(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	var out AllStruct
+	if err = NewSerializer().Deserialize(body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&out, All_S) {
+		t.Fatalf("expected %+v, got %+v", All_S, out)
+	}
+}
+
+func TestDecodeRequestGzip(t *testing.T) {
+	data, err := NewSerializer().Serialize(All_S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r = newTinyRequest(t, compressed)
+	r.Header.Set("Content-Encoding", "gzip")
+	var out AllStruct
+	if err = DecodeRequest(r, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&out, All_S) {
+		t.Fatalf("expected %+v, got %+v", All_S, out)
+	}
+}
+
+func TestDecodeRequestErrors(t *testing.T) {
+	data, err := NewSerializer().Serialize(All_S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := Compress(bytes.Repeat([]byte{0}, 1<<16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		request  func() *http.Request
+		limit    int
+		expected int
+	}{
+		{"content type", func() *http.Request {
+			var r = newTinyRequest(t, data)
+			r.Header.Set("Content-Type", "application/json")
+			return r
+		}, DefaultMaxRequestSize, http.StatusUnsupportedMediaType},
+		{"content encoding", func() *http.Request {
+			var r = newTinyRequest(t, data)
+			r.Header.Set("Content-Encoding", "br")
+			return r
+		}, DefaultMaxRequestSize, http.StatusUnsupportedMediaType},
+		{"too large", func() *http.Request {
+			return newTinyRequest(t, data)
+		}, len(data) - 1, http.StatusRequestEntityTooLarge},
+		{"decompressed too large", func() *http.Request {
+			var r = newTinyRequest(t, compressed)
+			r.Header.Set("Content-Encoding", "gzip")
+			return r
+		}, 1 << 10, http.StatusRequestEntityTooLarge},
+		{"invalid gzip", func() *http.Request {
+			var r = newTinyRequest(t, data)
+			r.Header.Set("Content-Encoding", "gzip")
+			return r
+		}, DefaultMaxRequestSize, http.StatusBadRequest},
+		{"truncated", func() *http.Request {
+			return newTinyRequest(t, data[:len(data)/2])
+		}, DefaultMaxRequestSize, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		var out AllStruct
+		var err = DecodeRequestLimit(test.request(), &out, test.limit)
+		if status := ErrorStatus(err); status != test.expected {
+			t.Errorf("%s: expected status %d, got %d (%v)", test.name, test.expected, status, err)
+		}
+	}
+	if err = DecodeRequestLimit(newTinyRequest(t, data), new(AllStruct), len(data)); err != nil {
+		t.Fatalf("expected a body of exactly the limit to be accepted, got %v", err)
+	}
+}
+
+func TestDecodeRequestHostileLength(t *testing.T) {
+	// A small body can not make the server allocate a huge slice, whatever the body limit
+	for _, body := range [][]byte{{0xff, 0xff, 0xff, 0xff}, {0x00, 0x00, 0x00, 0x10}} {
+		var items struct {
+			Items []AllStruct `tiny:"items"`
+		}
+		var err = DecodeRequest(newTinyRequest(t, body), &items)
+		if ErrorStatus(err) != http.StatusBadRequest || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected a bad request for % x, got %v", body, err)
+		}
+		err = DecodeRequestLimit(newTinyRequest(t, body), &items, 1<<30)
+		if ErrorStatus(err) != http.StatusBadRequest || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected a bad request for % x, got %v", body, err)
+		}
+	}
+}
+
+func TestWriteResponse(t *testing.T) {
+	var tests = []struct {
+		accept     string
+		compressed bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"*", true},
+	}
+	for _, test := range tests {
+		var r = httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept-Encoding", test.accept)
+		}
+		var w = httptest.NewRecorder()
+		if err := WriteResponseTo(w, r, http.StatusOK, All_S); err != nil {
+			t.Fatal(err)
+		}
+		var encoding = w.Header().Get("Content-Encoding")
+		if (encoding == "gzip") != test.compressed {
+			t.Errorf("accept %q: expected compressed %v, got encoding %q", test.accept, test.compressed, encoding)
+		}
+
+		var data = w.Body.Bytes()
+		if test.compressed {
+			var err error
+			if data, err = Decompress(data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var out AllStruct
+		if err := NewSerializer().Deserialize(data, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(&out, All_S) {
+			t.Fatalf("expected %+v, got %+v", All_S, out)
+		}
+	}
+
+	// Small bodies are not worth compressing
+	var r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	var w = httptest.NewRecorder()
+	if err := WriteResponseTo(w, r, http.StatusOK, &ArithArgs{A: 1, B: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+}
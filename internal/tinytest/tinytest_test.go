@@ -0,0 +1,203 @@
+package tinytest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Nigel2392/tinyserializer"
+)
+
+var pointer int64 = 42
+
+// Values to cross-check, maps have at most one entry so the output does not depend on iteration order.
+var values = []interface{}{
+	&Scalars{
+		Bool: true, Int: -1, Int8: -8, Int16: -16, Int32: -32, Int64: -64,
+		Uint: 1, Uint8: 8, Uint16: 16, Uint32: 32, Uint64: 64,
+		Float32: 3.5, Float64: 6.25, Complex64: complex(1, -1), Complex128: complex(-2, 2),
+		String: "string", Bytes: []byte("bytes"),
+	},
+	&Scalars{},
+	&Collections{
+		Ints:        []int64{1, 2, 3},
+		Strings:     []string{"a", "", "c"},
+		Nested:      [][]int32{{1}, {}, {2, 3}},
+		Map:         map[string]int64{"key": 1},
+		MapOfSlices: map[string][]float64{"key": {1.5, 2.5}},
+		SliceOfMaps: []map[int]string{{1: "one"}, {}},
+		ByteSlices:  [][]byte{[]byte("a"), {}},
+	},
+	&Collections{},
+	&Nested{
+		Child:    Child{Name: "child", Values: []int64{1}, Labels: map[string]string{"a": "b"}},
+		Ptr:      &Child{Name: "pointer"},
+		Children: []Child{{Name: "first"}, {Name: "second", Values: []int64{2, 3}}},
+		ByName:   map[string]Child{"name": {Name: "by name"}},
+		ByPtr:    map[int]*Child{1: {Name: "by pointer"}},
+		Optional: "optional",
+		Child2:   Child2{Flag: true},
+	},
+	&Nested{},
+	&Named{
+		Level: -3,
+		Tags:  Tags{"a", "b"},
+		Raw:   Raw("raw"),
+		Index: Index{1: Tags{"x"}},
+	},
+	&Packed{
+		Raw:      []int64{-1, 0, 1 << 40},
+		Varint:   []int32{-300, 0, 300},
+		Delta:    []uint64{10, 20, 25, 1 << 63},
+		Floats:   []float32{1.5, -2.25},
+		Bools:    []bool{true, false, true, true, false, false, false, false, true},
+		Levels:   Levels{-1, 2},
+		Unpacked: []int16{1, 2},
+		Strings:  []string{"not", "packable"},
+	},
+	&Packed{},
+	&Fallback{
+		Duration: 5 * time.Second,
+		Array:    [4]uint16{1, 2, 3, 4},
+		Pointer:  &pointer,
+	},
+}
+
+func TestGeneratedMatchesReflection(t *testing.T) {
+	var reflective = tinyserializer.NewSerializer().SetGenerated(false)
+	for _, v := range values {
+		expected, err := reflective.AppendSerialize(nil, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		generated, err := v.(tinyserializer.Marshaler).MarshalTiny(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected, generated) {
+			t.Fatalf("%T: generated output differs from reflection\nexpected: %v\ngot:      %v", v, expected, generated)
+		}
+
+		// The serializer prefers the generated methods
+		preferred, err := tinyserializer.NewSerializer().AppendSerialize(nil, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expected, preferred) {
+			t.Fatalf("%T: serializer output differs from reflection", v)
+		}
+	}
+}
+
+func TestGeneratedDecodesReflection(t *testing.T) {
+	var reflective = tinyserializer.NewSerializer().SetGenerated(false)
+	for _, v := range values {
+		data, err := reflective.AppendSerialize(nil, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Generated methods decode the output of reflection
+		var generated = reflect.New(reflect.TypeOf(v).Elem()).Interface()
+		n, err := generated.(tinyserializer.Unmarshaler).UnmarshalTiny(data)
+		if err != nil {
+			t.Fatalf("%T: %v", v, err)
+		}
+		if n != len(data) {
+			t.Fatalf("%T: expected %d bytes to be read, got %d", v, len(data), n)
+		}
+
+		// Reflection decodes the same value
+		var decoded = reflect.New(reflect.TypeOf(v).Elem()).Interface()
+		if err = reflective.Deserialize(data, decoded); err != nil {
+			t.Fatalf("%T: %v", v, err)
+		}
+		if !reflect.DeepEqual(generated, decoded) {
+			t.Fatalf("%T: generated and reflective decoding differ\ngenerated:  %+v\nreflective: %+v", v, generated, decoded)
+		}
+	}
+}
+
+func TestGeneratedLargeMaps(t *testing.T) {
+	var v = Collections{Map: map[string]int64{}}
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		v.Map[key] = int64(len(key))
+	}
+	data, err := v.MarshalTiny(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Collections
+	if err = tinyserializer.NewSerializer().SetGenerated(false).Deserialize(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v.Map, decoded.Map) {
+		t.Fatalf("expected %v, got %v", v.Map, decoded.Map)
+	}
+}
+
+func TestGeneratedTruncated(t *testing.T) {
+	data, err := values[0].(tinyserializer.Marshaler).MarshalTiny(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Scalars
+	if _, err = decoded.UnmarshalTiny(data[:len(data)-1]); err == nil {
+		t.Fatal("expected error decoding truncated data")
+	}
+}
+
+func TestGeneratedOmitEmpty(t *testing.T) {
+	for _, v := range []Nested{{Optional: "present"}, {}} {
+		data, err := v.MarshalTiny(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Presence is read from the data, not from the value decoded into
+		var into, over = Nested{}, Nested{Optional: "old"}
+		for _, decoded := range []*Nested{&into, &over} {
+			if _, err = decoded.UnmarshalTiny(data); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Optional != v.Optional || decoded.Child2 != v.Child2 {
+				t.Fatalf("expected %+v, got %+v", v, *decoded)
+			}
+		}
+	}
+}
+
+func TestGeneratedHostileLength(t *testing.T) {
+	// The length of the children follows the child and its pointer, which are both empty
+	child, err := (&Child{}).MarshalTiny(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prefix = append(child, child...)
+	for _, length := range [][]byte{{0xff, 0xff, 0xff, 0xff}, {0x00, 0x00, 0x00, 0x10}} {
+		var decoded Nested
+		if _, err = decoded.UnmarshalTiny(append(prefix[:len(prefix):len(prefix)], length...)); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected %v for length % x, got %v", io.ErrUnexpectedEOF, length, err)
+		}
+	}
+}
+
+func BenchmarkReflection(b *testing.B) {
+	var s = tinyserializer.NewSerializer().SetGenerated(false)
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = s.AppendSerialize(buf[:0], values[0])
+	}
+}
+
+func BenchmarkGenerated(b *testing.B) {
+	var s = tinyserializer.NewSerializer()
+	var buf []byte
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf, _ = s.AppendSerialize(buf[:0], values[0])
+	}
+}
@@ -0,0 +1,94 @@
+// Package tinytest holds types with generated serialization methods,
+// used to cross-check the generated code against the reflective serializer.
+package tinytest
+
+import "time"
+
+//go:generate go run ../../cmd/tinygen -type=Scalars,Collections,Nested,Child,Named,Fallback,Packed
+
+type Scalars struct {
+	Bool       bool       `tiny:"bool"`
+	Int        int        `tiny:"int"`
+	Int8       int8       `tiny:"int8"`
+	Int16      int16      `tiny:"int16"`
+	Int32      int32      `tiny:"int32"`
+	Int64      int64      `tiny:"int64"`
+	Uint       uint       `tiny:"uint"`
+	Uint8      uint8      `tiny:"uint8"`
+	Uint16     uint16     `tiny:"uint16"`
+	Uint32     uint32     `tiny:"uint32"`
+	Uint64     uint64     `tiny:"uint64"`
+	Float32    float32    `tiny:"float32"`
+	Float64    float64    `tiny:"float64"`
+	Complex64  complex64  `tiny:"complex64"`
+	Complex128 complex128 `tiny:"complex128"`
+	String     string     `tiny:"string"`
+	Bytes      []byte     `tiny:"bytes"`
+	Ignored    string     `tiny:"-"`
+	Untagged   string
+	unexported string `tiny:"unexported"`
+}
+
+type Collections struct {
+	Ints        []int64              `tiny:"ints"`
+	Strings     []string             `tiny:"strings"`
+	Nested      [][]int32            `tiny:"nested"`
+	Map         map[string]int64     `tiny:"map"`
+	MapOfSlices map[string][]float64 `tiny:"mapofslices"`
+	SliceOfMaps []map[int]string     `tiny:"sliceofmaps"`
+	ByteSlices  [][]byte             `tiny:"byteslices"`
+}
+
+type Child struct {
+	Name   string            `tiny:"name"`
+	Values []int64           `tiny:"values"`
+	Labels map[string]string `tiny:"labels"`
+}
+
+type Nested struct {
+	Child    Child            `tiny:"child"`
+	Ptr      *Child           `tiny:"ptr"`
+	Children []Child          `tiny:"children"`
+	ByName   map[string]Child `tiny:"byname"`
+	ByPtr    map[int]*Child   `tiny:"byptr"`
+	Optional string           `tiny:"optional,omitempty"`
+	Child2   `tiny:"embedded"`
+}
+
+type Child2 struct {
+	Flag bool `tiny:"flag"`
+}
+
+type Level int16
+type Tags []string
+type Raw []byte
+type Index map[Level]Tags
+
+type Named struct {
+	Level Level `tiny:"level"`
+	Tags  Tags  `tiny:"tags"`
+	Raw   Raw   `tiny:"raw"`
+	Index Index `tiny:"index"`
+}
+
+// Fallback has fields the generator leaves to the reflective serializer
+type Fallback struct {
+	Time     time.Time     `tiny:"time"`
+	Duration time.Duration `tiny:"duration"`
+	Array    [4]uint16     `tiny:"array"`
+	Pointer  *int64        `tiny:"pointer"`
+}
+
+type Levels []Level
+
+// Packed has slices with the packed tag option
+type Packed struct {
+	Raw      []int64   `tiny:"raw,packed"`
+	Varint   []int32   `tiny:"varint,packed=varint"`
+	Delta    []uint64  `tiny:"delta,packed=delta"`
+	Floats   []float32 `tiny:"floats,packed"`
+	Bools    []bool    `tiny:"bools,packed"`
+	Levels   Levels    `tiny:"levels,packed=varint"`
+	Unpacked []int16   `tiny:"unpacked"`
+	Strings  []string  `tiny:"strings,packed"`
+}
@@ -0,0 +1,835 @@
+// Code generated by tinygen. DO NOT EDIT.
+
+package tinytest
+
+import "github.com/Nigel2392/tinyserializer"
+
+// MarshalTiny appends the serialized Scalars to dst
+func (v *Scalars) MarshalTiny(dst []byte) ([]byte, error) {
+	var err error
+	dst = tinyserializer.AppendBool(dst, v.Bool)
+	dst = tinyserializer.AppendInt(dst, v.Int)
+	dst = tinyserializer.AppendInt8(dst, v.Int8)
+	dst = tinyserializer.AppendInt16(dst, v.Int16)
+	dst = tinyserializer.AppendInt32(dst, v.Int32)
+	dst = tinyserializer.AppendInt64(dst, v.Int64)
+	dst = tinyserializer.AppendUint(dst, v.Uint)
+	dst = tinyserializer.AppendUint8(dst, v.Uint8)
+	dst = tinyserializer.AppendUint16(dst, v.Uint16)
+	dst = tinyserializer.AppendUint32(dst, v.Uint32)
+	dst = tinyserializer.AppendUint64(dst, v.Uint64)
+	dst = tinyserializer.AppendFloat32(dst, v.Float32)
+	dst = tinyserializer.AppendFloat64(dst, v.Float64)
+	dst = tinyserializer.AppendComplex64(dst, v.Complex64)
+	dst = tinyserializer.AppendComplex128(dst, v.Complex128)
+	if dst, err = tinyserializer.AppendString(dst, v.String); err != nil {
+		return nil, err
+	}
+	dst = tinyserializer.AppendBytes(dst, v.Bytes)
+	return dst, nil
+}
+
+// UnmarshalTiny deserializes Scalars from the start of data, and returns the number of bytes read
+func (v *Scalars) UnmarshalTiny(data []byte) (int, error) {
+	var err error
+	var r = tinyserializer.NewReader(data)
+	{
+		var x1 bool
+		if x1, err = r.Bool(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("bool", err)
+		}
+		v.Bool = x1
+	}
+	{
+		var x1 int64
+		if x1, err = r.Int(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("int", err)
+		}
+		v.Int = int(x1)
+	}
+	{
+		var x1 int64
+		if x1, err = r.Int(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("int8", err)
+		}
+		v.Int8 = int8(x1)
+	}
+	{
+		var x1 int64
+		if x1, err = r.Int(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("int16", err)
+		}
+		v.Int16 = int16(x1)
+	}
+	{
+		var x1 int64
+		if x1, err = r.Int(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("int32", err)
+		}
+		v.Int32 = int32(x1)
+	}
+	{
+		var x1 int64
+		if x1, err = r.Int(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("int64", err)
+		}
+		v.Int64 = x1
+	}
+	{
+		var x1 uint64
+		if x1, err = r.Uint(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("uint", err)
+		}
+		v.Uint = uint(x1)
+	}
+	{
+		var x1 uint64
+		if x1, err = r.Uint(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("uint8", err)
+		}
+		v.Uint8 = uint8(x1)
+	}
+	{
+		var x1 uint64
+		if x1, err = r.Uint(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("uint16", err)
+		}
+		v.Uint16 = uint16(x1)
+	}
+	{
+		var x1 uint64
+		if x1, err = r.Uint(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("uint32", err)
+		}
+		v.Uint32 = uint32(x1)
+	}
+	{
+		var x1 uint64
+		if x1, err = r.Uint(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("uint64", err)
+		}
+		v.Uint64 = x1
+	}
+	{
+		var x1 float64
+		if x1, err = r.Float(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("float32", err)
+		}
+		v.Float32 = float32(x1)
+	}
+	{
+		var x1 float64
+		if x1, err = r.Float(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("float64", err)
+		}
+		v.Float64 = x1
+	}
+	{
+		var x1 complex128
+		if x1, err = r.Complex(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("complex64", err)
+		}
+		v.Complex64 = complex64(x1)
+	}
+	{
+		var x1 complex128
+		if x1, err = r.Complex(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("complex128", err)
+		}
+		v.Complex128 = x1
+	}
+	{
+		var x1 string
+		if x1, err = r.String(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("string", err)
+		}
+		v.String = x1
+	}
+	{
+		var x1 []byte
+		if x1, err = r.Bytes(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("bytes", err)
+		}
+		v.Bytes = x1
+	}
+	return r.Pos(), nil
+}
+
+// MarshalTiny appends the serialized Collections to dst
+func (v *Collections) MarshalTiny(dst []byte) ([]byte, error) {
+	var err error
+	dst = tinyserializer.AppendLength(dst, len(v.Ints))
+	for i1 := range v.Ints {
+		dst = tinyserializer.AppendInt64(dst, v.Ints[i1])
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.Strings))
+	for i1 := range v.Strings {
+		if dst, err = tinyserializer.AppendString(dst, v.Strings[i1]); err != nil {
+			return nil, err
+		}
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.Nested))
+	for i1 := range v.Nested {
+		dst = tinyserializer.AppendLength(dst, len(v.Nested[i1]))
+		for i2 := range v.Nested[i1] {
+			dst = tinyserializer.AppendInt32(dst, v.Nested[i1][i2])
+		}
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.Map))
+	for k1, v1 := range v.Map {
+		if dst, err = tinyserializer.AppendString(dst, k1); err != nil {
+			return nil, err
+		}
+		dst = tinyserializer.AppendInt64(dst, v1)
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.MapOfSlices))
+	for k1, v1 := range v.MapOfSlices {
+		if dst, err = tinyserializer.AppendString(dst, k1); err != nil {
+			return nil, err
+		}
+		dst = tinyserializer.AppendLength(dst, len(v1))
+		for i2 := range v1 {
+			dst = tinyserializer.AppendFloat64(dst, v1[i2])
+		}
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.SliceOfMaps))
+	for i1 := range v.SliceOfMaps {
+		dst = tinyserializer.AppendLength(dst, len(v.SliceOfMaps[i1]))
+		for k2, v2 := range v.SliceOfMaps[i1] {
+			dst = tinyserializer.AppendInt(dst, k2)
+			if dst, err = tinyserializer.AppendString(dst, v2); err != nil {
+				return nil, err
+			}
+		}
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.ByteSlices))
+	for i1 := range v.ByteSlices {
+		dst = tinyserializer.AppendBytes(dst, v.ByteSlices[i1])
+	}
+	return dst, nil
+}
+
+// UnmarshalTiny deserializes Collections from the start of data, and returns the number of bytes read
+func (v *Collections) UnmarshalTiny(data []byte) (int, error) {
+	var err error
+	var r = tinyserializer.NewReader(data)
+	{
+		var n1 int
+		if n1, err = r.Length(2); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("ints", err)
+		}
+		v.Ints = make([]int64, n1)
+		for i1 := range v.Ints {
+			{
+				var x2 int64
+				if x2, err = r.Int(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("ints", err)
+				}
+				v.Ints[i1] = x2
+			}
+		}
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(2); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("strings", err)
+		}
+		v.Strings = make([]string, n1)
+		for i1 := range v.Strings {
+			{
+				var x2 string
+				if x2, err = r.String(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("strings", err)
+				}
+				v.Strings[i1] = x2
+			}
+		}
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(4); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("nested", err)
+		}
+		v.Nested = make([][]int32, n1)
+		for i1 := range v.Nested {
+			{
+				var n2 int
+				if n2, err = r.Length(2); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("nested", err)
+				}
+				v.Nested[i1] = make([]int32, n2)
+				for i2 := range v.Nested[i1] {
+					{
+						var x3 int64
+						if x3, err = r.Int(); err != nil {
+							return r.Pos(), tinyserializer.WrapFieldError("nested", err)
+						}
+						v.Nested[i1][i2] = int32(x3)
+					}
+				}
+			}
+		}
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(4); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("map", err)
+		}
+		v.Map = make(map[string]int64, n1)
+		for i1 := 0; i1 < n1; i1++ {
+			var k1 string
+			var v1 int64
+			{
+				var x2 string
+				if x2, err = r.String(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("map", err)
+				}
+				k1 = x2
+			}
+			{
+				var x2 int64
+				if x2, err = r.Int(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("map", err)
+				}
+				v1 = x2
+			}
+			v.Map[k1] = v1
+		}
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(6); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("mapofslices", err)
+		}
+		v.MapOfSlices = make(map[string][]float64, n1)
+		for i1 := 0; i1 < n1; i1++ {
+			var k1 string
+			var v1 []float64
+			{
+				var x2 string
+				if x2, err = r.String(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("mapofslices", err)
+				}
+				k1 = x2
+			}
+			{
+				var n2 int
+				if n2, err = r.Length(2); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("mapofslices", err)
+				}
+				v1 = make([]float64, n2)
+				for i2 := range v1 {
+					{
+						var x3 float64
+						if x3, err = r.Float(); err != nil {
+							return r.Pos(), tinyserializer.WrapFieldError("mapofslices", err)
+						}
+						v1[i2] = x3
+					}
+				}
+			}
+			v.MapOfSlices[k1] = v1
+		}
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(4); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("sliceofmaps", err)
+		}
+		v.SliceOfMaps = make([]map[int]string, n1)
+		for i1 := range v.SliceOfMaps {
+			{
+				var n2 int
+				if n2, err = r.Length(4); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("sliceofmaps", err)
+				}
+				v.SliceOfMaps[i1] = make(map[int]string, n2)
+				for i2 := 0; i2 < n2; i2++ {
+					var k2 int
+					var v2 string
+					{
+						var x3 int64
+						if x3, err = r.Int(); err != nil {
+							return r.Pos(), tinyserializer.WrapFieldError("sliceofmaps", err)
+						}
+						k2 = int(x3)
+					}
+					{
+						var x3 string
+						if x3, err = r.String(); err != nil {
+							return r.Pos(), tinyserializer.WrapFieldError("sliceofmaps", err)
+						}
+						v2 = x3
+					}
+					v.SliceOfMaps[i1][k2] = v2
+				}
+			}
+		}
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(4); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("byteslices", err)
+		}
+		v.ByteSlices = make([][]byte, n1)
+		for i1 := range v.ByteSlices {
+			{
+				var x2 []byte
+				if x2, err = r.Bytes(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("byteslices", err)
+				}
+				v.ByteSlices[i1] = x2
+			}
+		}
+	}
+	return r.Pos(), nil
+}
+
+// MarshalTiny appends the serialized Nested to dst
+func (v *Nested) MarshalTiny(dst []byte) ([]byte, error) {
+	var err error
+	if dst, err = tinyserializer.AppendValue(dst, &v.Child); err != nil {
+		return nil, err
+	}
+	if dst, err = tinyserializer.AppendValue(dst, &v.Ptr); err != nil {
+		return nil, err
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.Children))
+	for i1 := range v.Children {
+		if dst, err = tinyserializer.AppendValue(dst, &v.Children[i1]); err != nil {
+			return nil, err
+		}
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.ByName))
+	for k1, v1 := range v.ByName {
+		if dst, err = tinyserializer.AppendString(dst, k1); err != nil {
+			return nil, err
+		}
+		if dst, err = tinyserializer.AppendValue(dst, &v1); err != nil {
+			return nil, err
+		}
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.ByPtr))
+	for k1, v1 := range v.ByPtr {
+		dst = tinyserializer.AppendInt(dst, k1)
+		if dst, err = tinyserializer.AppendValue(dst, &v1); err != nil {
+			return nil, err
+		}
+	}
+	if v.Optional != "" {
+		dst = tinyserializer.AppendPresence(dst, true)
+		if dst, err = tinyserializer.AppendString(dst, v.Optional); err != nil {
+			return nil, err
+		}
+	} else {
+		dst = tinyserializer.AppendPresence(dst, false)
+	}
+	if dst, err = tinyserializer.AppendValue(dst, &v.Child2); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// UnmarshalTiny deserializes Nested from the start of data, and returns the number of bytes read
+func (v *Nested) UnmarshalTiny(data []byte) (int, error) {
+	var err error
+	var r = tinyserializer.NewReader(data)
+	if err = r.Value(&v.Child); err != nil {
+		return r.Pos(), tinyserializer.WrapFieldError("child", err)
+	}
+	if err = r.Value(&v.Ptr); err != nil {
+		return r.Pos(), tinyserializer.WrapFieldError("ptr", err)
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(tinyserializer.EncodedSize((*Child)(nil))); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("children", err)
+		}
+		v.Children = make([]Child, n1)
+		for i1 := range v.Children {
+			if err = r.Value(&v.Children[i1]); err != nil {
+				return r.Pos(), tinyserializer.WrapFieldError("children", err)
+			}
+		}
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(2 + tinyserializer.EncodedSize((*Child)(nil))); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("byname", err)
+		}
+		v.ByName = make(map[string]Child, n1)
+		for i1 := 0; i1 < n1; i1++ {
+			var k1 string
+			var v1 Child
+			{
+				var x2 string
+				if x2, err = r.String(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("byname", err)
+				}
+				k1 = x2
+			}
+			if err = r.Value(&v1); err != nil {
+				return r.Pos(), tinyserializer.WrapFieldError("byname", err)
+			}
+			v.ByName[k1] = v1
+		}
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(2 + tinyserializer.EncodedSize((**Child)(nil))); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("byptr", err)
+		}
+		v.ByPtr = make(map[int]*Child, n1)
+		for i1 := 0; i1 < n1; i1++ {
+			var k1 int
+			var v1 *Child
+			{
+				var x2 int64
+				if x2, err = r.Int(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("byptr", err)
+				}
+				k1 = int(x2)
+			}
+			if err = r.Value(&v1); err != nil {
+				return r.Pos(), tinyserializer.WrapFieldError("byptr", err)
+			}
+			v.ByPtr[k1] = v1
+		}
+	}
+	{
+		var present bool
+		if present, err = r.Presence(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("optional", err)
+		}
+		if !present {
+			var zero string
+			v.Optional = zero
+		} else {
+			{
+				var x1 string
+				if x1, err = r.String(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("optional", err)
+				}
+				v.Optional = x1
+			}
+		}
+	}
+	if err = r.Value(&v.Child2); err != nil {
+		return r.Pos(), tinyserializer.WrapFieldError("embedded", err)
+	}
+	return r.Pos(), nil
+}
+
+// MarshalTiny appends the serialized Child to dst
+func (v *Child) MarshalTiny(dst []byte) ([]byte, error) {
+	var err error
+	if dst, err = tinyserializer.AppendString(dst, v.Name); err != nil {
+		return nil, err
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.Values))
+	for i1 := range v.Values {
+		dst = tinyserializer.AppendInt64(dst, v.Values[i1])
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.Labels))
+	for k1, v1 := range v.Labels {
+		if dst, err = tinyserializer.AppendString(dst, k1); err != nil {
+			return nil, err
+		}
+		if dst, err = tinyserializer.AppendString(dst, v1); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// UnmarshalTiny deserializes Child from the start of data, and returns the number of bytes read
+func (v *Child) UnmarshalTiny(data []byte) (int, error) {
+	var err error
+	var r = tinyserializer.NewReader(data)
+	{
+		var x1 string
+		if x1, err = r.String(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("name", err)
+		}
+		v.Name = x1
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(2); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("values", err)
+		}
+		v.Values = make([]int64, n1)
+		for i1 := range v.Values {
+			{
+				var x2 int64
+				if x2, err = r.Int(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("values", err)
+				}
+				v.Values[i1] = x2
+			}
+		}
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(4); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("labels", err)
+		}
+		v.Labels = make(map[string]string, n1)
+		for i1 := 0; i1 < n1; i1++ {
+			var k1 string
+			var v1 string
+			{
+				var x2 string
+				if x2, err = r.String(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("labels", err)
+				}
+				k1 = x2
+			}
+			{
+				var x2 string
+				if x2, err = r.String(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("labels", err)
+				}
+				v1 = x2
+			}
+			v.Labels[k1] = v1
+		}
+	}
+	return r.Pos(), nil
+}
+
+// MarshalTiny appends the serialized Named to dst
+func (v *Named) MarshalTiny(dst []byte) ([]byte, error) {
+	var err error
+	dst = tinyserializer.AppendInt16(dst, int16(v.Level))
+	dst = tinyserializer.AppendLength(dst, len(v.Tags))
+	for i1 := range v.Tags {
+		if dst, err = tinyserializer.AppendString(dst, v.Tags[i1]); err != nil {
+			return nil, err
+		}
+	}
+	dst = tinyserializer.AppendBytes(dst, v.Raw)
+	dst = tinyserializer.AppendLength(dst, len(v.Index))
+	for k1, v1 := range v.Index {
+		dst = tinyserializer.AppendInt16(dst, int16(k1))
+		dst = tinyserializer.AppendLength(dst, len(v1))
+		for i2 := range v1 {
+			if dst, err = tinyserializer.AppendString(dst, v1[i2]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return dst, nil
+}
+
+// UnmarshalTiny deserializes Named from the start of data, and returns the number of bytes read
+func (v *Named) UnmarshalTiny(data []byte) (int, error) {
+	var err error
+	var r = tinyserializer.NewReader(data)
+	{
+		var x1 int64
+		if x1, err = r.Int(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("level", err)
+		}
+		v.Level = Level(x1)
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(2); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("tags", err)
+		}
+		v.Tags = make(Tags, n1)
+		for i1 := range v.Tags {
+			{
+				var x2 string
+				if x2, err = r.String(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("tags", err)
+				}
+				v.Tags[i1] = x2
+			}
+		}
+	}
+	{
+		var x1 []byte
+		if x1, err = r.Bytes(); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("raw", err)
+		}
+		v.Raw = Raw(x1)
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(6); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("index", err)
+		}
+		v.Index = make(Index, n1)
+		for i1 := 0; i1 < n1; i1++ {
+			var k1 Level
+			var v1 Tags
+			{
+				var x2 int64
+				if x2, err = r.Int(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("index", err)
+				}
+				k1 = Level(x2)
+			}
+			{
+				var n2 int
+				if n2, err = r.Length(2); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("index", err)
+				}
+				v1 = make(Tags, n2)
+				for i2 := range v1 {
+					{
+						var x3 string
+						if x3, err = r.String(); err != nil {
+							return r.Pos(), tinyserializer.WrapFieldError("index", err)
+						}
+						v1[i2] = x3
+					}
+				}
+			}
+			v.Index[k1] = v1
+		}
+	}
+	return r.Pos(), nil
+}
+
+// MarshalTiny appends the serialized Fallback to dst
+func (v *Fallback) MarshalTiny(dst []byte) ([]byte, error) {
+	var err error
+	if dst, err = tinyserializer.AppendValue(dst, &v.Time); err != nil {
+		return nil, err
+	}
+	if dst, err = tinyserializer.AppendValue(dst, &v.Duration); err != nil {
+		return nil, err
+	}
+	if dst, err = tinyserializer.AppendValue(dst, &v.Array); err != nil {
+		return nil, err
+	}
+	if dst, err = tinyserializer.AppendValue(dst, &v.Pointer); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// UnmarshalTiny deserializes Fallback from the start of data, and returns the number of bytes read
+func (v *Fallback) UnmarshalTiny(data []byte) (int, error) {
+	var err error
+	var r = tinyserializer.NewReader(data)
+	if err = r.Value(&v.Time); err != nil {
+		return r.Pos(), tinyserializer.WrapFieldError("time", err)
+	}
+	if err = r.Value(&v.Duration); err != nil {
+		return r.Pos(), tinyserializer.WrapFieldError("duration", err)
+	}
+	if err = r.Value(&v.Array); err != nil {
+		return r.Pos(), tinyserializer.WrapFieldError("array", err)
+	}
+	if err = r.Value(&v.Pointer); err != nil {
+		return r.Pos(), tinyserializer.WrapFieldError("pointer", err)
+	}
+	return r.Pos(), nil
+}
+
+// MarshalTiny appends the serialized Packed to dst
+func (v *Packed) MarshalTiny(dst []byte) ([]byte, error) {
+	var err error
+	dst = tinyserializer.AppendPackedInts[int64](dst, v.Raw, tinyserializer.PackRaw)
+	dst = tinyserializer.AppendPackedInts[int32](dst, v.Varint, tinyserializer.PackVarint)
+	dst = tinyserializer.AppendPackedInts[uint64](dst, v.Delta, tinyserializer.PackDelta)
+	dst = tinyserializer.AppendPackedFloats[float32](dst, v.Floats)
+	dst = tinyserializer.AppendPackedBools[bool](dst, v.Bools)
+	dst = tinyserializer.AppendPackedInts[Level](dst, v.Levels, tinyserializer.PackVarint)
+	dst = tinyserializer.AppendLength(dst, len(v.Unpacked))
+	for i1 := range v.Unpacked {
+		dst = tinyserializer.AppendInt16(dst, v.Unpacked[i1])
+	}
+	dst = tinyserializer.AppendLength(dst, len(v.Strings))
+	for i1 := range v.Strings {
+		if dst, err = tinyserializer.AppendString(dst, v.Strings[i1]); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// UnmarshalTiny deserializes Packed from the start of data, and returns the number of bytes read
+func (v *Packed) UnmarshalTiny(data []byte) (int, error) {
+	var err error
+	var r = tinyserializer.NewReader(data)
+	{
+		var x1 []int64
+		if x1, err = tinyserializer.ReadPackedInts[int64](&r); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("raw", err)
+		}
+		v.Raw = x1
+	}
+	{
+		var x1 []int32
+		if x1, err = tinyserializer.ReadPackedInts[int32](&r); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("varint", err)
+		}
+		v.Varint = x1
+	}
+	{
+		var x1 []uint64
+		if x1, err = tinyserializer.ReadPackedInts[uint64](&r); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("delta", err)
+		}
+		v.Delta = x1
+	}
+	{
+		var x1 []float32
+		if x1, err = tinyserializer.ReadPackedFloats[float32](&r); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("floats", err)
+		}
+		v.Floats = x1
+	}
+	{
+		var x1 []bool
+		if x1, err = tinyserializer.ReadPackedBools[bool](&r); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("bools", err)
+		}
+		v.Bools = x1
+	}
+	{
+		var x1 []Level
+		if x1, err = tinyserializer.ReadPackedInts[Level](&r); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("levels", err)
+		}
+		v.Levels = Levels(x1)
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(2); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("unpacked", err)
+		}
+		v.Unpacked = make([]int16, n1)
+		for i1 := range v.Unpacked {
+			{
+				var x2 int64
+				if x2, err = r.Int(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("unpacked", err)
+				}
+				v.Unpacked[i1] = int16(x2)
+			}
+		}
+	}
+	{
+		var n1 int
+		if n1, err = r.Length(2); err != nil {
+			return r.Pos(), tinyserializer.WrapFieldError("strings", err)
+		}
+		v.Strings = make([]string, n1)
+		for i1 := range v.Strings {
+			{
+				var x2 string
+				if x2, err = r.String(); err != nil {
+					return r.Pos(), tinyserializer.WrapFieldError("strings", err)
+				}
+				v.Strings[i1] = x2
+			}
+		}
+	}
+	return r.Pos(), nil
+}
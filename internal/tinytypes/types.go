@@ -0,0 +1,45 @@
+// Package tinytypes holds the types the tinydump and tinyschema commands look up by the name passed to -type.
+//
+// The commands do not load plugins, and no types are registered in the installed commands.
+// To use them with your own types, add a file registering them to this package in a checkout of this module:
+//
+//	package tinytypes
+//
+//	import "example.com/app"
+//
+//	func init() {
+//		Register("app.Order", (*app.Order)(nil))
+//	}
+//
+// Then point the checkout at the module of your types, and install the commands from it:
+//
+//	go mod edit -require=example.com/app@v0.0.0 -replace=example.com/app=../app
+//	go install ./cmd/tinydump ./cmd/tinyschema
+package tinytypes
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Types holds the registered types by name
+var Types = map[string]interface{}{}
+
+// Register registers the type of v, which is usually a nil pointer, under the given name.
+// It panics if the name is already registered.
+func Register(name string, v interface{}) {
+	if _, ok := Types[name]; ok {
+		panic(fmt.Sprintf("tinytypes: type %s is already registered", name))
+	}
+	Types[name] = v
+}
+
+// Names returns the names of the registered types in order
+func Names() []string {
+	var names = make([]string, 0, len(Types))
+	for name := range Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
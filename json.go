@@ -0,0 +1,394 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// ToJSON converts serialized data to JSON, using a new serializer.
+// See Serializer.ToJSON.
+func ToJSON(data []byte, schema interface{}) ([]byte, error) {
+	return NewSerializer().ToJSON(data, schema)
+}
+
+// FromJSON converts JSON to serialized data, using a new serializer.
+// See Serializer.FromJSON.
+func FromJSON(jsonData []byte, schema interface{}) ([]byte, error) {
+	return NewSerializer().FromJSON(jsonData, schema)
+}
+
+// ToJSON deserializes the data as a value of the type of schema, and returns it as JSON.
+// schema is only used for its type, it can be a nil pointer.
+//
+// Structs are written as objects keyed by the tiny tag names of their fields, in the order they are serialized.
+// Omitempty fields are left out when they are empty, as they are in the payload.
+// Byte slices are written as base64 strings, complex numbers as [real, imag] arrays,
+// and maps as objects with their keys sorted. Only maps with string and integer keys can be converted.
+//
+// The data is decoded without validation and AfterDeserialize hooks, so payloads which fail to deserialize
+// for those reasons can still be inspected. Payloads of older versions are migrated to the type as usual.
+func (s *Serializer) ToJSON(data []byte, schema interface{}) ([]byte, error) {
+	var t = reflect.TypeOf(schema)
+	if t == nil {
+		return nil, errors.New("cannot convert data without a type")
+	}
+	var value = reflect.New(derefType(t))
+	s.inspecting = true
+	var err = s.Deserialize(data, value.Interface())
+	s.inspecting = false
+	if err != nil {
+		return nil, err
+	}
+	return appendJSON(nil, value.Elem())
+}
+
+// FromJSON parses JSON in the format written by ToJSON as a value of the type of schema, and serializes it.
+// schema is only used for its type, it can be a nil pointer.
+//
+// Fields missing from the JSON are left zero, unknown fields are rejected.
+func (s *Serializer) FromJSON(jsonData []byte, schema interface{}) ([]byte, error) {
+	var t = reflect.TypeOf(schema)
+	if t == nil {
+		return nil, errors.New("cannot convert data without a type")
+	}
+
+	var value = reflect.New(derefType(t))
+	if err := unmarshalJSON(jsonData, value.Elem()); err != nil {
+		return nil, err
+	}
+	return s.Serialize(value.Interface())
+}
+
+// unmarshalJSON parses JSON in the format written by appendJSON into value
+func unmarshalJSON(jsonData []byte, value reflect.Value) error {
+	var decoder = json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("invalid JSON: data after the top level value")
+	}
+	return setJSON(value, tree)
+}
+
+// appendJSON appends the value as JSON to dst
+func appendJSON(dst []byte, value reflect.Value) ([]byte, error) {
+	var err error
+	switch value.Kind() {
+	case reflect.Struct:
+		var info = getStructInfo(value.Type())
+		var start = len(dst)
+		dst = append(dst, '{')
+		for i := range info.fields {
+			var fi = &info.fields[i]
+			var field = value.Field(fi.index)
+			if fi.omitEmpty && field.IsZero() {
+				// Left out like in the payload, which also ends nil pointers back to the struct
+				continue
+			}
+			if len(dst) > start+1 {
+				dst = append(dst, ',')
+			}
+			dst = append(appendJSONString(dst, fi.name), ':')
+			if dst, err = appendJSON(dst, field); err != nil {
+				return nil, fmt.Errorf("field %s: %w", fi.name, err)
+			}
+		}
+		return append(dst, '}'), nil
+	case reflect.Ptr:
+		if value.IsNil() {
+			return appendJSON(dst, reflect.Zero(value.Type().Elem()))
+		}
+		return appendJSON(dst, value.Elem())
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			dst = append(dst, '"')
+			dst = append(dst, base64.StdEncoding.EncodeToString(value.Bytes())...)
+			return append(dst, '"'), nil
+		}
+		fallthrough
+	case reflect.Array:
+		dst = append(dst, '[')
+		for i := 0; i < value.Len(); i++ {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = appendJSON(dst, value.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, ']'), nil
+	case reflect.Map:
+		var keys = make([]string, 0, value.Len())
+		var elems = make(map[string]reflect.Value, value.Len())
+		var iter = value.MapRange()
+		for iter.Next() {
+			key, err := jsonKey(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			elems[key] = iter.Value()
+		}
+		sort.Strings(keys)
+
+		dst = append(dst, '{')
+		for i, key := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(appendJSONString(dst, key), ':')
+			if dst, err = appendJSON(dst, elems[key]); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, '}'), nil
+	case reflect.String:
+		return appendJSONString(dst, value.String()), nil
+	case reflect.Bool:
+		return strconv.AppendBool(dst, value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(dst, value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(dst, value.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return appendJSONFloat(dst, value.Float(), value.Type().Bits())
+	case reflect.Complex64, reflect.Complex128:
+		var c = value.Complex()
+		var bits = value.Type().Bits() / 2
+		dst = append(dst, '[')
+		if dst, err = appendJSONFloat(dst, real(c), bits); err != nil {
+			return nil, err
+		}
+		dst = append(dst, ',')
+		if dst, err = appendJSONFloat(dst, imag(c), bits); err != nil {
+			return nil, err
+		}
+		return append(dst, ']'), nil
+	default:
+		return nil, fmt.Errorf("cannot convert value of kind %s to JSON", value.Kind())
+	}
+}
+
+// appendJSONString appends the quoted string, without escaping HTML characters
+func appendJSONString(dst []byte, s string) []byte {
+	var b bytes.Buffer
+	var encoder = json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	// Encoding a string can not fail
+	_ = encoder.Encode(s)
+	return append(dst, bytes.TrimSuffix(b.Bytes(), []byte{'\n'})...)
+}
+
+func appendJSONFloat(dst []byte, f float64, bits int) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("cannot convert %v to JSON", f)
+	}
+	return strconv.AppendFloat(dst, f, 'g', -1, bits), nil
+}
+
+// jsonKey formats a map key as an object key
+func jsonKey(key reflect.Value) (string, error) {
+	switch key.Kind() {
+	case reflect.String:
+		return key.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("cannot convert map key of type %s to JSON", key.Type())
+}
+
+// setJSON sets value to the decoded JSON value, as parsed by encoding/json with numbers preserved
+func setJSON(value reflect.Value, tree interface{}) error {
+	if tree == nil {
+		// null leaves the value zero
+		return nil
+	}
+	var t = value.Type()
+	switch t.Kind() {
+	case reflect.Struct:
+		var object, ok = tree.(map[string]interface{})
+		if !ok {
+			return jsonTypeError(tree, t)
+		}
+		var info = getStructInfo(t)
+		for name, v := range object {
+			var fi = info.field(name)
+			if fi == nil {
+				return fmt.Errorf("unknown field %s in %s", name, t)
+			}
+			if err := setJSON(value.Field(fi.index), v); err != nil {
+				return fmt.Errorf("field %s: %w", name, err)
+			}
+		}
+		return nil
+	case reflect.Ptr:
+		if value.IsNil() {
+			value.Set(reflect.New(t.Elem()))
+		}
+		return setJSON(value.Elem(), tree)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			var str, ok = tree.(string)
+			if !ok {
+				return jsonTypeError(tree, t)
+			}
+			data, err := base64.StdEncoding.DecodeString(str)
+			if err != nil {
+				return fmt.Errorf("invalid base64 for %s: %w", t, err)
+			}
+			value.SetBytes(data)
+			return nil
+		}
+		var array, ok = tree.([]interface{})
+		if !ok {
+			return jsonTypeError(tree, t)
+		}
+		value.Set(reflect.MakeSlice(t, len(array), len(array)))
+		return setJSONElements(value, array)
+	case reflect.Array:
+		var array, ok = tree.([]interface{})
+		if !ok {
+			return jsonTypeError(tree, t)
+		}
+		if len(array) != value.Len() {
+			return fmt.Errorf("cannot convert array of length %d to %s", len(array), t)
+		}
+		return setJSONElements(value, array)
+	case reflect.Map:
+		var object, ok = tree.(map[string]interface{})
+		if !ok {
+			return jsonTypeError(tree, t)
+		}
+		value.Set(reflect.MakeMapWithSize(t, len(object)))
+		for k, v := range object {
+			var key = reflect.New(t.Key()).Elem()
+			if err := setJSONKey(key, k); err != nil {
+				return err
+			}
+			var elem = reflect.New(t.Elem()).Elem()
+			if err := setJSON(elem, v); err != nil {
+				return fmt.Errorf("key %s: %w", k, err)
+			}
+			value.SetMapIndex(key, elem)
+		}
+		return nil
+	case reflect.String:
+		var str, ok = tree.(string)
+		if !ok {
+			return jsonTypeError(tree, t)
+		}
+		value.SetString(str)
+		return nil
+	case reflect.Bool:
+		var b, ok = tree.(bool)
+		if !ok {
+			return jsonTypeError(tree, t)
+		}
+		value.SetBool(b)
+		return nil
+	case reflect.Complex64, reflect.Complex128:
+		var array, ok = tree.([]interface{})
+		if !ok || len(array) != 2 {
+			return jsonTypeError(tree, t)
+		}
+		var parts [2]float64
+		for i, part := range array {
+			var number, ok = part.(json.Number)
+			if !ok {
+				return jsonTypeError(tree, t)
+			}
+			f, err := strconv.ParseFloat(string(number), t.Bits()/2)
+			if err != nil {
+				return fmt.Errorf("cannot convert %s to %s: %w", number, t, err)
+			}
+			parts[i] = f
+		}
+		value.SetComplex(complex(parts[0], parts[1]))
+		return nil
+	}
+
+	var number, ok = tree.(json.Number)
+	if !ok {
+		return jsonTypeError(tree, t)
+	}
+	return setJSONNumber(value, string(number))
+}
+
+func setJSONElements(value reflect.Value, array []interface{}) error {
+	for i, v := range array {
+		if err := setJSON(value.Index(i), v); err != nil {
+			return fmt.Errorf("index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// setJSONKey parses an object key into a map key
+func setJSONKey(key reflect.Value, k string) error {
+	if key.Kind() == reflect.String {
+		key.SetString(k)
+		return nil
+	}
+	if err := setJSONNumber(key, k); err != nil {
+		return fmt.Errorf("invalid map key %q: %w", k, err)
+	}
+	return nil
+}
+
+// setJSONNumber parses the number into an integer or float value, checking it fits the type
+func setJSONNumber(value reflect.Value, number string) error {
+	var t = value.Type()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(number, 10, t.Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert %s to %s: %w", number, t, err)
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(number, 10, t.Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert %s to %s: %w", number, t, err)
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(number, t.Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert %s to %s: %w", number, t, err)
+		}
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot convert JSON to value of kind %s", t.Kind())
+	}
+	return nil
+}
+
+func jsonTypeError(tree interface{}, t reflect.Type) error {
+	var kind string
+	switch tree.(type) {
+	case map[string]interface{}:
+		kind = "object"
+	case []interface{}:
+		kind = "array"
+	case string:
+		kind = "string"
+	case bool:
+		kind = "bool"
+	default:
+		kind = "number"
+	}
+	return fmt.Errorf("cannot convert JSON %s to %s", kind, t)
+}
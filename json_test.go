@@ -0,0 +1,204 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type jsonStruct struct {
+	Int     int8            `tiny:"int"`
+	Uint    uint16          `tiny:"uint"`
+	Float   float32         `tiny:"float"`
+	Complex complex128      `tiny:"complex"`
+	String  string          `tiny:"string"`
+	Bytes   []byte          `tiny:"bytes"`
+	Ptr     *jsonInner      `tiny:"ptr"`
+	Array   [2]bool         `tiny:"array"`
+	ByID    map[int]string  `tiny:"byid"`
+	Nested  [][]uint64      `tiny:"nested"`
+	Packed  []int32         `tiny:"packed,packed=varint"`
+	Skipped string          `tiny:"-"`
+	Values  map[string]bool `tiny:"values"`
+}
+
+// Nil pointers are serialized as their zero value, so a struct can only point to its own type through an omitempty field
+type jsonInner struct {
+	String string     `tiny:"string"`
+	Ints   [][]uint64 `tiny:"ints"`
+}
+
+func TestToJSON(t *testing.T) {
+	var v = jsonStruct{
+		Int: -8, Uint: 16, Float: 1.5, Complex: complex(1, -2), String: "<tag> \"quoted\"",
+		Bytes: []byte("bytes"), Ptr: &jsonInner{String: "inner"}, Array: [2]bool{true, false},
+		ByID: map[int]string{10: "ten", 2: "two"}, Nested: [][]uint64{{1}, {}}, Packed: []int32{-1, 1},
+	}
+	// Canonical encoding makes the map order, and with that the data, reproducible
+	var s = NewSerializer().SetCanonical(true)
+	data, err := s.Serialize(&v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := s.ToJSON(data, (*jsonStruct)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected = `{"int":-8,"uint":16,"float":1.5,"complex":[1,-2],"string":"<tag> \"quoted\"","bytes":"Ynl0ZXM=",` +
+		`"ptr":{"string":"inner","ints":[]},"array":[true,false],"byid":{"10":"ten","2":"two"},"nested":[[1],[]],"packed":[-1,1],"values":{}}`
+	if string(out) != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, out)
+	}
+	if !json.Valid(out) {
+		t.Fatal("invalid JSON")
+	}
+
+	back, err := s.FromJSON(out, jsonStruct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back, data) {
+		t.Fatalf("expected FromJSON to reproduce the data\n%v\n%v", data, back)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	var s = NewSerializer().SetCompress(true).SetPacking(PackDelta)
+	data, err := s.Serialize(All_S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := s.ToJSON(data, All_S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := s.FromJSON(out, All_S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded AllStruct
+	if err = s.Deserialize(back, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, All_S) {
+		t.Fatalf("expected %+v, got %+v", All_S, decoded)
+	}
+}
+
+func TestJSONLinkedList(t *testing.T) {
+	data, err := NewSerializer().Serialize(&linkedList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ToJSON(data, (*listNode)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected = `{"name":"a","next":{"name":"b","next":{"name":"c"}}}`
+	if string(out) != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, out)
+	}
+	back, err := FromJSON(out, (*listNode)(nil))
+	if err != nil || !bytes.Equal(back, data) {
+		t.Fatalf("expected FromJSON to reproduce the data, got %v (%v)", back, err)
+	}
+}
+
+func TestToJSONInvalid(t *testing.T) {
+	var s = NewSerializer()
+	// Payloads which fail validation or AfterDeserialize can still be inspected
+	data, err := s.Serialize(&validateUser{Name: "root", Addresses: []validateAddress{{Number: -1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Deserialize(data, &validateUser{}); err == nil {
+		t.Fatal("expected the payload to fail validation")
+	}
+	out, err := s.ToJSON(data, (*validateUser)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected = `{"name":"root","addresses":[{"street":"","number":-1}],"contacts":{},"tags":[]}`
+	if string(out) != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, out)
+	}
+
+	if data, err = s.Serialize(&struct {
+		Price    int64 `tiny:"price"`
+		Quantity int64 `tiny:"quantity"`
+		Total    int64 `tiny:"total"`
+	}{Price: 1, Quantity: 2, Total: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Deserialize(data, &hookLine{}); err == nil {
+		t.Fatal("expected AfterDeserialize to fail")
+	}
+	if out, err = s.ToJSON(data, (*hookLine)(nil)); err != nil || string(out) != `{"price":1,"quantity":2,"total":3}` {
+		t.Fatalf("expected the line as JSON, got %s (%v)", out, err)
+	}
+	// The serializer validates again afterwards
+	if err = s.Deserialize(data, &hookLine{}); err == nil {
+		t.Fatal("expected AfterDeserialize to fail")
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	var tests = []struct {
+		json  string
+		error string
+	}{
+		{`{"int":128}`, "field int: cannot convert 128 to int8"},
+		{`{"uint":-1}`, "field uint: cannot convert -1 to uint16"},
+		{`{"int":1.5}`, "field int: cannot convert 1.5 to int8"},
+		{`{"string":1}`, "field string: cannot convert JSON number to string"},
+		{`{"bytes":"!"}`, "field bytes: invalid base64"},
+		{`{"array":[true]}`, "field array: cannot convert array of length 1 to [2]bool"},
+		{`{"byid":{"x":"y"}}`, `field byid: invalid map key "x"`},
+		{`{"ptr":{"ints":[[1,"a"]]}}`, "field ptr: field ints: index 0: index 1: cannot convert JSON string to uint64"},
+		{`{"missing":1}`, "unknown field missing"},
+		{`{"complex":[1]}`, "field complex: cannot convert JSON array to complex128"},
+		{`[]`, "cannot convert JSON array to tinyserializer.jsonStruct"},
+		{`{} {}`, "data after the top level value"},
+		{`{`, "invalid JSON"},
+	}
+	for _, test := range tests {
+		_, err := FromJSON([]byte(test.json), (*jsonStruct)(nil))
+		if err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("%s: expected error containing %q, got %v", test.json, test.error, err)
+		}
+	}
+
+	// Missing fields and null are left zero
+	data, err := FromJSON([]byte(`{"string":"only","ptr":null}`), (*jsonStruct)(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v jsonStruct
+	if err = NewSerializer().Deserialize(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.String != "only" || v.Int != 0 || v.Ptr.String != "" {
+		t.Fatalf("unexpected value %+v", v)
+	}
+}
+
+func TestToJSONErrors(t *testing.T) {
+	type floatKeys struct {
+		Map map[float64]int `tiny:"map"`
+	}
+	data, err := NewSerializer().Serialize(&floatKeys{Map: map[float64]int{1.5: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ToJSON(data, floatKeys{}); err == nil {
+		t.Fatal("expected error converting float map keys")
+	}
+	if _, err = ToJSON(data[:3], floatKeys{}); err == nil {
+		t.Fatal("expected error converting truncated data")
+	}
+	if _, err = ToJSON(data, nil); err == nil {
+		t.Fatal("expected error converting without a type")
+	}
+}
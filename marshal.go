@@ -0,0 +1,333 @@
+package tinyserializer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Marshaler is implemented by types which can serialize themselves without reflection,
+// usually through methods generated by cmd/tinygen.
+//
+// The output must be identical to the output of the reflective serializer.
+type Marshaler interface {
+	// MarshalTiny appends the serialized value to dst
+	MarshalTiny(dst []byte) ([]byte, error)
+}
+
+// Unmarshaler is implemented by types which can deserialize themselves without reflection,
+// usually through methods generated by cmd/tinygen.
+type Unmarshaler interface {
+	// UnmarshalTiny deserializes the value from the start of data,
+	// and returns the number of bytes it has read.
+	UnmarshalTiny(data []byte) (int, error)
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+// SetGenerated sets whether MarshalTiny and UnmarshalTiny methods are preferred over reflection.
+// This is enabled by default, disabling it is mostly useful to compare both implementations.
+//
+// Generated methods are only used with the default encoding options.
+func (s *Serializer) SetGenerated(generated bool) *Serializer {
+	s.noGenerated = !generated
+	return s
+}
+
+// generated reports whether generated methods can be used with the options of the serializer
+func (s *Serializer) generated() bool {
+	return !s.noGenerated && s.packing == PackNone && !s.canonical && !s.index
+}
+
+// useMarshaler reports whether the generated MarshalTiny method of the struct can be used
+func (s *Serializer) useMarshaler(info *structInfo, value reflect.Value) bool {
+	return info.marshaler && s.generated() && value.CanAddr() && !info.hasEncodeHooks()
+}
+
+// useUnmarshaler reports whether the generated UnmarshalTiny method of the struct can be used
+func (s *Serializer) useUnmarshaler(info *structInfo) bool {
+	return info.unmarshaler && s.generated() && !s.zeroCopy && !info.hasDecodeHooks()
+}
+
+// appendMarshaler appends the struct to dst with its MarshalTiny method
+func appendMarshaler(dst []byte, value reflect.Value) ([]byte, error) {
+	return value.Addr().Interface().(Marshaler).MarshalTiny(dst)
+}
+
+// decodeUnmarshaler decodes the struct with its UnmarshalTiny method
+func (s *Serializer) decodeUnmarshaler(value reflect.Value) error {
+	n, err := value.Addr().Interface().(Unmarshaler).UnmarshalTiny(s.data[s.pos:])
+	if err != nil {
+		return err
+	}
+	s.pos += n
+	return nil
+}
+
+// The functions below encode single values in the format of the serializer,
+// they are used by generated MarshalTiny methods.
+
+// AppendValue appends the serialized value v points to, using reflection.
+func AppendValue(dst []byte, v interface{}) ([]byte, error) {
+	var s Serializer
+	return s.appendValue(dst, reflect.ValueOf(v))
+}
+
+// AppendLength appends the length of a slice or map
+func AppendLength(dst []byte, n int) []byte {
+	return binary.LittleEndian.AppendUint32(dst, uint32(n))
+}
+
+// AppendPresence appends the byte before an omitempty field, which reports whether the field follows it
+func AppendPresence(dst []byte, present bool) []byte {
+	if present {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+// AppendString appends a string, which can be at most 65535 bytes long
+func AppendString(dst []byte, v string) ([]byte, error) {
+	if len(v) > math.MaxUint16 {
+		return nil, ErrFieldTooLarge
+	}
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(len(v)))
+	return append(dst, v...), nil
+}
+
+// AppendBytes appends a byte slice
+func AppendBytes(dst []byte, v []byte) []byte {
+	return appendBytes(dst, v)
+}
+
+// AppendBool appends a bool
+func AppendBool(dst []byte, v bool) []byte {
+	dst = binary.LittleEndian.AppendUint16(dst, 1)
+	if v {
+		return append(dst, 1)
+	}
+	return append(dst, 0)
+}
+
+// AppendInt appends an int
+func AppendInt(dst []byte, v int) []byte {
+	return appendUint(dst, uint64(v), intSize)
+}
+
+// AppendInt8 appends an int8
+func AppendInt8(dst []byte, v int8) []byte {
+	return appendUint(dst, uint64(v), 1)
+}
+
+// AppendInt16 appends an int16
+func AppendInt16(dst []byte, v int16) []byte {
+	return appendUint(dst, uint64(v), 2)
+}
+
+// AppendInt32 appends an int32
+func AppendInt32(dst []byte, v int32) []byte {
+	return appendUint(dst, uint64(v), 4)
+}
+
+// AppendInt64 appends an int64
+func AppendInt64(dst []byte, v int64) []byte {
+	return appendUint(dst, uint64(v), 8)
+}
+
+// AppendUint appends a uint
+func AppendUint(dst []byte, v uint) []byte {
+	return appendUint(dst, uint64(v), intSize)
+}
+
+// AppendUint8 appends a uint8
+func AppendUint8(dst []byte, v uint8) []byte {
+	return appendUint(dst, uint64(v), 1)
+}
+
+// AppendUint16 appends a uint16
+func AppendUint16(dst []byte, v uint16) []byte {
+	return appendUint(dst, uint64(v), 2)
+}
+
+// AppendUint32 appends a uint32
+func AppendUint32(dst []byte, v uint32) []byte {
+	return appendUint(dst, uint64(v), 4)
+}
+
+// AppendUint64 appends a uint64
+func AppendUint64(dst []byte, v uint64) []byte {
+	return appendUint(dst, v, 8)
+}
+
+// AppendUintptr appends a uintptr
+func AppendUintptr(dst []byte, v uintptr) []byte {
+	return appendUint(dst, uint64(v), ptrSize)
+}
+
+// AppendFloat32 appends a float32
+func AppendFloat32(dst []byte, v float32) []byte {
+	return appendUint(dst, uint64(math.Float32bits(v)), 4)
+}
+
+// AppendFloat64 appends a float64
+func AppendFloat64(dst []byte, v float64) []byte {
+	return appendUint(dst, math.Float64bits(v), 8)
+}
+
+// AppendComplex64 appends a complex64
+func AppendComplex64(dst []byte, v complex64) []byte {
+	dst = binary.LittleEndian.AppendUint16(dst, 8)
+	dst = binary.LittleEndian.AppendUint32(dst, math.Float32bits(real(v)))
+	return binary.LittleEndian.AppendUint32(dst, math.Float32bits(imag(v)))
+}
+
+// AppendComplex128 appends a complex128
+func AppendComplex128(dst []byte, v complex128) []byte {
+	dst = binary.LittleEndian.AppendUint16(dst, 16)
+	dst = binary.LittleEndian.AppendUint64(dst, math.Float64bits(real(v)))
+	return binary.LittleEndian.AppendUint64(dst, math.Float64bits(imag(v)))
+}
+
+// Sizes of int and uintptr, as written by the reflective serializer
+var (
+	intSize = int(reflect.TypeOf(int(0)).Size())
+	ptrSize = int(reflect.TypeOf(uintptr(0)).Size())
+)
+
+// EncodedSize returns the smallest number of bytes a value of the type v points to is encoded in.
+// Generated methods pass it to Reader.Length for elements they decode with reflection.
+func EncodedSize(v interface{}) int {
+	return encodedSize(reflect.TypeOf(v).Elem())
+}
+
+// IsZero reports whether the value v points to is the zero value of its type,
+// as used for omitempty fields.
+func IsZero(v interface{}) bool {
+	return reflect.ValueOf(v).Elem().IsZero()
+}
+
+// WrapFieldError annotates an error which occurred while deserializing the named field
+func WrapFieldError(name string, err error) error {
+	return fmt.Errorf("failed to deserialize field %s: %w", name, err)
+}
+
+// Reader reads single values in the format of the serializer,
+// it is used by generated UnmarshalTiny methods.
+type Reader struct {
+	r reader
+}
+
+// NewReader returns a reader reading from the start of data
+func NewReader(data []byte) Reader {
+	return Reader{r: reader{data: data}}
+}
+
+// Pos returns the number of bytes read so far
+func (r *Reader) Pos() int {
+	return r.r.pos
+}
+
+// Length reads the length of a slice or map.
+// Lengths whose elements of at least minElemSize bytes each can not fit the remaining data are rejected,
+// elements of zero bytes are limited like they are when decoding with reflection.
+func (r *Reader) Length(minElemSize int) (int, error) {
+	n, err := r.r.readLength()
+	if err == nil {
+		err = r.r.checkLength(n, minElemSize)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read length: %w", err)
+	}
+	return n, nil
+}
+
+// Presence reads the byte before an omitempty field, which reports whether the field follows it
+func (r *Reader) Presence() (bool, error) {
+	return r.r.readPresence()
+}
+
+// String reads a string
+func (r *Reader) String() (string, error) {
+	data, err := r.r.readScalar()
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Bytes reads a byte slice
+func (r *Reader) Bytes() ([]byte, error) {
+	n, err := r.r.readLength()
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.r.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return append(make([]byte, 0, n), data...), nil
+}
+
+// Bool reads a bool
+func (r *Reader) Bool() (bool, error) {
+	data, err := r.r.readScalar()
+	if err != nil {
+		return false, err
+	}
+	return scalarBool(data)
+}
+
+// Int reads a signed integer of any size
+func (r *Reader) Int() (int64, error) {
+	data, err := r.r.readScalar()
+	if err != nil {
+		return 0, err
+	}
+	return scalarInt(data)
+}
+
+// Uint reads an unsigned integer of any size
+func (r *Reader) Uint() (uint64, error) {
+	data, err := r.r.readScalar()
+	if err != nil {
+		return 0, err
+	}
+	return scalarUint(data)
+}
+
+// Float reads a float32 or float64
+func (r *Reader) Float() (float64, error) {
+	data, err := r.r.readScalar()
+	if err != nil {
+		return 0, err
+	}
+	return scalarFloat(data)
+}
+
+// Complex reads a complex64 or complex128
+func (r *Reader) Complex() (complex128, error) {
+	data, err := r.r.readScalar()
+	if err != nil {
+		return 0, err
+	}
+	return scalarComplex(data)
+}
+
+// Value reads the value v points to, using reflection
+func (r *Reader) Value(v interface{}) error {
+	var value = reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("data is not a pointer %s", value.Kind())
+	}
+	var s Serializer
+	s.reader = r.r
+	if err := s.decodeValue(value.Elem()); err != nil {
+		return err
+	}
+	r.r.pos = s.pos
+	return nil
+}
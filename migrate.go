@@ -0,0 +1,154 @@
+package tinyserializer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrVersionMismatch is returned when a payload of another version than the type it is read into can not be migrated
+var ErrVersionMismatch = errors.New("payload version does not match")
+
+// migration upgrades a value of the old type of a migration to the new type
+type migration struct {
+	old     reflect.Type
+	migrate func(old reflect.Value) reflect.Value
+}
+
+// migrations holds the registered versions of types, and the migrations to each type
+var migrations = struct {
+	sync.RWMutex
+	versions map[reflect.Type]uint32
+	to       map[reflect.Type]migration
+}{
+	versions: make(map[reflect.Type]uint32),
+	to:       make(map[reflect.Type]migration),
+}
+
+// RegisterMigration registers a function which upgrades values of type Old at fromVersion to type New at toVersion.
+// Old and New are the struct types of the versions, not pointers to them.
+//
+// With versioning enabled, payloads record the version of the serialized type.
+// Deserializing a payload of an older version decodes it as the type of that version,
+// and upgrades it through the registered migrations to the type it is read into:
+//
+//	tinyserializer.RegisterMigration(1, 2, func(old UserV1) UserV2 { ... })
+//	tinyserializer.RegisterMigration(2, 3, func(old UserV2) User { ... })
+//
+// Every type has a single version, and a single migration to it.
+// RegisterMigration panics if a registration conflicts with an earlier one, it is meant to be called from init functions.
+func RegisterMigration[Old, New any](fromVersion, toVersion uint32, migrate func(Old) New) {
+	var oldType, newType = reflect.TypeOf((*Old)(nil)).Elem(), reflect.TypeOf((*New)(nil)).Elem()
+	if fromVersion >= toVersion {
+		panic(fmt.Sprintf("tinyserializer: migration from %s to %s must increase the version, got %d to %d", oldType, newType, fromVersion, toVersion))
+	}
+	if oldType == newType {
+		panic(fmt.Sprintf("tinyserializer: migration of %s must be to another type", oldType))
+	}
+
+	migrations.Lock()
+	defer migrations.Unlock()
+	for t, version := range map[reflect.Type]uint32{oldType: fromVersion, newType: toVersion} {
+		if registered, ok := migrations.versions[t]; ok && registered != version {
+			panic(fmt.Sprintf("tinyserializer: %s is registered as version %d, not %d", t, registered, version))
+		}
+	}
+	if _, ok := migrations.to[newType]; ok {
+		panic(fmt.Sprintf("tinyserializer: a migration to %s is already registered", newType))
+	}
+	migrations.versions[oldType] = fromVersion
+	migrations.versions[newType] = toVersion
+	migrations.to[newType] = migration{
+		old: oldType,
+		migrate: func(old reflect.Value) reflect.Value {
+			return reflect.ValueOf(migrate(old.Interface().(Old)))
+		},
+	}
+}
+
+// SetVersioning sets whether the registered version of serialized types is written into the payload header,
+// and whether payloads of older versions are migrated when deserializing them. See RegisterMigration.
+//
+// Types without a registered version are written as version 0.
+// The reader must have versioning enabled as well, unless another option writing a header is enabled on both sides.
+func (s *Serializer) SetVersioning(versioning bool) *Serializer {
+	s.versioning = versioning
+	return s
+}
+
+// versionOf returns the registered version of the type, or 0
+func versionOf(t reflect.Type) uint32 {
+	migrations.RLock()
+	defer migrations.RUnlock()
+	return migrations.versions[derefType(t)]
+}
+
+// checkVersion checks the version in the header, if any, is the version of the type
+func checkVersion(h header, t reflect.Type) error {
+	if h.flags&flagVersion == 0 {
+		return nil
+	}
+	if version := versionOf(t); version != h.version {
+		return fmt.Errorf("%w: payload has version %d, %s has version %d", ErrVersionMismatch, h.version, derefType(t), version)
+	}
+	return nil
+}
+
+// migrationPath returns the migrations from the type of the version to the type t, in the order to apply them
+func migrationPath(version uint32, t reflect.Type) ([]migration, error) {
+	migrations.RLock()
+	defer migrations.RUnlock()
+
+	var path []migration
+	for current := derefType(t); migrations.versions[current] != version; {
+		m, ok := migrations.to[current]
+		if !ok || migrations.versions[current] < version {
+			return nil, fmt.Errorf("%w: no migration from version %d to %s", ErrVersionMismatch, version, derefType(t))
+		}
+		path = append(path, m)
+		current = m.old
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+// deserializeMigrated decodes the payload as the type of its version, and migrates it into out
+func (s *Serializer) deserializeMigrated(payload []byte, h header, out interface{}) error {
+	var value = reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("data is not a pointer %s", value.Kind())
+	}
+	path, err := migrationPath(h.version, value.Type())
+	if err != nil {
+		return err
+	}
+
+	var old = reflect.New(path[0].old)
+	if err = checkFingerprint(h, old.Type()); err != nil {
+		return err
+	}
+	if err = s.deserialize(payload, old.Interface()); err != nil {
+		return err
+	}
+	var migrated = old.Elem()
+	for _, m := range path {
+		migrated = m.migrate(migrated)
+	}
+
+	var elem = value.Elem()
+	for elem.Kind() == reflect.Ptr {
+		if elem.IsNil() {
+			elem.Set(reflect.New(elem.Type().Elem()))
+		}
+		elem = elem.Elem()
+	}
+	elem.Set(migrated)
+	// The old value was decoded as the old type, the top level value is validated and finished again as the new type
+	if elem.Kind() == reflect.Struct && !s.inspecting {
+		return decodedStruct(elem, getStructInfo(elem.Type()))
+	}
+	return nil
+}
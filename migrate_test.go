@@ -0,0 +1,142 @@
+package tinyserializer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type migrateUserV1 struct {
+	Name string `tiny:"name"`
+	Age  int32  `tiny:"age"`
+}
+
+type migrateUserV2 struct {
+	First string `tiny:"first"`
+	Last  string `tiny:"last"`
+	Age   int32  `tiny:"age"`
+}
+
+type migrateUser struct {
+	First  string   `tiny:"first"`
+	Last   string   `tiny:"last"`
+	Age    int64    `tiny:"age"`
+	Emails []string `tiny:"emails"`
+}
+
+func init() {
+	RegisterMigration(1, 2, func(old migrateUserV1) migrateUserV2 {
+		first, last, _ := strings.Cut(old.Name, " ")
+		return migrateUserV2{First: first, Last: last, Age: old.Age}
+	})
+	RegisterMigration(2, 3, func(old migrateUserV2) migrateUser {
+		return migrateUser{First: old.First, Last: old.Last, Age: int64(old.Age)}
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	var s = NewSerializer().SetVersioning(true)
+	var expected = migrateUser{First: "Ada", Last: "Lovelace", Age: 36}
+
+	for _, old := range []interface{}{
+		&migrateUserV1{Name: "Ada Lovelace", Age: 36},
+		&migrateUserV2{First: "Ada", Last: "Lovelace", Age: 36},
+	} {
+		data, err := s.Serialize(old)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var user = migrateUser{Emails: []string{"overwritten"}}
+		if err = s.Deserialize(data, &user); err != nil {
+			t.Fatalf("%T: %v", old, err)
+		}
+		if user.First != expected.First || user.Last != expected.Last || user.Age != expected.Age || user.Emails != nil {
+			t.Fatalf("%T: expected %+v, got %+v", old, expected, user)
+		}
+
+		// Migrating into a pointer to a pointer allocates the value
+		var ptr *migrateUser
+		if err = s.Deserialize(data, &ptr); err != nil || ptr == nil || ptr.First != "Ada" {
+			t.Fatalf("%T: expected migrated pointer, got %+v, %v", old, ptr, err)
+		}
+	}
+
+	// Intermediate versions can be read as well
+	data, err := s.Serialize(&migrateUserV1{Name: "Grace Hopper", Age: 85})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v2 migrateUserV2
+	if err = s.Deserialize(data, &v2); err != nil || v2.First != "Grace" || v2.Last != "Hopper" {
+		t.Fatalf("expected migrated v2, got %+v, %v", v2, err)
+	}
+
+	// The current version is decoded directly
+	data, err = s.Serialize(&migrateUser{First: "Alan", Emails: []string{"alan@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var user migrateUser
+	if err = s.Deserialize(data, &user); err != nil || len(user.Emails) != 1 {
+		t.Fatalf("expected current version, got %+v, %v", user, err)
+	}
+
+	// Payloads can not be migrated to older versions, or to unrelated types
+	if err = s.Deserialize(data, &v2); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch for a newer payload, got %v", err)
+	}
+	var unrelated struct {
+		First string `tiny:"first"`
+	}
+	if err = s.Deserialize(data, &unrelated); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch for an unrelated type, got %v", err)
+	}
+}
+
+func TestMigrationsWithHeaderOptions(t *testing.T) {
+	var s = NewSerializer().SetVersioning(true).SetFingerprint(true).SetCompress(true)
+	s.SetCompressThreshold(0)
+	data, err := s.Serialize(&migrateUserV1{Name: "Ada Lovelace", Age: 36})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var user migrateUser
+	if err = s.Deserialize(data, &user); err != nil || user.Last != "Lovelace" {
+		t.Fatalf("expected migrated user, got %+v, %v", user, err)
+	}
+
+	// Views and projections do not migrate
+	if err = s.View(data, &user).Err(); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch from View, got %v", err)
+	}
+	if err = s.DeserializeFields(data, &user, "first"); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch from DeserializeFields, got %v", err)
+	}
+
+	// A reader with versioning expects payloads to start with a header
+	data, err = NewSerializer().Serialize(&migrateUserV1{Name: "Ada Lovelace", Age: 36})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.SetCompress(false).SetFingerprint(false).Deserialize(data, &user); !errors.Is(err, ErrInvalidHeader) {
+		t.Fatalf("expected ErrInvalidHeader for a payload without header, got %v", err)
+	}
+}
+
+func TestRegisterMigrationConflicts(t *testing.T) {
+	type a struct{ A int32 }
+	type b struct{ B int32 }
+	var expectPanic = func(name string, register func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("%s: expected a panic", name)
+			}
+		}()
+		register()
+	}
+	expectPanic("decreasing version", func() { RegisterMigration(2, 1, func(a) b { return b{} }) })
+	expectPanic("same type", func() { RegisterMigration(1, 2, func(v a) a { return v }) })
+	expectPanic("version conflict", func() { RegisterMigration(5, 6, func(migrateUserV1) b { return b{} }) })
+	expectPanic("second migration", func() { RegisterMigration(1, 3, func(a) migrateUser { return migrateUser{} }) })
+}
@@ -0,0 +1,451 @@
+package tinyserializer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+// Packing selects how slices of numbers and bools are encoded.
+//
+// Packed slices are written as a single block instead of a size prefixed value per element:
+//
+//	[length (4 bytes)][mode (1 byte)][elements]
+//
+// Bools are always packed as bits, and floats are always packed as raw little endian values.
+// The mode is stored in the block, so the reader only needs to know the slice is packed.
+type Packing uint8
+
+const (
+	// PackNone writes every element as a separate value, this is the default
+	PackNone Packing = iota
+	// PackRaw writes the elements as fixed size little endian values
+	PackRaw
+	// PackVarint writes integers as variable length values, small numbers take up a single byte
+	PackVarint
+	// PackDelta writes integers as the variable length difference to the previous element,
+	// which is the smallest encoding for sorted data
+	PackDelta
+)
+
+func (p Packing) String() string {
+	switch p {
+	case PackNone:
+		return "none"
+	case PackRaw:
+		return "raw"
+	case PackVarint:
+		return "varint"
+	case PackDelta:
+		return "delta"
+	}
+	return fmt.Sprintf("Packing(%d)", uint8(p))
+}
+
+// Modes of a packed block, as stored on the wire
+const (
+	packModeRaw byte = iota
+	packModeVarint
+	packModeDelta
+	packModeBits
+)
+
+// SetPacking sets how all slices of numbers and bools are encoded.
+// Fields can choose their own packing with the packed tag option:
+//
+//	IDs []int64 `tiny:"ids,packed=delta"`
+//
+// Where packed is the same as packed=raw. The reader must use the same setting as the writer.
+// []byte is always written as a single run of bytes.
+func (s *Serializer) SetPacking(packing Packing) *Serializer {
+	s.packing = packing
+	return s
+}
+
+// parsePacking parses the value of the packed tag option, unknown values pack raw
+func parsePacking(option string) Packing {
+	switch strings.TrimPrefix(option, "packed=") {
+	case "varint":
+		return PackVarint
+	case "delta":
+		return PackDelta
+	}
+	return PackRaw
+}
+
+// packable reports whether the type is a slice which can be packed
+func packable(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice {
+		return false
+	}
+	switch t.Elem().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		return true
+	}
+	return false
+}
+
+// Kinds of packed elements
+const (
+	packedInt = iota
+	packedUint
+	packedFloat
+	packedBool
+)
+
+// packedElem describes the elements of a packed slice
+type packedElem struct {
+	kind int
+	size int
+}
+
+func packedElemOf(t reflect.Type) packedElem {
+	var elem = packedElem{size: int(t.Size())}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		elem.kind = packedInt
+	case reflect.Float32, reflect.Float64:
+		elem.kind = packedFloat
+	case reflect.Bool:
+		elem.kind = packedBool
+	default:
+		elem.kind = packedUint
+	}
+	return elem
+}
+
+// mode returns the mode the elements are written in with the given packing
+func (e packedElem) mode(packing Packing) byte {
+	switch {
+	case e.kind == packedBool:
+		return packModeBits
+	case e.kind == packedFloat:
+		return packModeRaw
+	case packing == PackVarint:
+		return packModeVarint
+	case packing == PackDelta:
+		return packModeDelta
+	}
+	return packModeRaw
+}
+
+// appendPackedBlock appends n elements as a packed block.
+// Element i is returned by at as an integer, the bits of a float, or 1 for true.
+func appendPackedBlock(dst []byte, n int, elem packedElem, packing Packing, at func(i int) uint64) []byte {
+	var mode = elem.mode(packing)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(n))
+	dst = append(dst, mode)
+	switch mode {
+	case packModeBits:
+		var b byte
+		for i := 0; i < n; i++ {
+			if at(i) != 0 {
+				b |= 1 << (i % 8)
+			}
+			if i%8 == 7 || i == n-1 {
+				dst = append(dst, b)
+				b = 0
+			}
+		}
+	case packModeVarint:
+		for i := 0; i < n; i++ {
+			if elem.kind == packedInt {
+				dst = binary.AppendVarint(dst, int64(at(i)))
+			} else {
+				dst = binary.AppendUvarint(dst, at(i))
+			}
+		}
+	case packModeDelta:
+		var prev uint64
+		for i := 0; i < n; i++ {
+			var v = at(i)
+			dst = binary.AppendVarint(dst, int64(v-prev))
+			prev = v
+		}
+	default:
+		// Raw blocks record the element size, so ints written on 64 bit platforms can be read on 32 bit ones
+		dst = append(dst, byte(elem.size))
+		for i := 0; i < n; i++ {
+			switch elem.size {
+			case 1:
+				dst = append(dst, byte(at(i)))
+			case 2:
+				dst = binary.LittleEndian.AppendUint16(dst, uint16(at(i)))
+			case 4:
+				dst = binary.LittleEndian.AppendUint32(dst, uint32(at(i)))
+			default:
+				dst = binary.LittleEndian.AppendUint64(dst, at(i))
+			}
+		}
+	}
+	return dst
+}
+
+// packedBlock is the header of a packed block
+type packedBlock struct {
+	n    int
+	mode byte
+	size int
+}
+
+// readPackedBlock reads the header of a packed block, and checks it can hold the elements
+func (s *reader) readPackedBlock(elem packedElem) (packedBlock, error) {
+	var block packedBlock
+	n, err := s.readLength()
+	if err != nil {
+		return block, err
+	}
+	header, err := s.read(1)
+	if err != nil {
+		return block, err
+	}
+	block.n, block.mode = n, header[0]
+
+	var minSize = 1
+	switch block.mode {
+	case packModeBits:
+		if elem.kind != packedBool {
+			return block, fmt.Errorf("invalid packing mode %d for %s", block.mode, elem)
+		}
+		// Eight elements are stored per byte
+		minSize = 0
+		if (n+7)/8 > len(s.data)-s.pos {
+			return block, io.ErrUnexpectedEOF
+		}
+	case packModeVarint, packModeDelta:
+		if elem.kind != packedInt && elem.kind != packedUint {
+			return block, fmt.Errorf("invalid packing mode %d for %s", block.mode, elem)
+		}
+	case packModeRaw:
+		size, err := s.read(1)
+		if err != nil {
+			return block, err
+		}
+		block.size = int(size[0])
+		if !elem.validSize(block.size) {
+			return block, fmt.Errorf("invalid size %d for packed %s", block.size, elem)
+		}
+		minSize = block.size
+	default:
+		return block, fmt.Errorf("invalid packing mode %d", block.mode)
+	}
+	if minSize > 0 && n > (len(s.data)-s.pos)/minSize {
+		return block, io.ErrUnexpectedEOF
+	}
+	return block, nil
+}
+
+// validSize reports whether raw elements of the given size can be read into the element type
+func (e packedElem) validSize(size int) bool {
+	switch e.kind {
+	case packedFloat:
+		return size == 4 || size == 8
+	case packedInt, packedUint:
+		return size == 1 || size == 2 || size == 4 || size == 8
+	}
+	return false
+}
+
+func (e packedElem) String() string {
+	switch e.kind {
+	case packedInt:
+		return "int"
+	case packedUint:
+		return "uint"
+	case packedFloat:
+		return "float"
+	}
+	return "bool"
+}
+
+// readPackedValues reads the elements of the block, passing them to set.
+// Floats are passed as the bits of a float64.
+func (s *reader) readPackedValues(block packedBlock, elem packedElem, set func(i int, v uint64)) error {
+	switch block.mode {
+	case packModeBits:
+		var data, _ = s.read((block.n + 7) / 8)
+		for i := 0; i < block.n; i++ {
+			set(i, uint64(data[i/8]>>(i%8)&1))
+		}
+	case packModeVarint, packModeDelta:
+		var prev uint64
+		for i := 0; i < block.n; i++ {
+			var v uint64
+			var n int
+			if elem.kind == packedInt || block.mode == packModeDelta {
+				var x int64
+				x, n = binary.Varint(s.data[s.pos:])
+				v = uint64(x)
+			} else {
+				v, n = binary.Uvarint(s.data[s.pos:])
+			}
+			if n <= 0 {
+				return fmt.Errorf("invalid varint: %w", io.ErrUnexpectedEOF)
+			}
+			s.pos += n
+			if block.mode == packModeDelta {
+				v += prev
+				prev = v
+			}
+			set(i, v)
+		}
+	default:
+		var data, _ = s.read(block.n * block.size)
+		for i := 0; i < block.n; i++ {
+			var v = decodeUint(data[i*block.size : (i+1)*block.size])
+			switch {
+			case elem.kind == packedInt:
+				v = uint64(signExtend(v, block.size))
+			case elem.kind == packedFloat && block.size == 4:
+				v = math.Float64bits(float64(math.Float32frombits(uint32(v))))
+			}
+			set(i, v)
+		}
+	}
+	return nil
+}
+
+// appendPacked appends the slice as a packed block, with floats normalized if canonical is set
+func appendPacked(dst []byte, value reflect.Value, packing Packing, canonical bool) []byte {
+	var elem = packedElemOf(value.Type().Elem())
+	var at func(i int) uint64
+	switch {
+	case elem.kind == packedInt:
+		at = func(i int) uint64 { return uint64(value.Index(i).Int()) }
+	case elem.kind == packedUint:
+		at = func(i int) uint64 { return value.Index(i).Uint() }
+	case elem.kind == packedFloat && elem.size == 4 && canonical:
+		at = func(i int) uint64 { return uint64(canonicalFloat32(float32(value.Index(i).Float()))) }
+	case elem.kind == packedFloat && canonical:
+		at = func(i int) uint64 { return canonicalFloat64(value.Index(i).Float()) }
+	case elem.kind == packedFloat && elem.size == 4:
+		at = func(i int) uint64 { return uint64(math.Float32bits(float32(value.Index(i).Float()))) }
+	case elem.kind == packedFloat:
+		at = func(i int) uint64 { return math.Float64bits(value.Index(i).Float()) }
+	default:
+		at = func(i int) uint64 {
+			if value.Index(i).Bool() {
+				return 1
+			}
+			return 0
+		}
+	}
+	return appendPackedBlock(dst, value.Len(), elem, packing, at)
+}
+
+// decodePacked decodes a packed block into the slice
+func (s *Serializer) decodePacked(value reflect.Value) error {
+	var elem = packedElemOf(value.Type().Elem())
+	block, err := s.readPackedBlock(elem)
+	if err != nil {
+		return fmt.Errorf("failed to read packed slice: %w", err)
+	}
+
+	var slice = reflect.MakeSlice(value.Type(), block.n, block.n)
+	var set func(i int, v uint64)
+	switch elem.kind {
+	case packedInt:
+		set = func(i int, v uint64) { slice.Index(i).SetInt(int64(v)) }
+	case packedUint:
+		set = func(i int, v uint64) { slice.Index(i).SetUint(v) }
+	case packedFloat:
+		set = func(i int, v uint64) { slice.Index(i).SetFloat(math.Float64frombits(v)) }
+	default:
+		set = func(i int, v uint64) { slice.Index(i).SetBool(v != 0) }
+	}
+	if err = s.readPackedValues(block, elem, set); err != nil {
+		return fmt.Errorf("failed to read packed slice: %w", err)
+	}
+	value.Set(slice)
+	return nil
+}
+
+// Integer is the constraint of integer types which can be packed
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Float is the constraint of float types which can be packed
+type Float interface {
+	~float32 | ~float64
+}
+
+// The functions below encode packed slices without reflection,
+// they are used by generated methods for fields with the packed tag option.
+
+// AppendPackedInts appends a slice of integers as a packed block
+func AppendPackedInts[T Integer](dst []byte, v []T, packing Packing) []byte {
+	var elem = integerElem[T]()
+	return appendPackedBlock(dst, len(v), elem, packing, func(i int) uint64 { return uint64(v[i]) })
+}
+
+// AppendPackedFloats appends a slice of floats as a packed block
+func AppendPackedFloats[T Float](dst []byte, v []T) []byte {
+	var elem = packedElem{kind: packedFloat, size: int(unsafe.Sizeof(T(0)))}
+	if elem.size == 4 {
+		return appendPackedBlock(dst, len(v), elem, PackRaw, func(i int) uint64 { return uint64(math.Float32bits(float32(v[i]))) })
+	}
+	return appendPackedBlock(dst, len(v), elem, PackRaw, func(i int) uint64 { return math.Float64bits(float64(v[i])) })
+}
+
+// AppendPackedBools appends a slice of bools as a packed block of bits
+func AppendPackedBools[T ~bool](dst []byte, v []T) []byte {
+	return appendPackedBlock(dst, len(v), packedElem{kind: packedBool, size: 1}, PackRaw, func(i int) uint64 {
+		if v[i] {
+			return 1
+		}
+		return 0
+	})
+}
+
+// ReadPackedInts reads a packed block of integers
+func ReadPackedInts[T Integer](r *Reader) ([]T, error) {
+	var elem = integerElem[T]()
+	block, err := r.r.readPackedBlock(elem)
+	if err != nil {
+		return nil, err
+	}
+	var v = make([]T, block.n)
+	err = r.r.readPackedValues(block, elem, func(i int, x uint64) { v[i] = T(x) })
+	return v, err
+}
+
+// ReadPackedFloats reads a packed block of floats
+func ReadPackedFloats[T Float](r *Reader) ([]T, error) {
+	var elem = packedElem{kind: packedFloat, size: int(unsafe.Sizeof(T(0)))}
+	block, err := r.r.readPackedBlock(elem)
+	if err != nil {
+		return nil, err
+	}
+	var v = make([]T, block.n)
+	err = r.r.readPackedValues(block, elem, func(i int, x uint64) { v[i] = T(math.Float64frombits(x)) })
+	return v, err
+}
+
+// ReadPackedBools reads a packed block of bools
+func ReadPackedBools[T ~bool](r *Reader) ([]T, error) {
+	var elem = packedElem{kind: packedBool, size: 1}
+	block, err := r.r.readPackedBlock(elem)
+	if err != nil {
+		return nil, err
+	}
+	var v = make([]T, block.n)
+	err = r.r.readPackedValues(block, elem, func(i int, x uint64) { v[i] = x != 0 })
+	return v, err
+}
+
+// integerElem returns the packed element of the integer type
+func integerElem[T Integer]() packedElem {
+	var elem = packedElem{kind: packedUint, size: int(unsafe.Sizeof(T(0)))}
+	if ^T(0) < 0 {
+		elem.kind = packedInt
+	}
+	return elem
+}
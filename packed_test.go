@@ -0,0 +1,193 @@
+package tinyserializer
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type PackedStruct struct {
+	Raw     []int64   `tiny:"raw,packed"`
+	Varint  []int32   `tiny:"varint,packed=varint"`
+	Delta   []uint64  `tiny:"delta,packed=delta"`
+	Floats  []float64 `tiny:"floats,packed"`
+	Bools   []bool    `tiny:"bools,packed"`
+	Strings []string  `tiny:"strings,packed"`
+}
+
+func TestPackedTags(t *testing.T) {
+	var packed = PackedStruct{
+		Raw:     []int64{-1, 0, 1 << 40},
+		Varint:  []int32{-300, 0, 300},
+		Delta:   []uint64{100, 101, 105, 1 << 63},
+		Floats:  []float64{1.5, -2.25},
+		Bools:   []bool{true, false, true, true, false, false, false, false, true},
+		Strings: []string{"not", "packable"},
+	}
+
+	var s = NewSerializer()
+	serialized, err := s.Serialize(&packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deserialized PackedStruct
+	if err = s.Deserialize(serialized, &deserialized); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(packed, deserialized) {
+		t.Fatalf("expected %+v, got %+v", packed, deserialized)
+	}
+}
+
+func TestSetPacking(t *testing.T) {
+	unpacked, err := NewSerializer().Serialize(&BasicIntList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 4 byte length, followed by a 2 byte size and 8 bytes for every element
+	if len(unpacked) != 4+10*(2+8) {
+		t.Fatalf("expected %d bytes, got %d", 4+10*(2+8), len(unpacked))
+	}
+
+	var sizes = map[Packing]int{
+		// Length, mode and element size, followed by 8 bytes for every element
+		PackRaw: 4 + 1 + 1 + 10*8,
+		// Length and mode, followed by a byte for every element
+		PackVarint: 4 + 1 + 10,
+		PackDelta:  4 + 1 + 10,
+	}
+	for packing, size := range sizes {
+		var s = NewSerializer().SetPacking(packing)
+		var value = Structie{
+			IntList:         BasicIntList,
+			EmbeddedintList: BasicEmbeddedIntList,
+			EmbeddedstrList: [][]string{BasicStringList},
+			DoubleEmbedded:  BasicDoubleEmbedded,
+		}
+		serialized, err := s.AppendSerialize(nil, &BasicIntList)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(serialized) != size {
+			t.Fatalf("packing %d: expected %d bytes, got %d", packing, size, len(serialized))
+		}
+
+		// Nested slices are packed as well
+		serialized, err = s.AppendSerialize(nil, &value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var deserialized Structie
+		if err = s.Deserialize(serialized, &deserialized); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(value, deserialized) {
+			t.Fatalf("packing %d: expected %+v, got %+v", packing, value, deserialized)
+		}
+	}
+}
+
+func TestPackedGeneric(t *testing.T) {
+	type Level int8
+	var levels = []Level{-128, -1, 0, 127}
+	for _, packing := range []Packing{PackRaw, PackVarint, PackDelta} {
+		var data = AppendPackedInts(nil, levels, packing)
+		var r = NewReader(data)
+		decoded, err := ReadPackedInts[Level](&r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(levels, decoded) || r.Pos() != len(data) {
+			t.Fatalf("packing %d: expected %v, got %v", packing, levels, decoded)
+		}
+
+		// The reflective serializer reads the same block
+		var reflective []Level
+		if err = NewSerializer().SetPacking(packing).Deserialize(data, &reflective); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(levels, reflective) {
+			t.Fatalf("packing %d: expected %v, got %v", packing, levels, reflective)
+		}
+	}
+
+	var floats = []float32{1.5, -0.25}
+	var r = NewReader(AppendPackedFloats(nil, floats))
+	decodedFloats, err := ReadPackedFloats[float32](&r)
+	if err != nil || !reflect.DeepEqual(floats, decodedFloats) {
+		t.Fatalf("expected %v, got %v (%v)", floats, decodedFloats, err)
+	}
+
+	var bools = []bool{true, false, true}
+	r = NewReader(AppendPackedBools(nil, bools))
+	decodedBools, err := ReadPackedBools[bool](&r)
+	if err != nil || !reflect.DeepEqual(bools, decodedBools) {
+		t.Fatalf("expected %v, got %v (%v)", bools, decodedBools, err)
+	}
+}
+
+func TestPackedRawWidening(t *testing.T) {
+	// Raw blocks of smaller ints, as written for int on 32 bit platforms, keep their sign
+	var narrow = []int32{-1, -300, 0, 1 << 30, -1 << 31}
+	var data = AppendPackedInts(nil, narrow, PackRaw)
+	var wide []int64
+	if err := NewSerializer().SetPacking(PackRaw).Deserialize(data, &wide); err != nil {
+		t.Fatal(err)
+	}
+	var r = NewReader(data)
+	generic, err := ReadPackedInts[int64](&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range narrow {
+		if wide[i] != int64(v) || generic[i] != int64(v) {
+			t.Fatalf("expected %d at %d, got %d and %d", v, i, wide[i], generic[i])
+		}
+	}
+
+	// Unsigned values are not extended
+	var unsigned []uint64
+	if err = NewSerializer().SetPacking(PackRaw).Deserialize(AppendPackedInts(nil, []uint16{0xffff}, PackRaw), &unsigned); err != nil || unsigned[0] != 0xffff {
+		t.Fatalf("expected 65535, got %v (%v)", unsigned, err)
+	}
+
+	// The same holds for single ints
+	data, err = NewSerializer().AppendSerialize(nil, &struct {
+		V int32 `tiny:"v"`
+	}{-5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var single struct {
+		V int64 `tiny:"v"`
+	}
+	if err = NewSerializer().Deserialize(data, &single); err != nil || single.V != -5 {
+		t.Fatalf("expected -5, got %d (%v)", single.V, err)
+	}
+}
+
+func TestPackedInvalid(t *testing.T) {
+	var s = NewSerializer().SetPacking(PackVarint)
+	serialized, err := s.AppendSerialize(nil, &BasicIntList)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deserialized []int64
+	if err = s.Deserialize(serialized[:len(serialized)-1], &deserialized); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+
+	// Varint blocks can not be read into floats
+	var floats []float64
+	if err = s.Deserialize(serialized, &floats); err == nil {
+		t.Fatal("expected error decoding varints into floats")
+	}
+
+	// Huge lengths are rejected before allocating
+	var huge = append([]byte{0xff, 0xff, 0xff, 0xff}, serialized[4:]...)
+	if err = s.Deserialize(huge, &deserialized); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+}
@@ -0,0 +1,269 @@
+package tinyserializer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DeserializeFields deserializes only the fields at the given paths into out, which must point to a struct.
+//
+// Paths are the tag names of the fields, separated by dots to select fields of nested structs:
+//
+//	s.DeserializeFields(data, &record, "name", "all.mapint")
+//
+// Other fields are skipped without being allocated, and are left untouched in out.
+// Requested fields which are missing from the end of the payload are set to their defaults.
+// Decoding stops as soon as all requested fields have been read.
+func (s *Serializer) DeserializeFields(data []byte, out interface{}, paths ...string) error {
+	var value = reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("data is not a pointer %s", value.Kind())
+	}
+	projection, err := newProjection(value.Type(), paths)
+	if err != nil {
+		return err
+	}
+	if data, err = s.openPayload(data, s.zeroCopy); err != nil {
+		return err
+	}
+
+	if s.headered() {
+		if s.zeroCopy {
+			s.decompressBuffer = nil
+		}
+		h, payload, err := s.readPayload(data)
+		if err == nil {
+			err = checkVersion(h, value.Type())
+		}
+		if err == nil {
+			err = checkFingerprint(h, value.Type())
+		}
+		if err != nil {
+			return err
+		}
+		data = payload
+	}
+
+	s.data, s.pos = data, 0
+	err = s.decodeProjection(value.Elem(), projection, true)
+	s.data = nil
+	return err
+}
+
+// projection holds the requested fields of a struct, by tag name.
+// Fields which are requested completely map to nil.
+type projection map[string]projection
+
+// newProjection builds the projection of the paths, and checks they exist in the type
+func newProjection(t reflect.Type, paths []string) (projection, error) {
+	var root = make(projection)
+	for _, path := range paths {
+		var p, typ = root, t
+		var names = strings.Split(path, ".")
+		for i, name := range names {
+			for typ.Kind() == reflect.Ptr {
+				typ = typ.Elem()
+			}
+			if typ.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("invalid path %q: %s is not a struct", path, typ)
+			}
+			var fi = getStructInfo(typ).field(name)
+			if fi == nil {
+				return nil, fmt.Errorf("invalid path %q: unknown field %s in %s", path, name, typ)
+			}
+			sub, ok := p[name]
+			if ok && sub == nil {
+				// The whole field is already requested
+				break
+			}
+			if i == len(names)-1 {
+				p[name] = nil
+				break
+			}
+			if !ok {
+				sub = make(projection)
+				p[name] = sub
+			}
+			p, typ = sub, fi.typ
+		}
+	}
+	return root, nil
+}
+
+// decodeProjection decodes the projected fields of the struct value points to, and skips the others.
+// If last is set nothing is read after the struct, so decoding stops after the last projected field.
+func (s *Serializer) decodeProjection(value reflect.Value, p projection, last bool) error {
+	if len(p) == 0 && last {
+		return nil
+	}
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+
+	var remaining = len(p)
+	var info = getStructInfo(value.Type())
+	if info.err != nil {
+		return info.err
+	}
+	for i := 0; i < len(info.fields) && (remaining > 0 || !last); i++ {
+		if s.pos == len(s.data) && i >= info.optionalFrom && s.elements == 0 {
+			setDefaults(value, info, i, p)
+			return nil
+		}
+		var fi = &info.fields[i]
+		var field = value.Field(fi.index)
+		if fi.omitEmpty {
+			present, err := s.readPresence()
+			if err != nil {
+				return WrapFieldError(fi.name, err)
+			}
+			if !present {
+				if _, ok := p[fi.name]; ok {
+					field.Set(reflect.Zero(fi.typ))
+					remaining--
+				}
+				continue
+			}
+		}
+
+		var err error
+		sub, ok := p[fi.name]
+		switch {
+		case !ok:
+			err = s.skipField(field, fi)
+		case sub == nil:
+			err = s.decodeField(field, fi)
+		default:
+			err = s.decodeProjection(field, sub, false)
+		}
+		if ok {
+			remaining--
+		}
+		if err != nil {
+			if verr, ok := err.(*ValidationError); ok {
+				return verr.in(fi.name)
+			}
+			return WrapFieldError(fi.name, err)
+		}
+	}
+	return nil
+}
+
+// skipField skips over the value of a struct field
+func (s *Serializer) skipField(field reflect.Value, fi *fieldInfo) error {
+	if fi.packing != PackNone {
+		return s.skipPacked(field.Type())
+	}
+	return s.skipValue(field)
+}
+
+// skipValue skips over an encoded value of the type of value, without decoding it
+func (s *Serializer) skipValue(value reflect.Value) error {
+	switch value.Kind() {
+	case reflect.Struct:
+		var info = getStructInfo(value.Type())
+		if info.err != nil {
+			return info.err
+		}
+		for i := range info.fields {
+			if s.pos == len(s.data) && i >= info.optionalFrom && s.elements == 0 {
+				// The fields after the end of the payload have defaults, as they do when decoded
+				return nil
+			}
+			var fi = &info.fields[i]
+			var field = value.Field(fi.index)
+			if fi.omitEmpty {
+				present, err := s.readPresence()
+				if err != nil {
+					return WrapFieldError(fi.name, err)
+				}
+				if !present {
+					continue
+				}
+			}
+			if err := s.skipField(field, fi); err != nil {
+				return WrapFieldError(fi.name, err)
+			}
+		}
+		return nil
+	case reflect.Ptr:
+		if value.IsNil() {
+			return s.skipValue(reflect.Zero(value.Type().Elem()))
+		}
+		return s.skipValue(value.Elem())
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			length, err := s.readLength()
+			if err == nil {
+				_, err = s.read(length)
+			}
+			return err
+		}
+		if s.packing != PackNone && packable(value.Type()) {
+			return s.skipPacked(value.Type())
+		}
+		return s.skipElements(value.Type().Elem())
+	case reflect.Array:
+		return s.skipElements(value.Type().Elem())
+	case reflect.Map:
+		length, err := s.readLength()
+		if err == nil {
+			err = s.checkLength(length, encodedSize(value.Type().Key())+encodedSize(value.Type().Elem()))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read map length: %w", err)
+		}
+		var key, elem = reflect.Zero(value.Type().Key()), reflect.Zero(value.Type().Elem())
+		s.elements++
+		defer s.endElements()
+		for i := 0; i < length; i++ {
+			if err = s.skipValue(key); err != nil {
+				return err
+			}
+			if err = s.skipValue(elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		_, err := s.readScalar()
+		return err
+	}
+}
+
+// skipElements skips over the length of a slice or array, followed by its elements
+func (s *Serializer) skipElements(elemType reflect.Type) error {
+	length, err := s.readLength()
+	if err == nil {
+		err = s.checkLength(length, encodedSize(elemType))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read slice length: %w", err)
+	}
+	var elem = reflect.Zero(elemType)
+	s.elements++
+	defer s.endElements()
+	for i := 0; i < length; i++ {
+		if err = s.skipValue(elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipPacked skips over a packed block of the slice type
+func (s *Serializer) skipPacked(t reflect.Type) error {
+	var elem = packedElemOf(t.Elem())
+	block, err := s.readPackedBlock(elem)
+	if err == nil {
+		err = s.readPackedValues(block, elem, func(int, uint64) {})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read packed slice: %w", err)
+	}
+	return nil
+}
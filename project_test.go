@@ -0,0 +1,154 @@
+package tinyserializer
+
+import (
+	"reflect"
+	"testing"
+)
+
+type ProjectedStruct struct {
+	Name     string     `tiny:"name"`
+	Packed   []int64    `tiny:"packed,packed=varint"`
+	Optional string     `tiny:"optional,omitempty"`
+	Testie   Testie     `tiny:"testie"`
+	Ptr      *AllStruct `tiny:"ptr"`
+	Bytes    []byte     `tiny:"bytes"`
+	Array    [2]int16   `tiny:"array"`
+	Last     string     `tiny:"last"`
+}
+
+func TestDeserializeFields(t *testing.T) {
+	var value = ProjectedStruct{
+		Name:   "name",
+		Packed: []int64{1, 2, 3},
+		Testie: testStruct,
+		Ptr:    All_S,
+		Bytes:  []byte("bytes"),
+		Array:  [2]int16{1, 2},
+		Last:   "last",
+	}
+
+	for _, compress := range []bool{false, true} {
+		var s = NewSerializer().SetCompress(compress)
+		serialized, err := s.Serialize(&value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var projected ProjectedStruct
+		if err = s.DeserializeFields(serialized, &projected, "last", "testie.all.mapint", "ptr.stringfield"); err != nil {
+			t.Fatal(err)
+		}
+		var expected = ProjectedStruct{
+			Testie: Testie{All: &AllStruct{MapInt: All_S.MapInt}},
+			Ptr:    &AllStruct{StringField: All_S.StringField},
+			Last:   "last",
+		}
+		if !reflect.DeepEqual(expected, projected) {
+			t.Fatalf("expected %+v, got %+v", expected, projected)
+		}
+
+		// Requesting a whole struct includes all of its fields
+		projected = ProjectedStruct{}
+		if err = s.DeserializeFields(serialized, &projected, "testie.all.mapint", "testie", "packed"); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(value.Testie, projected.Testie) || !reflect.DeepEqual(value.Packed, projected.Packed) || projected.Name != "" {
+			t.Fatalf("expected testie and packed to be deserialized, got %+v", projected)
+		}
+	}
+}
+
+func TestDeserializeFieldsOmitEmpty(t *testing.T) {
+	type omitNested struct {
+		Skipped string `tiny:"skipped,omitempty"`
+		Value   int64  `tiny:"value"`
+	}
+	type omitStruct struct {
+		Optional string       `tiny:"optional,omitempty"`
+		Nested   []omitNested `tiny:"nested"`
+		Last     string       `tiny:"last"`
+	}
+	var s = NewSerializer()
+	for _, value := range []omitStruct{
+		{Optional: "x", Nested: []omitNested{{Skipped: "y", Value: 1}, {Value: 2}}, Last: "last"},
+		{Nested: []omitNested{{Value: 2}}, Last: "last"},
+	} {
+		serialized, err := s.Serialize(&value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Fields after omitempty fields are found whether they are present or not, also in skipped values
+		var projected omitStruct
+		if err = s.DeserializeFields(serialized, &projected, "last"); err != nil || projected.Last != "last" {
+			t.Fatalf("expected to decode the last field, got %+v, %v", projected, err)
+		}
+		projected = omitStruct{Optional: "old"}
+		if err = s.DeserializeFields(serialized, &projected, "optional", "nested"); err != nil {
+			t.Fatal(err)
+		}
+		if projected.Optional != value.Optional || !reflect.DeepEqual(projected.Nested, value.Nested) {
+			t.Fatalf("expected %+v, got %+v", value, projected)
+		}
+	}
+}
+
+func TestDeserializeFieldsLinkedList(t *testing.T) {
+	type lists struct {
+		List listNode `tiny:"list"`
+		Last string   `tiny:"last"`
+	}
+	var s = NewSerializer()
+	data, err := s.Serialize(&lists{List: linkedList, Last: "last"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The list is skipped to reach the last field
+	var projected lists
+	if err = s.DeserializeFields(data, &projected, "last"); err != nil || projected.Last != "last" || projected.List.Next != nil {
+		t.Fatalf("expected only the last field, got %+v (%v)", projected, err)
+	}
+	projected = lists{}
+	if err = s.DeserializeFields(data, &projected, "list.next.next.name", "last"); err != nil {
+		t.Fatal(err)
+	}
+	if projected.List.Next == nil || projected.List.Next.Next == nil || projected.List.Next.Next.Name != "c" || projected.List.Name != "" {
+		t.Fatalf("expected the name of the third node, got %+v", projected)
+	}
+}
+
+func TestDeserializeFieldsInvalid(t *testing.T) {
+	var s = NewSerializer()
+	serialized, err := s.Serialize(&testStruct)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var projected Testie
+	for _, path := range []string{"missing", "all.missing", "intlist.value", ""} {
+		if err = s.DeserializeFields(serialized, &projected, path); err == nil {
+			t.Fatalf("expected error for path %q", path)
+		}
+	}
+	if err = s.DeserializeFields(serialized, projected, "all"); err == nil {
+		t.Fatal("expected error deserializing into a value which is not a pointer")
+	}
+	if err = s.DeserializeFields(serialized[:len(serialized)/2], &projected, "listmapstruct"); err == nil {
+		t.Fatal("expected error deserializing truncated data")
+	}
+}
+
+func BenchmarkDeserializeFields(b *testing.B) {
+	var s = NewSerializer()
+	serialized, err := s.Serialize(&testStruct)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var projected Testie
+		if err = s.DeserializeFields(serialized, &projected, "all.intfield"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
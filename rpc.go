@@ -0,0 +1,140 @@
+package tinyserializer
+
+import (
+	"bufio"
+	"io"
+	"net/rpc"
+	"reflect"
+)
+
+// rpcHeader is the header of net/rpc requests and responses.
+// Every header is sent in its own frame, followed by a frame holding the body.
+type rpcHeader struct {
+	ServiceMethod string `tiny:"method"`
+	Seq           uint64 `tiny:"seq"`
+	Error         string `tiny:"error"`
+}
+
+// rpcCodec holds the framing shared by the client and server codecs
+type rpcCodec struct {
+	conn io.ReadWriteCloser
+	buf  *bufio.Writer
+	w    *FrameWriter
+	r    *FrameReader
+
+	// Headers being written and read, which happens concurrently
+	out, in rpcHeader
+}
+
+func newRPCCodec(conn io.ReadWriteCloser) rpcCodec {
+	var buf = bufio.NewWriter(conn)
+	return rpcCodec{
+		conn: conn,
+		buf:  buf,
+		// Reading and writing happen concurrently, so they use their own serializers
+		w: NewFrameWriter(buf, nil),
+		r: NewFrameReader(conn, nil),
+	}
+}
+
+// write writes the header and body as a single message
+func (c *rpcCodec) write(body interface{}) error {
+	if err := c.w.WriteFrame(&c.out); err != nil {
+		return err
+	}
+	if err := c.w.WriteFrame(rpcBody(body)); err != nil {
+		return err
+	}
+	return c.buf.Flush()
+}
+
+// readHeader reads the next header into c.in
+func (c *rpcCodec) readHeader() error {
+	c.in = rpcHeader{}
+	return c.r.ReadFrame(&c.in)
+}
+
+// readBody reads the body following the header, a nil body is discarded
+func (c *rpcCodec) readBody(body interface{}) error {
+	if body == nil {
+		_, err := c.r.Next()
+		return err
+	}
+	return c.r.ReadFrame(body)
+}
+
+// rpcBody returns a pointer to the body, if it is a value which can not be serialized on its own
+func rpcBody(body interface{}) interface{} {
+	var value = reflect.ValueOf(body)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Struct, reflect.Slice, reflect.Map:
+		return body
+	}
+	var ptr = reflect.New(value.Type())
+	ptr.Elem().Set(value)
+	return ptr.Interface()
+}
+
+type clientCodec struct {
+	rpcCodec
+}
+
+// NewClientCodec returns a net/rpc client codec using the serializer as its wire format.
+//
+//	client := rpc.NewClientWithCodec(tinyserializer.NewClientCodec(conn))
+func NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return &clientCodec{newRPCCodec(conn)}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	c.out = rpcHeader{ServiceMethod: r.ServiceMethod, Seq: r.Seq}
+	return c.write(body)
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	if err := c.readHeader(); err != nil {
+		return err
+	}
+	r.ServiceMethod, r.Seq, r.Error = c.in.ServiceMethod, c.in.Seq, c.in.Error
+	return nil
+}
+
+func (c *clientCodec) ReadResponseBody(body interface{}) error {
+	return c.readBody(body)
+}
+
+func (c *clientCodec) Close() error {
+	return c.conn.Close()
+}
+
+type serverCodec struct {
+	rpcCodec
+}
+
+// NewServerCodec returns a net/rpc server codec using the serializer as its wire format.
+//
+//	go server.ServeCodec(tinyserializer.NewServerCodec(conn))
+func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return &serverCodec{newRPCCodec(conn)}
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.readHeader(); err != nil {
+		return err
+	}
+	r.ServiceMethod, r.Seq = c.in.ServiceMethod, c.in.Seq
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(body interface{}) error {
+	return c.readBody(body)
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.out = rpcHeader{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Error: r.Error}
+	return c.write(body)
+}
+
+func (c *serverCodec) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,139 @@
+package tinyserializer
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type ArithArgs struct {
+	A int64 `tiny:"a"`
+	B int64 `tiny:"b"`
+}
+
+type Arith struct{}
+
+func (Arith) Multiply(args *ArithArgs, reply *int64) error {
+	*reply = args.A * args.B
+	return nil
+}
+
+func (Arith) Divide(args ArithArgs, reply *ArithArgs) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	reply.A, reply.B = args.A/args.B, args.A%args.B
+	return nil
+}
+
+func (Arith) Echo(args *AllStruct, reply *AllStruct) error {
+	*reply = *args
+	return nil
+}
+
+func (Arith) Square(args int32, reply *int32) error {
+	*reply = args * args
+	return nil
+}
+
+func (Arith) Sum(args []ArithArgs, reply *int64) error {
+	for _, arg := range args {
+		*reply += arg.A + arg.B
+	}
+	return nil
+}
+
+func newRPCClient(t *testing.T) *rpc.Client {
+	var server = rpc.NewServer()
+	if err := server.Register(Arith{}); err != nil {
+		t.Fatal(err)
+	}
+	var clientConn, serverConn = net.Pipe()
+	go server.ServeCodec(NewServerCodec(serverConn))
+	var client = rpc.NewClientWithCodec(NewClientCodec(clientConn))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRPC(t *testing.T) {
+	var client = newRPCClient(t)
+
+	var product int64
+	if err := client.Call("Arith.Multiply", &ArithArgs{A: 6, B: 7}, &product); err != nil || product != 42 {
+		t.Fatalf("expected 42, got %d (%v)", product, err)
+	}
+	var quotient ArithArgs
+	if err := client.Call("Arith.Divide", ArithArgs{A: 7, B: 2}, &quotient); err != nil || quotient != (ArithArgs{A: 3, B: 1}) {
+		t.Fatalf("expected {3 1}, got %v (%v)", quotient, err)
+	}
+	var square int32
+	if err := client.Call("Arith.Square", int32(9), &square); err != nil || square != 81 {
+		t.Fatalf("expected 81, got %d (%v)", square, err)
+	}
+	var echo AllStruct
+	if err := client.Call("Arith.Echo", All_S, &echo); err != nil || !reflect.DeepEqual(&echo, All_S) {
+		t.Fatalf("expected %+v, got %+v (%v)", All_S, echo, err)
+	}
+
+	// Errors are returned to the client, and the connection stays usable
+	if err := client.Call("Arith.Divide", ArithArgs{A: 1}, &quotient); err == nil || err.Error() != "divide by zero" {
+		t.Fatalf("expected divide by zero error, got %v", err)
+	}
+	if err := client.Call("Arith.Missing", ArithArgs{}, &quotient); err == nil {
+		t.Fatal("expected error calling an unknown method")
+	}
+	if err := client.Call("Arith.Multiply", &ArithArgs{A: 2, B: 3}, &product); err != nil || product != 6 {
+		t.Fatalf("expected 6, got %d (%v)", product, err)
+	}
+}
+
+func TestRPCConcurrent(t *testing.T) {
+	var client = newRPCClient(t)
+	var wg sync.WaitGroup
+	for i := int64(0); i < 50; i++ {
+		wg.Add(1)
+		go func(i int64) {
+			defer wg.Done()
+			var product int64
+			if err := client.Call("Arith.Multiply", &ArithArgs{A: i, B: i}, &product); err != nil || product != i*i {
+				t.Errorf("expected %d, got %d (%v)", i*i, product, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRPCHostileLength(t *testing.T) {
+	var server = rpc.NewServer()
+	if err := server.Register(Arith{}); err != nil {
+		t.Fatal(err)
+	}
+	var clientConn, serverConn = net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewServerCodec(serverConn))
+
+	// A request body with a huge slice length is answered with an error, instead of allocating it
+	go func() {
+		var fw = NewFrameWriter(clientConn, nil)
+		if err := fw.WriteFrame(&rpcHeader{ServiceMethod: "Arith.Sum", Seq: 1}); err != nil {
+			t.Error(err)
+			return
+		}
+		var body = []byte{frameMagic0, frameMagic1, 0, 4, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}
+		if _, err := clientConn.Write(body); err != nil {
+			t.Error(err)
+		}
+	}()
+	var fr = NewFrameReader(clientConn, nil)
+	var header rpcHeader
+	if err := fr.ReadFrame(&header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Seq != 1 || !strings.Contains(header.Error, "unexpected EOF") {
+		t.Fatalf("expected an error response, got %+v", header)
+	}
+}
@@ -0,0 +1,232 @@
+package tinyserializer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ErrSchemaMismatch is returned when a payload was written with a different schema than the type it is read into
+var ErrSchemaMismatch = errors.New("payload schema does not match")
+
+// Schema describes the encoded layout of a type.
+//
+// Types are stored in a table and refer to each other by their index in it,
+// so that recursive types can be described. The first type is the described type.
+// Pointers are encoded as the value they point to, so they do not appear in the schema.
+//
+// Schemas can be serialized themselves, to store or compare them with later versions of the type.
+type Schema struct {
+	Types []SchemaType `tiny:"types"`
+}
+
+// SchemaType describes the encoding of a single type
+type SchemaType struct {
+	// Name of the Go type, it does not affect the encoding
+	Name string `tiny:"name"`
+	// Kind of the type, as named by reflect.Kind
+	Kind string `tiny:"kind"`
+	// Length of an array
+	Len int64 `tiny:"len"`
+	// Index of the element type of a slice, array or map, and of the key type of a map
+	Elem int32 `tiny:"elem"`
+	Key  int32 `tiny:"key"`
+	// Serialized fields of a struct, in the order they are encoded
+	Fields []SchemaField `tiny:"fields"`
+}
+
+// SchemaField describes a serialized field of a struct
+type SchemaField struct {
+	// Name of the field, taken from the tiny tag
+	Name string `tiny:"name"`
+	// Position of the field in the encoding of the struct
+	ID int32 `tiny:"id"`
+	// Index of the type of the field
+	Type int32 `tiny:"type"`
+	// A bare omitempty tag name would make the field itself omitempty
+	OmitEmpty bool    `tiny:"omit"`
+	Packing   Packing `tiny:"packing"`
+	// Value of the default option, set when the field is missing from older payloads
+	Default string `tiny:"default"`
+	// Whether the field has a default, from the default option or because the struct implements Defaulter
+	HasDefault bool `tiny:"hasdefault"`
+	// Whether the field has the required option, missing required fields fail validation
+	Required bool `tiny:"required"`
+}
+
+// Cache of reflect.Type -> *Schema and fingerprints
+var (
+	schemaCache      sync.Map
+	fingerprintCache sync.Map
+)
+
+// SchemaOf returns the schema of the type of v, which can be a nil pointer.
+// The schema is shared, it must not be modified.
+func SchemaOf(v interface{}) *Schema {
+	var t = reflect.TypeOf(v)
+	if t == nil {
+		return nil
+	}
+	return schemaOfType(derefType(t))
+}
+
+func schemaOfType(t reflect.Type) *Schema {
+	if schema, ok := schemaCache.Load(t); ok {
+		return schema.(*Schema)
+	}
+	var schema = new(Schema)
+	schema.add(t, make(map[reflect.Type]int32))
+	actual, _ := schemaCache.LoadOrStore(t, schema)
+	return actual.(*Schema)
+}
+
+// add adds the type to the schema if it is not in it yet, and returns its index
+func (schema *Schema) add(t reflect.Type, seen map[reflect.Type]int32) int32 {
+	t = derefType(t)
+	if i, ok := seen[t]; ok {
+		return i
+	}
+	var i = int32(len(schema.Types))
+	seen[t] = i
+	schema.Types = append(schema.Types, SchemaType{Name: t.String(), Kind: t.Kind().String()})
+
+	// The table may grow while adding the types below, so the type is set by index
+	switch t.Kind() {
+	case reflect.Array:
+		schema.Types[i].Len = int64(t.Len())
+		schema.Types[i].Elem = schema.add(t.Elem(), seen)
+	case reflect.Slice:
+		schema.Types[i].Elem = schema.add(t.Elem(), seen)
+	case reflect.Map:
+		schema.Types[i].Key = schema.add(t.Key(), seen)
+		schema.Types[i].Elem = schema.add(t.Elem(), seen)
+	case reflect.Struct:
+		var info = getStructInfo(t)
+		var fields = make([]SchemaField, len(info.fields))
+		for j := range info.fields {
+			var fi = &info.fields[j]
+			fields[j] = SchemaField{
+				Name:      fi.name,
+				ID:        int32(j),
+				Type:      schema.add(fi.typ, seen),
+				OmitEmpty: fi.omitEmpty,
+				Packing:   fi.packing,
+				Default:   fi.defaultText,
+
+				HasDefault: fi.defaultValue.IsValid() || info.defaulter,
+				Required:   fi.required,
+			}
+		}
+		schema.Types[i].Fields = fields
+	}
+	return i
+}
+
+// Root returns the described type
+func (schema *Schema) Root() *SchemaType {
+	return &schema.Types[0]
+}
+
+// Type returns the type at index i of the table
+func (schema *Schema) Type(i int32) *SchemaType {
+	return &schema.Types[i]
+}
+
+// Fingerprint returns a hash of the positional layout described by the schema:
+// the kinds of the types, and the positions, omitempty and packing options of struct fields.
+//
+// Names of Go types and fields and defaults of fields do not affect the fingerprint,
+// so renaming a type or field keeps it, as CheckCompatibility allows.
+// The fingerprint only matches the exact same layout: adding a field changes it,
+// also where CheckCompatibility accepts the added field because it has a default.
+func (schema *Schema) Fingerprint() uint64 {
+	var stripped = Schema{Types: make([]SchemaType, len(schema.Types))}
+	copy(stripped.Types, schema.Types)
+	for i := range stripped.Types {
+		var t = &stripped.Types[i]
+		t.Name = ""
+		if len(t.Fields) > 0 {
+			t.Fields = append([]SchemaField(nil), t.Fields...)
+			for j := range t.Fields {
+				var field = &t.Fields[j]
+				field.Name, field.Default, field.HasDefault, field.Required = "", "", false, false
+			}
+		}
+	}
+	// A schema only holds encodable values
+	var sum, _ = Sum64(&stripped)
+	return sum
+}
+
+// Validate checks that the types of the schema only refer to types in it
+func (schema *Schema) Validate() error {
+	if schema == nil || len(schema.Types) == 0 {
+		return fmt.Errorf("schema has no types")
+	}
+	var n = int32(len(schema.Types))
+	var valid = func(i int32) bool { return i >= 0 && i < n }
+	for i, t := range schema.Types {
+		if !valid(t.Elem) || !valid(t.Key) {
+			return fmt.Errorf("type %d (%s) refers to an unknown type", i, t.Name)
+		}
+		for _, field := range t.Fields {
+			if !valid(field.Type) {
+				return fmt.Errorf("field %s of type %d (%s) refers to an unknown type", field.Name, i, t.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON returns the schema as JSON keyed by the tiny tag names of its fields, see ToJSON
+func (schema *Schema) MarshalJSON() ([]byte, error) {
+	return appendJSON(nil, reflect.ValueOf(schema).Elem())
+}
+
+// UnmarshalJSON parses a schema written by MarshalJSON, and validates it
+func (schema *Schema) UnmarshalJSON(data []byte) error {
+	var parsed Schema
+	if err := unmarshalJSON(data, reflect.ValueOf(&parsed).Elem()); err != nil {
+		return err
+	}
+	if err := parsed.Validate(); err != nil {
+		return err
+	}
+	*schema = parsed
+	return nil
+}
+
+// fingerprintOf returns the cached fingerprint of the schema of t
+func fingerprintOf(t reflect.Type) uint64 {
+	t = derefType(t)
+	if sum, ok := fingerprintCache.Load(t); ok {
+		return sum.(uint64)
+	}
+	var sum = schemaOfType(t).Fingerprint()
+	fingerprintCache.Store(t, sum)
+	return sum
+}
+
+// SetFingerprint sets whether the fingerprint of the schema of serialized values is written into the payload header.
+//
+// Deserializing a payload with a fingerprint into a type with a different layout fails with ErrSchemaMismatch,
+// instead of decoding garbage. Payloads without a fingerprint are decoded as usual.
+// Fields added to a type change its fingerprint even if they have defaults, so payloads written with
+// fingerprints before a field was added can not be read into the new type, see Schema.Fingerprint.
+// The reader must have fingerprints enabled as well, unless another option writing a header is enabled on both sides.
+func (s *Serializer) SetFingerprint(fingerprint bool) *Serializer {
+	s.fingerprint = fingerprint
+	return s
+}
+
+// checkFingerprint checks the fingerprint in the header, if any, matches the type
+func checkFingerprint(h header, t reflect.Type) error {
+	if h.flags&flagFingerprint == 0 {
+		return nil
+	}
+	if sum := fingerprintOf(t); sum != h.fingerprint {
+		return fmt.Errorf("%w: payload has fingerprint %016x, %s has %016x", ErrSchemaMismatch, h.fingerprint, derefType(t), sum)
+	}
+	return nil
+}
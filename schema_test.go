@@ -0,0 +1,246 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type schemaNode struct {
+	Name     string            `tiny:"name"`
+	Children []schemaNode      `tiny:"children"`
+	Parent   *schemaNode       `tiny:"parent,omitempty"`
+	Labels   map[string][2]int `tiny:"labels"`
+	IDs      []int64           `tiny:"ids,packed=delta"`
+	Ignored  string            `tiny:"-"`
+}
+
+type schemaV1 struct {
+	ID   int64  `tiny:"id"`
+	Name string `tiny:"name"`
+}
+
+// Renamed type with the same layout
+type schemaV1Renamed struct {
+	Key   int64  `tiny:"id"`
+	Label string `tiny:"name"`
+}
+
+type schemaV2 struct {
+	ID   int64  `tiny:"id"`
+	Name string `tiny:"name"`
+	Age  int32  `tiny:"age"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	var schema = SchemaOf((*schemaNode)(nil))
+	var expected = &Schema{Types: []SchemaType{
+		{Name: "tinyserializer.schemaNode", Kind: "struct", Fields: []SchemaField{
+			{Name: "name", ID: 0, Type: 1},
+			{Name: "children", ID: 1, Type: 2},
+			{Name: "parent", ID: 2, Type: 0, OmitEmpty: true},
+			{Name: "labels", ID: 3, Type: 3},
+			{Name: "ids", ID: 4, Type: 6, Packing: PackDelta},
+		}},
+		{Name: "string", Kind: "string"},
+		{Name: "[]tinyserializer.schemaNode", Kind: "slice", Elem: 0},
+		{Name: "map[string][2]int", Kind: "map", Key: 1, Elem: 4},
+		{Name: "[2]int", Kind: "array", Len: 2, Elem: 5},
+		{Name: "int", Kind: "int"},
+		{Name: "[]int64", Kind: "slice", Elem: 7},
+		{Name: "int64", Kind: "int64"},
+	}}
+	if !reflect.DeepEqual(schema, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, schema)
+	}
+	if SchemaOf(schemaNode{}) != schema {
+		t.Fatal("expected the schema to be cached")
+	}
+	if schema.Root().Fields[1].Name != "children" || schema.Type(schema.Root().Fields[1].Type).Kind != "slice" {
+		t.Fatalf("unexpected root %+v", schema.Root())
+	}
+
+	// Schemas can be serialized themselves
+	data, err := NewSerializer().Serialize(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Schema
+	if err = NewSerializer().Deserialize(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	// Empty field lists are decoded as empty slices, so compare the encodings
+	reencoded, err := NewSerializer().Serialize(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reencoded, data) || decoded.Types[0].Fields[4].Packing != PackDelta {
+		t.Fatalf("expected %+v, got %+v", schema, decoded)
+	}
+	if decoded.Fingerprint() != schema.Fingerprint() {
+		t.Fatal("expected the decoded schema to have the same fingerprint")
+	}
+}
+
+func TestSchemaFingerprint(t *testing.T) {
+	var v1 = SchemaOf(schemaV1{}).Fingerprint()
+	if v1 != SchemaOf(schemaV1Renamed{}).Fingerprint() {
+		t.Fatal("expected renaming Go types and fields to keep the fingerprint")
+	}
+	if v1 == SchemaOf(schemaV2{}).Fingerprint() {
+		t.Fatal("expected adding a field to change the fingerprint")
+	}
+	type reordered struct {
+		Name string `tiny:"name"`
+		ID   int64  `tiny:"id"`
+	}
+	type retyped struct {
+		ID   int32  `tiny:"id"`
+		Name string `tiny:"name"`
+	}
+	type renamedTag struct {
+		ID   int64  `tiny:"key"`
+		Name string `tiny:"name"`
+	}
+	type defaulted struct {
+		ID   int64  `tiny:"id,default=1"`
+		Name string `tiny:"name"`
+	}
+	type omitted struct {
+		ID   int64  `tiny:"id,omitempty"`
+		Name string `tiny:"name"`
+	}
+	for _, v := range []interface{}{renamedTag{}, defaulted{}} {
+		if SchemaOf(v).Fingerprint() != v1 {
+			t.Errorf("expected %T to keep the fingerprint", v)
+		}
+	}
+	for _, v := range []interface{}{reordered{}, retyped{}, omitted{}} {
+		if SchemaOf(v).Fingerprint() == v1 {
+			t.Errorf("expected %T to change the fingerprint", v)
+		}
+	}
+}
+
+func TestFingerprintCompatibility(t *testing.T) {
+	type renamed struct {
+		ID    int64  `tiny:"key"`
+		Title string `tiny:"title"`
+	}
+	type appended struct {
+		ID      int64  `tiny:"id"`
+		Name    string `tiny:"name"`
+		Retries int32  `tiny:"retries,default=3"`
+	}
+	var s = NewSerializer().SetFingerprint(true)
+	data, err := s.Serialize(&schemaV1{ID: 1, Name: "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Renamed fields are compatible, and keep the fingerprint
+	if found := CheckCompatibility(SchemaOf(schemaV1{}), SchemaOf(renamed{})); len(found) != 0 {
+		t.Fatalf("expected renamed fields to be compatible, got %v", found)
+	}
+	var r renamed
+	if err = s.Deserialize(data, &r); err != nil || r.Title != "name" {
+		t.Fatalf("expected the payload to decode into the renamed fields, got %+v (%v)", r, err)
+	}
+
+	// Fields with defaults are compatible, but the fingerprint only matches the exact layout
+	if found := CheckCompatibility(SchemaOf(schemaV1{}), SchemaOf(appended{})); len(found) != 0 {
+		t.Fatalf("expected the defaulted field to be compatible, got %v", found)
+	}
+	var a appended
+	if err = s.Deserialize(data, &a); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected %v, got %v", ErrSchemaMismatch, err)
+	}
+	// Without a fingerprint, the old payload decodes with the default
+	if data, err = NewSerializer().Serialize(&schemaV1{ID: 1, Name: "name"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = NewSerializer().Deserialize(data, &a); err != nil || a.Retries != 3 {
+		t.Fatalf("expected the default retries, got %+v (%v)", a, err)
+	}
+}
+
+func TestSerializeFingerprint(t *testing.T) {
+	var s = NewSerializer().SetFingerprint(true)
+	data, err := s.Serialize(&schemaV1{ID: 1, Name: "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var renamed schemaV1Renamed
+	if err = s.Deserialize(data, &renamed); err != nil {
+		t.Fatal(err)
+	}
+	if renamed != (schemaV1Renamed{Key: 1, Label: "name"}) {
+		t.Fatalf("unexpected value %+v", renamed)
+	}
+
+	var v2 schemaV2
+	if err = s.Deserialize(data, &v2); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected schema mismatch, got %v", err)
+	}
+	if err = s.DeserializeFields(data, &v2, "name"); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected schema mismatch, got %v", err)
+	}
+	if _, err = s.View(data, &v2).Field("name").Text(); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("expected schema mismatch, got %v", err)
+	}
+
+	// Fingerprints are combined with the other header fields
+	var all = NewSerializer().SetFingerprint(true).SetIndex(true).SetCompress(true).SetCompressThreshold(0).SetCompressIfSmaller(false)
+	if data, err = all.Serialize(All_S); err != nil {
+		t.Fatal(err)
+	}
+	h, _, err := parseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.flags != flagCompressed|flagIndex|flagFingerprint || h.fingerprint != SchemaOf(All_S).Fingerprint() {
+		t.Fatalf("unexpected header %+v", h)
+	}
+	var out AllStruct
+	if err = all.Deserialize(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&out, All_S) {
+		t.Fatalf("expected %+v, got %+v", All_S, out)
+	}
+	if name, err := all.View(data, All_S).Field("stringfield").Text(); err != nil || name != All_S.StringField {
+		t.Fatalf("expected %s, got %s (%v)", All_S.StringField, name, err)
+	}
+
+	// Payloads without a fingerprint are still accepted
+	if data, err = NewSerializer().SetCompress(true).Serialize(&schemaV1{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Deserialize(data, &renamed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchemaJSON(t *testing.T) {
+	var schema = SchemaOf(schemaNode{})
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte(`{"types":[{"name":"tinyserializer.schemaNode","kind":"struct","len":0,"elem":0,"key":0,"fields":[{"name":"name","id":0,"type":1,"omit":false,"packing":0,"default":"","hasdefault":false,"required":false}`)) {
+		t.Fatalf("unexpected JSON %s", data)
+	}
+	var decoded Schema
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Fingerprint() != schema.Fingerprint() {
+		t.Fatal("expected the decoded schema to have the same fingerprint")
+	}
+	if err = json.Unmarshal([]byte(`{"types":[{"kind":"map","key":1}]}`), &decoded); err == nil {
+		t.Fatal("expected error decoding an invalid schema")
+	}
+}
@@ -2,12 +2,11 @@ package tinyserializer
 
 import (
 	"bytes"
-	"compress/gzip"
 	"encoding/binary"
 	"fmt"
-	"io"
-	"math"
+	"hash"
 	"reflect"
+	"time"
 )
 
 // Serializer is a struct that can serialize and deserialize data
@@ -15,6 +14,59 @@ type Serializer struct {
 	// The buffer to serialize to or deserialize from
 	buffer   *bytes.Buffer
 	compress bool
+
+	// Compression policy and statistics
+	compressThreshold int
+	compressIfSmaller bool
+	stats             CompressionStats
+
+	// Preset dictionary to compress with, and the registry to look up dictionaries in when decompressing
+	dictionary   *Dictionary
+	dictionaries *DictionaryRegistry
+
+	// Reused buffers for serialized and decompressed payloads
+	out              []byte
+	decompressBuffer []byte
+
+	// Keyring to encrypt payloads with, and reused buffers for payloads before encryption and after decryption
+	keyring    *Keyring
+	sealBuffer []byte
+	openBuffer []byte
+
+	// Signer to sign payloads with, the lifetime of signed payloads, and a reused buffer for signed payloads to encrypt
+	signer       *Signer
+	signatureTTL time.Duration
+	signBuffer   []byte
+
+	// Data being deserialized, and the current position in it
+	reader
+	zeroCopy bool
+	// Number of slice, array and map elements being decoded, the payload can not end inside of them
+	elements int
+
+	// How slices of numbers and bools are encoded
+	packing Packing
+	// Whether equal values must encode to identical bytes
+	canonical bool
+
+	// Whether to write an index of the top level struct fields, and the index being recorded
+	index      bool
+	indexing   bool
+	fieldIndex []byte
+
+	// Whether to write the fingerprint of the schema of serialized values
+	fingerprint bool
+	// Whether to write the version of serialized types, and migrate older payloads
+	versioning bool
+
+	// Hash to stream encoded data into, and the number of maps whose entries are being sorted
+	hash        hash.Hash
+	sortingMaps int
+
+	// Whether to ignore generated MarshalTiny and UnmarshalTiny methods
+	noGenerated bool
+	// Whether values are decoded to inspect them, without validation and AfterDeserialize hooks
+	inspecting bool
 }
 
 // Now, all fields will be stored along
@@ -27,6 +79,10 @@ func NewSerializer() *Serializer {
 	return &Serializer{
 		buffer:   new(bytes.Buffer),
 		compress: false,
+
+		compressThreshold: DefaultCompressThreshold,
+		compressIfSmaller: true,
+		dictionaries:      DefaultDictionaries,
 	}
 }
 
@@ -41,154 +97,142 @@ func (s *Serializer) SetData(data []byte) *Serializer {
 }
 
 // Serialize serializes the given data
+//
+// The returned slice is reused by the next call to Serialize,
+// use AppendSerialize to serialize into a buffer of your own.
 func (s *Serializer) Serialize(data interface{}) ([]byte, error) {
-	// Reset the buffer
-	s.buffer.Reset()
-
-	// Serialize the data
-	err := s.serialize(data)
-	if err != nil {
-		return nil, err
+	if s.headered() {
+		return s.AppendSerialize(nil, data)
 	}
-
-	if s.compress {
-		return Compress(s.buffer.Bytes())
-	}
-	// Return the serialized data
-	return s.buffer.Bytes(), nil
+	var err error
+	s.out, err = s.AppendSerialize(s.out[:0], data)
+	return s.out, err
 }
 
-// Deserialize deserializes the given data
-func (s *Serializer) Deserialize(data []byte, out interface{}) error {
-	// Create a new serializer
-	if s.compress {
-		var err error
-		data, err = Decompress(data)
-		if err != nil {
-			return err
+// AppendSerialize serializes the given data and appends it to dst.
+//
+// Values are encoded directly into dst, without allocating per field.
+// If dst has enough capacity, serializing a struct of scalars does not allocate at all.
+func (s *Serializer) AppendSerialize(dst []byte, data interface{}) ([]byte, error) {
+	if s.keyring == nil && s.signer == nil {
+		return s.appendSerialize(dst, data)
+	}
+	var err error
+	if s.sealBuffer, err = s.appendSerialize(s.sealBuffer[:0], data); err != nil {
+		return nil, err
+	}
+	var payload = s.sealBuffer
+	if s.signer != nil {
+		if s.keyring == nil {
+			return s.signer.sign(dst, payload, s.signatureTTL)
 		}
+		if s.signBuffer, err = s.signer.sign(s.signBuffer[:0], payload, s.signatureTTL); err != nil {
+			return nil, err
+		}
+		payload = s.signBuffer
 	}
-
-	// Set the buffer to the given data
-	s.buffer = bytes.NewBuffer(data)
-
-	// Deserialize the data
-	return s.deserialize(out)
+	return s.keyring.seal(dst, payload)
 }
 
-// serialize serializes the given data
-func (s *Serializer) serialize(data interface{}) error {
+// appendSerialize serializes the given data and appends it to dst, without encrypting it
+func (s *Serializer) appendSerialize(dst []byte, data interface{}) ([]byte, error) {
 	// Get the value of the data
 	value := reflect.ValueOf(data)
 
 	// Check if the data is a pointer or struct
 	if value.Kind() != reflect.Ptr && value.Kind() != reflect.Struct && value.Kind() != reflect.Slice && value.Kind() != reflect.Map {
-		return fmt.Errorf("data must be a pointer, struct, map or slice")
+		return nil, fmt.Errorf("data must be a pointer, struct, map or slice")
 	}
-	// Get the value of the data
-	value = GetValue(value)
-
-	dataType := value.Type()
-	// Check if the data is a struct
-	if value.Kind() == reflect.Struct {
-		return s.WriteStruct(value, dataType)
-	} else if value.Kind() == reflect.Slice {
-		value = value.Index(0)
-		for i := 0; i < value.Len(); i++ {
-			err := s.WriteField(value.Index(i), value.Index(i).Kind())
-			if err != nil {
-				return err
-			}
-		}
-	} else if value.Kind() == reflect.Map {
-		return s.serializeMapFields(value)
-	}
-	return nil
-}
 
-func (s *Serializer) WriteStruct(value reflect.Value, dataType reflect.Type) error {
-	numFields := value.NumField()
-	// Loop through all fields
-	for i := 0; i < numFields; i++ {
-		// Get the field
-		field := value.Field(i)
-		kind := field.Kind()
-		// Check field tags
-		if dataType.Field(i).Tag.Get("tiny") == "" {
-			continue
-		} else if dataType.Field(i).Tag.Get("tiny") == "-" {
-			continue
-		} else if dataType.Field(i).Tag.Get("tiny") == "omitempty" && field.IsZero() {
-			continue
-		}
-		// Get the field type
-		fieldType := dataType.Field(i)
-		// Check if the field is exported
-		if fieldType.PkgPath != "" {
-			continue
-		}
-		// Check if the field is a slice or a map
-		s.WriteField(field, kind)
+	if !s.headered() {
+		return s.appendValue(dst, value)
 	}
-	return nil
-}
 
-func (s *Serializer) WriteField(field reflect.Value, kind reflect.Kind) error {
+	// The payload is written behind a header, serialize into a scratch buffer first
 	var err error
-	if kind == reflect.Slice {
-		// Serialize the field
-		length := field.Len()
+	var h header
+	s.fieldIndex = s.fieldIndex[:0]
+	s.indexing = s.index && reflect.Indirect(value).Kind() == reflect.Struct
+	if s.indexing {
+		h.flags |= flagIndex
+	}
+	if s.fingerprint {
+		h.flags |= flagFingerprint
+		h.fingerprint = fingerprintOf(value.Type())
+	}
+	if s.versioning {
+		h.flags |= flagVersion
+		h.version = versionOf(value.Type())
+	}
+	s.out, err = s.appendValue(s.out[:0], value)
+	s.indexing = false
+	if err != nil {
+		return nil, err
+	}
+	h.index = s.fieldIndex
 
-		// Write the length of the slice
-		if err = binary.Write(s.buffer, binary.LittleEndian, uint32(length)); err != nil {
-			return fmt.Errorf("failed to write slice length: " + err.Error())
-		}
+	if !s.compress {
+		dst = h.appendTo(dst)
+		return append(dst, s.out...), nil
+	}
+	return s.appendCompressed(dst, s.out, h)
+}
 
-		// Loop through all elements in the slice
-		for i := 0; i < length; i++ {
-			// Get the element
-			element := field.Index(i)
-			// Serialize the element
-			if err = s.serializeField(element); err != nil {
+// Deserialize deserializes the given data
+func (s *Serializer) Deserialize(data []byte, out interface{}) error {
+	data, err := s.openPayload(data, s.zeroCopy)
+	if err != nil {
+		return err
+	}
+	if s.headered() {
+		if s.zeroCopy {
+			// Decoded values alias the decompressed data, so its buffer can not be reused
+			s.decompressBuffer = nil
+		}
+		h, payload, err := s.readPayload(data)
+		if err != nil {
+			return err
+		}
+		if out != nil {
+			if h.flags&flagVersion != 0 && h.version != versionOf(reflect.TypeOf(out)) {
+				return s.deserializeMigrated(payload, h, out)
+			}
+			if err = checkFingerprint(h, reflect.TypeOf(out)); err != nil {
 				return err
 			}
 		}
-	} else if kind == reflect.Map {
-		// Serialize the field
-		length := field.Len()
+		data = payload
+	}
 
-		// Write the length of the map
-		err = binary.Write(s.buffer, binary.LittleEndian, uint32(length))
-		if err != nil {
-			return fmt.Errorf("failed to write map length: " + err.Error())
-		}
+	// Deserialize the data
+	return s.deserialize(data, out)
+}
 
-		// Loop through all elements in the map
-		return s.serializeMapFields(field)
-	} else {
-		// Serialize the field
-		return s.serializeField(field)
-	}
-	return nil
+// headered reports whether payloads start with a header
+func (s *Serializer) headered() bool {
+	return s.compress || s.index || s.fingerprint || s.versioning
 }
 
-func (s *Serializer) serializeMapFields(value reflect.Value) error {
-	// Get the number of fields
-	numFields := value.MapRange()
+// WriteStruct serializes the struct and writes it to the buffer of the serializer
+func (s *Serializer) WriteStruct(value reflect.Value, dataType reflect.Type) error {
+	var err error
+	s.out, err = s.appendStruct(s.out[:0], value)
+	if err != nil {
+		return err
+	}
+	_, err = s.buffer.Write(s.out)
+	return err
+}
 
-	// Loop through all fields
-	for numFields.Next() {
-		// Get the field
-		// Serialize the field
-		if err := s.serializeField(numFields.Key()); err != nil {
-			return fmt.Errorf("failed to serialize field: " + err.Error())
-		}
-		// Serialize the field
-		if err := s.serializeField(numFields.Value()); err != nil {
-			return fmt.Errorf("failed to serialize field: " + err.Error())
-		}
+// WriteField serializes the field and writes it to the buffer of the serializer
+func (s *Serializer) WriteField(field reflect.Value, kind reflect.Kind) error {
+	var err error
+	s.out, err = s.appendValue(s.out[:0], field)
+	if err != nil {
+		return err
 	}
-	return nil
+	_, err = s.buffer.Write(s.out)
+	return err
 }
 
 func GetValue(value reflect.Value) reflect.Value {
@@ -198,67 +242,6 @@ func GetValue(value reflect.Value) reflect.Value {
 	return value
 }
 
-// serializeField serializes the given field
-func (s *Serializer) serializeField(field reflect.Value) error {
-	// Get the field kind
-	kind := field.Kind()
-
-	// Check if the field is a struct
-	if kind == reflect.Struct {
-		// Serialize the struct
-		return s.serialize(field.Interface())
-	}
-
-	// Get the field size
-	usize := field.Type().Size()
-
-	// Write the field data
-	// If the field is a string, we need to convert it to a byte slice
-	var ndata []byte
-	switch kind {
-	case reflect.String:
-		ndata = []byte(field.String())
-	case reflect.Bool:
-		ndata = []byte{0}
-		if field.Bool() {
-			ndata[0] = 1
-		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		ndata = make([]byte, usize)
-		binary.LittleEndian.PutUint64(ndata, uint64(field.Int()))
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		ndata = make([]byte, usize)
-		binary.LittleEndian.PutUint64(ndata, field.Uint())
-	case reflect.Float32, reflect.Float64:
-		ndata = make([]byte, usize)
-		binary.LittleEndian.PutUint64(ndata, math.Float64bits(field.Float()))
-	case reflect.Complex64, reflect.Complex128:
-		ndata = make([]byte, usize)
-		binary.LittleEndian.PutUint64(ndata, math.Float64bits(real(field.Complex())))
-		binary.LittleEndian.PutUint64(ndata[usize/2:], math.Float64bits(imag(field.Complex())))
-	case reflect.Slice:
-		return s.WriteField(field, kind)
-	case reflect.Map:
-		return s.WriteField(field, kind)
-	case reflect.Ptr:
-		return s.serializeField(field.Elem())
-	default:
-		ndata = field.Bytes()
-	}
-	// Get data
-	data, err := GetBytes(ndata)
-	if err != nil {
-		return err
-	}
-	// Write the field data
-	_, err = s.buffer.Write(data)
-	if err != nil {
-		return fmt.Errorf("failed to write field data: " + err.Error())
-	}
-
-	return nil
-}
-
 func GetBytes(ndata []byte) ([]byte, error) {
 	// Convert uinptr to uint64
 	size := uint16(len(ndata))
@@ -284,220 +267,3 @@ func (s *Serializer) CheckTag(dataType reflect.Type, field reflect.Value, i int)
 		return false
 	}
 }
-
-// deserialize deserializes the given data
-func (s *Serializer) deserialize(data interface{}) error {
-	// Get the value of the data
-	value := reflect.ValueOf(data)
-
-	// Check if the data is a pointer
-	if value.Kind() != reflect.Ptr {
-		return fmt.Errorf("data is not a pointer %s", value.Kind())
-	}
-
-	// Get the value of the data
-	value = value.Elem()
-
-	// Check if the data is a struct
-	if value.Kind() != reflect.Struct {
-		return fmt.Errorf("deserialization error; data is not a struct [%s]", value.Kind())
-	}
-
-	// Get the type of the data
-	dataType := value.Type()
-
-	// Get the number of fields
-	numFields := value.NumField()
-
-	// Loop through all fields
-	for i := 0; i < numFields; i++ {
-		// Get the field
-		field := value.Field(i)
-
-		// Check field tags
-		if !s.CheckTag(dataType, field, i) {
-			continue
-		}
-		// Get the field type
-		fieldType := dataType.Field(i)
-
-		// Check if the field is exported
-		if fieldType.PkgPath != "" {
-			continue
-		}
-
-		// Check if the field is a slice or a map
-		if field.Kind() == reflect.Slice {
-			s.deserializeSlice(field)
-		} else if field.Kind() == reflect.Map {
-			s.deserializeMap(field)
-		} else {
-			// Deserialize the field
-			err := s.deserializeField(field)
-			if err != nil {
-				return fmt.Errorf("failed to deserialize field: " + err.Error())
-			}
-		}
-	}
-
-	return nil
-}
-
-func (s *Serializer) deserializeSlice(field reflect.Value) error {
-	// Deserialize the field
-	// Get the length of the slice
-	var length uint32
-	err := binary.Read(s.buffer, binary.LittleEndian, &length)
-	if err != nil {
-		return fmt.Errorf("failed to read slice length: " + err.Error())
-	}
-
-	// Create a new slice
-	field.Set(reflect.MakeSlice(field.Type(), int(length), int(length)))
-	// Loop through all elements
-	for j := 0; j < int(length); j++ {
-		// Deserialize the element
-		err := s.deserializeField(field.Index(j))
-		if err != nil {
-			return fmt.Errorf("failed to deserialize slice element: " + err.Error())
-		}
-	}
-	return nil
-}
-
-func (s *Serializer) deserializeMap(field reflect.Value) error {
-	// Deserialize the field
-	// Get the length of the map
-	var length uint32
-	err := binary.Read(s.buffer, binary.LittleEndian, &length)
-	if err != nil {
-		return fmt.Errorf("failed to read map length: " + err.Error())
-	}
-	// Create a new map
-	field.Set(reflect.MakeMap(field.Type()))
-	// Loop through all elements
-	for j := 0; j < int(length); j++ {
-		// Deserialize the key
-		key := reflect.New(field.Type().Key()).Elem()
-		err := s.deserializeField(key)
-		if err != nil {
-			return fmt.Errorf("failed to deserialize map key: " + err.Error())
-		}
-		// Deserialize the value
-		value := reflect.New(field.Type().Elem()).Elem()
-		err = s.deserializeField(value)
-		if err != nil {
-			return fmt.Errorf("failed to deserialize map value: " + err.Error())
-		}
-		// Set the map value
-		field.SetMapIndex(key, value)
-	}
-	return nil
-}
-
-// deserializeField deserializes the given field
-func (s *Serializer) deserializeField(field reflect.Value) error {
-	// Get the field kind
-	kind := field.Kind()
-
-	// Check if the field is a struct
-	switch kind {
-	case reflect.Struct:
-		return s.deserialize(field.Addr().Interface())
-	case reflect.Ptr:
-		// Get the pointer type
-		// Check if the pointer is nil
-		if ptrType := field.Type(); field.IsNil() {
-			// Create a new pointer
-			field.Set(reflect.New(ptrType.Elem()))
-		}
-		// Deserialize the pointer
-		return s.deserialize(field.Interface())
-	case reflect.Slice:
-		return s.deserializeSlice(field)
-	case reflect.Map:
-		return s.deserializeMap(field)
-	}
-
-	// Get the field size
-	var size uint16
-	err := binary.Read(s.buffer, binary.LittleEndian, &size)
-	if err != nil {
-		return fmt.Errorf("failed to read field size: %v", err)
-	}
-
-	// Read field data for the given size
-	data := make([]byte, size)
-	err = binary.Read(s.buffer, binary.LittleEndian, data)
-	if err != nil {
-		return fmt.Errorf("failed to read field data: %v", err)
-	}
-
-	// Set the field data
-	// If the field is a string, we need to convert it to a string
-	switch kind {
-	case reflect.String:
-		field.SetString(string(data))
-	case reflect.Bool:
-		field.SetBool(data[0] == 1)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		field.SetInt(int64(binary.LittleEndian.Uint64(data)))
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		field.SetUint(binary.LittleEndian.Uint64(data))
-	case reflect.Float32, reflect.Float64:
-		field.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)))
-	case reflect.Complex64, reflect.Complex128:
-		field.SetComplex(complex(math.Float64frombits(binary.LittleEndian.Uint64(data)), math.Float64frombits(binary.LittleEndian.Uint64(data[8:]))))
-	default:
-		field.SetBytes(data)
-	}
-
-	return nil
-}
-
-func Compress(data []byte) ([]byte, error) {
-	// Create a new buffer
-	buffer := new(bytes.Buffer)
-
-	// Create a new gzip writer
-	writer := gzip.NewWriter(buffer)
-
-	// Write the data to the writer
-	_, err := writer.Write(data)
-	if err != nil {
-		return nil, err
-	}
-
-	// Close the writer
-	err = writer.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	return buffer.Bytes(), nil
-}
-
-func Decompress(data []byte) ([]byte, error) {
-	// Create a new buffer
-	buffer := bytes.NewBuffer(data)
-
-	// Create a new gzip reader
-	reader, err := gzip.NewReader(buffer)
-	if err != nil {
-		return nil, err
-	}
-
-	// Read the data from the reader
-	result, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, err
-	}
-
-	// Close the reader
-	err = reader.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	return result, nil
-}
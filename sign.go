@@ -0,0 +1,199 @@
+package tinyserializer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"sync"
+	"time"
+)
+
+// Signed payloads are wrapped in an envelope which keeps the payload readable:
+// [magic (2 bytes)][version (1 byte)][flags (1 byte)][key id (4 bytes)][optional expiry][payload][HMAC-SHA256 (32 bytes)]
+//
+// signedExpires: [expiry in unix milliseconds (8 bytes)]
+//
+// The MAC covers everything before it, so the key id and expiry can not be changed either.
+const (
+	signedMagic0 byte = 't'
+	signedMagic1 byte = 's'
+
+	signedVersion = 1
+	// Size of the envelope before the optional expiry
+	signedPrefixSize = 8
+
+	// The envelope holds an expiry time
+	signedExpires byte = 1
+)
+
+var (
+	// ErrSignature is returned when the signature of a payload is missing, invalid, or made with an unknown key
+	ErrSignature = errors.New("payload signature is invalid")
+	// ErrExpired is returned when a signed payload is read after its expiry time
+	ErrExpired = errors.New("signed payload has expired")
+)
+
+// Signer holds the HMAC-SHA256 keys to sign and verify payloads with, by their id.
+//
+// Payloads are signed with the primary key, and record its id so they can be verified
+// with any key of the signer. To rotate keys, add a new key and make it the primary key,
+// and remove the old key once no payloads signed with it are left.
+type Signer struct {
+	mu         sync.RWMutex
+	keys       map[uint32][]byte
+	primary    uint32
+	hasPrimary bool
+
+	// Clock to set and check expiry times with
+	now func() time.Time
+}
+
+// NewSigner creates a new signer without keys
+func NewSigner() *Signer {
+	return &Signer{
+		keys: make(map[uint32][]byte),
+		now:  time.Now,
+	}
+}
+
+// Add adds a key to the signer, which should be 32 random bytes.
+// The first key added becomes the primary key.
+// Adding a key under an id which is already in use is an error,
+// since payloads signed with the old key could no longer be verified.
+func (k *Signer) Add(id uint32, key []byte) error {
+	if len(key) == 0 {
+		return errors.New("signing key is empty")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("key with id %d is already in the signer", id)
+	}
+	k.keys[id] = append([]byte(nil), key...)
+	if !k.hasPrimary {
+		k.primary, k.hasPrimary = id, true
+	}
+	return nil
+}
+
+// SetPrimary sets the key to sign payloads with
+func (k *Signer) SetPrimary(id uint32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return fmt.Errorf("key with id %d is not in the signer", id)
+	}
+	k.primary, k.hasPrimary = id, true
+	return nil
+}
+
+// Remove removes the key from the signer, payloads signed with it can no longer be verified.
+// The primary key can not be removed.
+func (k *Signer) Remove(id uint32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.hasPrimary && k.primary == id {
+		return fmt.Errorf("key with id %d is the primary key", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// mac returns a MAC for the key with the given id, or nil if the key is unknown
+func (k *Signer) mac(id uint32) hash.Hash {
+	k.mu.RLock()
+	var key = k.keys[id]
+	k.mu.RUnlock()
+	if key == nil {
+		return nil
+	}
+	return hmac.New(sha256.New, key)
+}
+
+// sign appends the envelope of the signed payload to dst.
+// If ttl is not zero, the payload expires after it.
+func (k *Signer) sign(dst, payload []byte, ttl time.Duration) ([]byte, error) {
+	k.mu.RLock()
+	var id = k.primary
+	k.mu.RUnlock()
+	var mac = k.mac(id)
+	if mac == nil {
+		return nil, errors.New("signer has no primary key")
+	}
+
+	var start = len(dst)
+	var flags byte
+	if ttl != 0 {
+		flags |= signedExpires
+	}
+	dst = append(dst, signedMagic0, signedMagic1, signedVersion, flags)
+	dst = binary.LittleEndian.AppendUint32(dst, id)
+	if ttl != 0 {
+		dst = binary.LittleEndian.AppendUint64(dst, uint64(k.now().Add(ttl).UnixMilli()))
+	}
+	dst = append(dst, payload...)
+	mac.Write(dst[start:])
+	return mac.Sum(dst), nil
+}
+
+// isSigned reports whether data starts with the signed envelope magic
+func isSigned(data []byte) bool {
+	return len(data) >= signedPrefixSize && data[0] == signedMagic0 && data[1] == signedMagic1
+}
+
+// verify checks the signature and expiry of the envelope, and returns the payload in it
+func (k *Signer) verify(data []byte) ([]byte, error) {
+	if !isSigned(data) {
+		return nil, fmt.Errorf("%w: data is not signed", ErrSignature)
+	}
+	if data[2] != signedVersion {
+		return nil, fmt.Errorf("%w: unknown envelope version %d", ErrSignature, data[2])
+	}
+	var flags = data[3]
+	if flags&^signedExpires != 0 {
+		return nil, fmt.Errorf("%w: unknown envelope flags %#x", ErrSignature, flags)
+	}
+	var id = binary.LittleEndian.Uint32(data[4:])
+	var mac = k.mac(id)
+	if mac == nil {
+		return nil, fmt.Errorf("%w: unknown key %d", ErrSignature, id)
+	}
+
+	var prefix = signedPrefixSize
+	if flags&signedExpires != 0 {
+		prefix += 8
+	}
+	if len(data) < prefix+sha256.Size {
+		return nil, fmt.Errorf("%w: envelope is truncated", ErrSignature)
+	}
+	var signed, signature = data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	mac.Write(signed)
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return nil, ErrSignature
+	}
+
+	if flags&signedExpires != 0 {
+		var expiry = time.UnixMilli(int64(binary.LittleEndian.Uint64(data[signedPrefixSize:])))
+		if !k.now().Before(expiry) {
+			return nil, fmt.Errorf("%w at %s", ErrExpired, expiry.UTC().Format(time.RFC3339))
+		}
+	}
+	return signed[prefix:], nil
+}
+
+// SetSigning sets the signer to sign serialized payloads with, using HMAC-SHA256.
+// If ttl is not zero, signed payloads record an expiry time ttl from now, and are rejected with ErrExpired after it.
+//
+// Signed payloads stay readable, but can not be modified without the key.
+// With a signer set, Deserialize verifies the signature in constant time and rejects payloads which are
+// not signed with ErrSignature. Setting a nil signer disables signing.
+//
+// Signing can be combined with encryption, the signed envelope is encrypted.
+func (s *Serializer) SetSigning(signer *Signer, ttl time.Duration) *Serializer {
+	s.signer, s.signatureTTL = signer, ttl
+	return s
+}
@@ -0,0 +1,174 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type signToken struct {
+	Subject string `tiny:"sub"`
+	Scope   string `tiny:"scope"`
+}
+
+func testSigner(t *testing.T, ids ...uint32) *Signer {
+	var signer = NewSigner()
+	for _, id := range ids {
+		if err := signer.Add(id, bytes.Repeat([]byte{byte(id)}, 32)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signer
+}
+
+func TestSigning(t *testing.T) {
+	var s = NewSerializer().SetSigning(testSigner(t, 1), 0)
+	data, err := s.AppendSerialize(nil, &signToken{Subject: "ada", Scope: "read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Signed payloads stay readable
+	if !bytes.Contains(data, []byte("ada")) {
+		t.Fatal("expected the payload to be readable")
+	}
+
+	var decoded signToken
+	if err = s.Deserialize(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Subject != "ada" || decoded.Scope != "read" {
+		t.Fatalf("expected the token, got %+v", decoded)
+	}
+	if scope, err := s.View(data, &decoded).Field("scope").Text(); err != nil || scope != "read" {
+		t.Fatalf("expected to view the scope, got %q, %v", scope, err)
+	}
+
+	// Changing any byte invalidates the signature
+	for i := range data {
+		var tampered = append([]byte(nil), data...)
+		tampered[i] ^= 1
+		if err = s.Deserialize(tampered, &decoded); !errors.Is(err, ErrSignature) {
+			t.Fatalf("expected %v after changing byte %d, got %v", ErrSignature, i, err)
+		}
+	}
+	if err = s.Deserialize(data[:len(data)-1], &decoded); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected %v for a truncated payload, got %v", ErrSignature, err)
+	}
+
+	// A forged token without a signature is rejected
+	forged, err := NewSerializer().AppendSerialize(nil, &signToken{Subject: "ada", Scope: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Deserialize(forged, &decoded); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected %v for an unsigned payload, got %v", ErrSignature, err)
+	}
+
+	// Signatures made with other keys are rejected
+	var other = NewSigner()
+	if err = other.Add(1, []byte("another key")); err != nil {
+		t.Fatal(err)
+	}
+	if err = NewSerializer().SetSigning(other, 0).Deserialize(data, &decoded); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected %v for another key, got %v", ErrSignature, err)
+	}
+	if err = NewSerializer().SetSigning(testSigner(t, 2), 0).Deserialize(data, &decoded); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected %v for an unknown key, got %v", ErrSignature, err)
+	}
+}
+
+func TestSigningExpiry(t *testing.T) {
+	var now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var signer = testSigner(t, 1)
+	signer.now = func() time.Time { return now }
+
+	var s = NewSerializer().SetSigning(signer, time.Hour)
+	data, err := s.AppendSerialize(nil, &signToken{Subject: "ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded signToken
+	now = now.Add(59 * time.Minute)
+	if err = s.Deserialize(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if err = s.Deserialize(data, &decoded); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected %v, got %v", ErrExpired, err)
+	}
+
+	// The expiry is signed, so it can not be extended
+	var extended = append([]byte(nil), data...)
+	extended[signedPrefixSize+3]++
+	if err = s.Deserialize(extended, &decoded); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected %v for a changed expiry, got %v", ErrSignature, err)
+	}
+}
+
+func TestSigningRotation(t *testing.T) {
+	var signer = testSigner(t, 1)
+	var s = NewSerializer().SetSigning(signer, 0)
+	old, err := s.AppendSerialize(nil, &signToken{Subject: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = signer.Add(2, bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if err = signer.SetPrimary(2); err != nil {
+		t.Fatal(err)
+	}
+	current, err := s.AppendSerialize(nil, &signToken{Subject: "current"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the new key can verify the current payload
+	var decoded signToken
+	for _, data := range [][]byte{old, current} {
+		if err = s.Deserialize(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = NewSerializer().SetSigning(testSigner(t, 1), 0).Deserialize(current, &decoded); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected %v for the old key, got %v", ErrSignature, err)
+	}
+
+	if err = signer.Remove(1); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Deserialize(old, &decoded); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected %v for a removed key, got %v", ErrSignature, err)
+	}
+	if err = signer.Remove(2); err == nil {
+		t.Fatal("expected an error removing the primary key")
+	}
+	if err = signer.Add(3, nil); err == nil {
+		t.Fatal("expected an error for an empty key")
+	}
+}
+
+func TestSigningWithEncryption(t *testing.T) {
+	var s = NewSerializer().SetSigning(testSigner(t, 1), time.Hour).SetEncryption(testKeyring(t, 1))
+	data, err := s.AppendSerialize(nil, &signToken{Subject: "ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(data) || bytes.Contains(data, []byte("ada")) {
+		t.Fatal("expected the signed payload to be encrypted")
+	}
+	var decoded signToken
+	if err = s.Deserialize(data, &decoded); err != nil || decoded.Subject != "ada" {
+		t.Fatalf("expected the token, got %+v, %v", decoded, err)
+	}
+
+	var out strings.Builder
+	if err = s.Dump(&out, data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "signed with key 1, expires ") || !strings.Contains(out.String(), "signature verified") {
+		t.Fatalf("expected the envelope in the dump, got\n%s", out.String())
+	}
+}
@@ -0,0 +1,85 @@
+package tinyserializer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrRequired is returned when a field with the required option is missing from the payload or empty
+var ErrRequired = errors.New("required field is missing or empty")
+
+// Validator is implemented by structs which check their own values after they are decoded.
+//
+// Deserialize calls Validate on every decoded struct, after its fields, and the structs nested in them,
+// have been decoded and validated. Projections only validate the values of the selected fields,
+// not the partially decoded structs holding them. Views do not validate.
+type Validator interface {
+	Validate() error
+}
+
+var validatorType = reflect.TypeOf((*Validator)(nil)).Elem()
+
+// ValidationError is returned when a decoded value is invalid.
+//
+// Path is the path of the invalid value, with struct fields separated by dots,
+// slice and array elements as [index] and map values as [key]. It is empty for the top level value.
+type ValidationError struct {
+	Path string
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("invalid value: %v", e.Err)
+	}
+	return fmt.Sprintf("invalid field %s: %v", e.Path, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// in prefixes the path of the error with the field or element holding the invalid value
+func (e *ValidationError) in(elem string) *ValidationError {
+	switch {
+	case e.Path == "":
+		e.Path = elem
+	case strings.HasPrefix(e.Path, "["):
+		e.Path = elem + e.Path
+	default:
+		e.Path = elem + "." + e.Path
+	}
+	return e
+}
+
+// validateStruct checks the required fields of a decoded struct, and calls its Validate method
+func validateStruct(value reflect.Value, info *structInfo) error {
+	if info.required {
+		for i := range info.fields {
+			var fi = &info.fields[i]
+			if fi.required && isEmpty(value.Field(fi.index)) {
+				return &ValidationError{Path: fi.name, Err: ErrRequired}
+			}
+		}
+	}
+	if info.validator && value.CanAddr() {
+		if err := value.Addr().Interface().(Validator).Validate(); err != nil {
+			return &ValidationError{Err: err}
+		}
+	}
+	return nil
+}
+
+// isEmpty reports whether the value is zero, or an empty string, slice or map
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	case reflect.Ptr:
+		return value.IsNil() || isEmpty(value.Elem())
+	default:
+		return value.IsZero()
+	}
+}
@@ -0,0 +1,143 @@
+package tinyserializer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type validateAddress struct {
+	Street string `tiny:"street,required"`
+	Number int32  `tiny:"number"`
+}
+
+func (a *validateAddress) Validate() error {
+	if a.Number < 0 {
+		return fmt.Errorf("negative house number %d", a.Number)
+	}
+	return nil
+}
+
+type validateUser struct {
+	Name      string                     `tiny:"name,required"`
+	Addresses []validateAddress          `tiny:"addresses"`
+	Contacts  map[string]validateAddress `tiny:"contacts"`
+	Tags      []string                   `tiny:"tags,required"`
+}
+
+func (u *validateUser) Validate() error {
+	if u.Name == "root" {
+		return errors.New("reserved name")
+	}
+	return nil
+}
+
+func TestRequiredFields(t *testing.T) {
+	var s = NewSerializer()
+	var valid = validateUser{
+		Name:      "ada",
+		Addresses: []validateAddress{{Street: "Main"}},
+		Contacts:  map[string]validateAddress{"home": {Street: "Side", Number: 2}},
+		Tags:      []string{"admin"},
+	}
+	data, err := s.AppendSerialize(nil, &valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded validateUser
+	if err = s.Deserialize(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name string
+		user validateUser
+		path string
+	}{
+		{"empty name", validateUser{Tags: []string{"a"}}, "name"},
+		{"empty tags", validateUser{Name: "ada", Tags: []string{}}, "tags"},
+		{"nested", validateUser{Name: "ada", Addresses: []validateAddress{{Street: "Main"}, {}}, Tags: []string{"a"}}, "addresses[1].street"},
+		{"map value", validateUser{Name: "ada", Contacts: map[string]validateAddress{"work": {}}, Tags: []string{"a"}}, "contacts[work].street"},
+	} {
+		data, err := s.AppendSerialize(nil, &test.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.Deserialize(data, &decoded)
+		var verr *ValidationError
+		if !errors.As(err, &verr) || verr.Path != test.path || !errors.Is(err, ErrRequired) {
+			t.Errorf("%s: expected required error at %s, got %v", test.name, test.path, err)
+		}
+	}
+
+	// A required field missing from the end of the payload is reported by its path
+	data, err = s.AppendSerialize(nil, &struct {
+		Name      string                     `tiny:"name"`
+		Addresses []validateAddress          `tiny:"addresses"`
+		Contacts  map[string]validateAddress `tiny:"contacts"`
+	}{Name: "ada"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded = validateUser{Tags: []string{"stale"}}
+	if err = s.Deserialize(data, &decoded); !errors.Is(err, ErrRequired) || err.Error() != "invalid field tags: required field is missing or empty" {
+		t.Fatalf("expected the missing tags to be reported, got %v", err)
+	}
+}
+
+func TestValidator(t *testing.T) {
+	var s = NewSerializer()
+	data, err := s.AppendSerialize(nil, &validateUser{Name: "root", Tags: []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded validateUser
+	err = s.Deserialize(data, &decoded)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Path != "" || err.Error() != "invalid value: reserved name" {
+		t.Fatalf("expected the top level validation error, got %v", err)
+	}
+
+	data, err = s.AppendSerialize(nil, &validateUser{
+		Name:      "ada",
+		Addresses: []validateAddress{{Street: "Main", Number: -1}},
+		Tags:      []string{"a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Deserialize(data, &decoded); err == nil || err.Error() != "invalid field addresses[0]: negative house number -1" {
+		t.Fatalf("expected the nested validation error, got %v", err)
+	}
+
+	// Projections validate the selected values, but not the partially decoded user
+	decoded = validateUser{}
+	if err = s.DeserializeFields(data, &decoded, "name"); err != nil || decoded.Name != "ada" {
+		t.Fatalf("expected the projection to skip validation of the user, got %+v, %v", decoded, err)
+	}
+	if err = s.DeserializeFields(data, &decoded, "addresses"); err == nil || err.Error() != "invalid field addresses[0]: negative house number -1" {
+		t.Fatalf("expected the projection to validate the addresses, got %v", err)
+	}
+}
+
+type validateAddressV1 struct {
+	Street string `tiny:"street"`
+}
+
+func init() {
+	RegisterMigration(1, 2, func(old validateAddressV1) validateAddress {
+		return validateAddress{Street: old.Street, Number: -1}
+	})
+}
+
+func TestValidateMigrated(t *testing.T) {
+	var s = NewSerializer().SetVersioning(true)
+	data, err := s.Serialize(&validateAddressV1{Street: "Main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded validateAddress
+	if err = s.Deserialize(data, &decoded); err == nil || err.Error() != "invalid value: negative house number -1" {
+		t.Fatalf("expected the migrated value to be validated, got %v", err)
+	}
+}
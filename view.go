@@ -0,0 +1,385 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrKeyNotFound is returned by View.Get when the map does not hold the key
+var ErrKeyNotFound = errors.New("key not found")
+
+// SetIndex sets whether an index of the fields of top level structs is written into the payload header.
+// Views use the index to jump directly to a field, instead of skipping over the fields before it.
+//
+// Indexed payloads always start with a header, the reader must have indexing enabled as well
+// unless compression is enabled on both sides.
+func (s *Serializer) SetIndex(index bool) *Serializer {
+	s.index = index
+	return s
+}
+
+// View provides lazy, read-only access to a serialized value, without decoding it into Go values.
+//
+// Views are navigated by the tag names of fields, map keys and slice indexes:
+//
+//	v := s.View(data, (*Testie)(nil))
+//	n, err := v.Field("all").Map("mapint").Get("Hello").Int()
+//
+// Errors are carried along the chain, and returned by the accessor at the end of it.
+// Omitempty fields which are left out of the data are viewed as their zero value.
+type View struct {
+	// Data starting at the value
+	data []byte
+	typ  reflect.Type
+	// Packing of the serializer, and whether this value is a packed field
+	packing Packing
+	packed  bool
+	// Field offsets of the top level struct
+	index []byte
+	err   error
+}
+
+// View returns a view of the data, which must have been serialized from a value of the type of v.
+// v is only used for its type, it can be a nil pointer.
+//
+// Compressed and encrypted data is decompressed and decrypted into a new buffer, other data is not copied
+// and must not be modified while the view is in use.
+func (s *Serializer) View(data []byte, v interface{}) View {
+	var view = View{typ: reflect.TypeOf(v), packing: s.packing}
+	if view.typ == nil {
+		return View{err: errors.New("cannot view data without a type")}
+	}
+	view.typ = derefType(view.typ)
+
+	data, err := s.openPayload(data, true)
+	if err != nil {
+		return View{err: err}
+	}
+
+	if s.headered() {
+		// The view keeps the decompressed data alive, so the buffer can not be reused
+		s.decompressBuffer = nil
+		h, payload, err := s.readPayload(data)
+		if err == nil {
+			err = checkVersion(h, view.typ)
+		}
+		if err == nil {
+			err = checkFingerprint(h, view.typ)
+		}
+		if err != nil {
+			return View{err: err}
+		}
+		data, view.index = payload, h.index
+	}
+	view.data = data
+	return view
+}
+
+// Err returns the error which occurred while navigating to the view
+func (v View) Err() error {
+	return v.err
+}
+
+// Type returns the type of the viewed value
+func (v View) Type() reflect.Type {
+	return v.typ
+}
+
+func (v View) fail(err error) View {
+	return View{err: err}
+}
+
+// reader returns a serializer reading from the start of the view
+func (v View) reader() *Serializer {
+	return &Serializer{reader: reader{data: v.data}, packing: v.packing}
+}
+
+// at returns a view of the value of type t at pos
+func (v View) at(pos int, t reflect.Type) View {
+	return View{data: v.data[pos:], typ: derefType(t), packing: v.packing}
+}
+
+// Field returns a view of the struct field with the given tag name
+func (v View) Field(name string) View {
+	if v.err != nil {
+		return v
+	}
+	if v.typ.Kind() != reflect.Struct {
+		return v.fail(fmt.Errorf("cannot get field %s of %s", name, v.typ))
+	}
+
+	var info = getStructInfo(v.typ)
+	if info.err != nil {
+		return v.fail(info.err)
+	}
+	if v.index != nil && len(v.index) != len(info.fields)*4 {
+		return v.fail(fmt.Errorf("index of %d fields does not match %s", len(v.index)/4, v.typ))
+	}
+	var s = v.reader()
+	for i := range info.fields {
+		var fi = &info.fields[i]
+		if fi.name != name && v.index != nil {
+			continue
+		}
+		if v.index != nil {
+			s.pos = int(binary.LittleEndian.Uint32(v.index[i*4:]))
+			if s.pos > len(v.data) {
+				return v.fail(ErrInvalidHeader)
+			}
+		}
+		if fi.omitEmpty {
+			present, err := s.readPresence()
+			if err != nil {
+				return v.fail(WrapFieldError(fi.name, err))
+			}
+			if !present && fi.name == name {
+				return v.zero(fi)
+			}
+			if !present {
+				continue
+			}
+		}
+		if fi.name == name {
+			var field = v.at(s.pos, fi.typ)
+			field.packed = fi.packing != PackNone
+			return field
+		}
+		if err := s.skipField(reflect.Zero(fi.typ), fi); err != nil {
+			return v.fail(WrapFieldError(fi.name, err))
+		}
+	}
+	return v.fail(fmt.Errorf("unknown field %s in %s", name, v.typ))
+}
+
+// zero returns a view of the zero value of an omitempty field which is left out of the data
+func (v View) zero(fi *fieldInfo) View {
+	var s = v.reader()
+	var data []byte
+	var err error
+	if fi.packing != PackNone {
+		data = appendPacked(nil, reflect.Zero(fi.typ), fi.packing, false)
+	} else if data, err = s.appendValue(nil, reflect.Zero(fi.typ)); err != nil {
+		return v.fail(WrapFieldError(fi.name, err))
+	}
+	var field = View{data: data, typ: derefType(fi.typ), packing: v.packing}
+	field.packed = fi.packing != PackNone
+	return field
+}
+
+// Map returns a view of the struct field with the given tag name, which must be a map
+func (v View) Map(name string) View {
+	var field = v.Field(name)
+	if field.err == nil && field.typ.Kind() != reflect.Map {
+		return v.fail(fmt.Errorf("field %s is not a map", name))
+	}
+	return field
+}
+
+// Get returns a view of the map value stored under key.
+// The key must be convertible to the key type of the map.
+func (v View) Get(key interface{}) View {
+	if v.err != nil {
+		return v
+	}
+	if v.typ.Kind() != reflect.Map {
+		return v.fail(fmt.Errorf("cannot get key of %s", v.typ))
+	}
+	var keyType = v.typ.Key()
+	var keyValue = reflect.ValueOf(key)
+	if !keyValue.IsValid() || !keyValue.Type().ConvertibleTo(keyType) {
+		return v.fail(fmt.Errorf("cannot use %T as key of %s", key, v.typ))
+	}
+
+	// Keys are compared by their encoding, so they do not have to be decoded
+	var s = v.reader()
+	encoded, err := s.appendValue(nil, keyValue.Convert(keyType))
+	if err != nil {
+		return v.fail(err)
+	}
+	length, err := s.readLength()
+	if err == nil {
+		err = s.checkLength(length, encodedSize(keyType)+encodedSize(v.typ.Elem()))
+	}
+	if err != nil {
+		return v.fail(fmt.Errorf("failed to read map length: %w", err))
+	}
+	var zeroKey, zeroElem = reflect.Zero(keyType), reflect.Zero(v.typ.Elem())
+	for i := 0; i < length; i++ {
+		var start = s.pos
+		if err = s.skipValue(zeroKey); err != nil {
+			return v.fail(fmt.Errorf("failed to read map key: %w", err))
+		}
+		if bytes.Equal(v.data[start:s.pos], encoded) {
+			return v.at(s.pos, v.typ.Elem())
+		}
+		if err = s.skipValue(zeroElem); err != nil {
+			return v.fail(fmt.Errorf("failed to read map value: %w", err))
+		}
+	}
+	return v.fail(fmt.Errorf("%w: %v", ErrKeyNotFound, key))
+}
+
+// Index returns a view of the element at index i of a slice or array.
+// Elements of byte slices and packed slices can not be viewed, use Bytes or Decode instead.
+func (v View) Index(i int) View {
+	if v.err != nil {
+		return v
+	}
+	var kind = v.typ.Kind()
+	if kind != reflect.Slice && kind != reflect.Array || v.isBlock() {
+		return v.fail(fmt.Errorf("cannot index %s", v.typ))
+	}
+
+	var s = v.reader()
+	length, err := s.readLength()
+	if err != nil {
+		return v.fail(fmt.Errorf("failed to read slice length: %w", err))
+	}
+	if i < 0 || i >= length {
+		return v.fail(fmt.Errorf("index %d out of range with length %d", i, length))
+	}
+	var zeroElem = reflect.Zero(v.typ.Elem())
+	for j := 0; j < i; j++ {
+		if err = s.skipValue(zeroElem); err != nil {
+			return v.fail(fmt.Errorf("failed to read slice element: %w", err))
+		}
+	}
+	return v.at(s.pos, v.typ.Elem())
+}
+
+// isBlock reports whether the value is a byte slice or packed slice, whose elements are not encoded separately
+func (v View) isBlock() bool {
+	if v.typ.Kind() != reflect.Slice {
+		return false
+	}
+	return v.typ.Elem().Kind() == reflect.Uint8 || v.packed || v.packing != PackNone && packable(v.typ)
+}
+
+// Len returns the length of a slice, array or map
+func (v View) Len() (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	switch v.typ.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+	default:
+		return 0, fmt.Errorf("cannot get length of %s", v.typ)
+	}
+	return v.reader().readLength()
+}
+
+// Decode decodes the viewed value into out, which must be a pointer to a value of its type
+func (v View) Decode(out interface{}) error {
+	if v.err != nil {
+		return v.err
+	}
+	var value = reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("data is not a pointer %s", value.Kind())
+	}
+	if derefType(value.Type().Elem()) != v.typ {
+		return fmt.Errorf("cannot decode %s into %s", v.typ, value.Type().Elem())
+	}
+	var s = v.reader()
+	if !v.packed {
+		return s.decodeValue(value.Elem())
+	}
+	var elem = value.Elem()
+	for elem.Kind() == reflect.Ptr {
+		if elem.IsNil() {
+			elem.Set(reflect.New(elem.Type().Elem()))
+		}
+		elem = elem.Elem()
+	}
+	return s.decodePacked(elem)
+}
+
+// scalar reads the data of a scalar of one of the given kinds
+func (v View) scalar(kinds ...reflect.Kind) ([]byte, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	for _, kind := range kinds {
+		if v.typ.Kind() == kind {
+			return v.reader().readScalar()
+		}
+	}
+	return nil, fmt.Errorf("cannot read %s as %s", v.typ, kinds[0])
+}
+
+// Int returns the value of a signed integer
+func (v View) Int() (int64, error) {
+	data, err := v.scalar(reflect.Int64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32)
+	if err != nil {
+		return 0, err
+	}
+	return scalarInt(data)
+}
+
+// Uint returns the value of an unsigned integer
+func (v View) Uint() (uint64, error) {
+	data, err := v.scalar(reflect.Uint64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uintptr)
+	if err != nil {
+		return 0, err
+	}
+	return scalarUint(data)
+}
+
+// Float returns the value of a float
+func (v View) Float() (float64, error) {
+	data, err := v.scalar(reflect.Float64, reflect.Float32)
+	if err != nil {
+		return 0, err
+	}
+	return scalarFloat(data)
+}
+
+// Complex returns the value of a complex number
+func (v View) Complex() (complex128, error) {
+	data, err := v.scalar(reflect.Complex128, reflect.Complex64)
+	if err != nil {
+		return 0, err
+	}
+	return scalarComplex(data)
+}
+
+// Bool returns the value of a bool
+func (v View) Bool() (bool, error) {
+	data, err := v.scalar(reflect.Bool)
+	if err != nil {
+		return false, err
+	}
+	return scalarBool(data)
+}
+
+// Text returns the value of a string
+func (v View) Text() (string, error) {
+	data, err := v.scalar(reflect.String)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Bytes returns a copy of the value of a byte slice
+func (v View) Bytes() ([]byte, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	if v.typ.Kind() != reflect.Slice || v.typ.Elem().Kind() != reflect.Uint8 {
+		return nil, fmt.Errorf("cannot read %s as []byte", v.typ)
+	}
+	var r = Reader{r: reader{data: v.data}}
+	return r.Bytes()
+}
+
+// derefType returns the type pointers of t point to, as they are encoded as their element
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,200 @@
+package tinyserializer
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type ViewStruct struct {
+	Name     string           `tiny:"name"`
+	Optional string           `tiny:"optional,omitempty"`
+	Packed   []int64          `tiny:"packed,packed=delta"`
+	Bytes    []byte           `tiny:"bytes"`
+	Testie   *Testie          `tiny:"testie"`
+	Route    map[string]int32 `tiny:"route"`
+	Float    float32          `tiny:"float"`
+}
+
+var viewStruct = ViewStruct{
+	Name:   "name",
+	Packed: []int64{1, 2, 3},
+	Bytes:  []byte("bytes"),
+	Testie: &testStruct,
+	Route:  map[string]int32{"a": 1, "b": 2, "c": 3},
+	Float:  1.5,
+}
+
+func TestView(t *testing.T) {
+	for _, s := range []*Serializer{
+		NewSerializer(),
+		NewSerializer().SetIndex(true),
+		NewSerializer().SetIndex(true).SetCompress(true).SetCompressThreshold(0),
+		NewSerializer().SetPacking(PackVarint),
+	} {
+		data, err := s.AppendSerialize(nil, &viewStruct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded ViewStruct
+		if err = s.Deserialize(data, &decoded); err != nil || !reflect.DeepEqual(decoded, viewStruct) {
+			t.Fatalf("expected %+v, got %+v (%v)", viewStruct, decoded, err)
+		}
+
+		var view = s.View(data, (*ViewStruct)(nil))
+
+		n, err := view.Field("testie").Field("all").Map("mapint").Get("World").Int()
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2, got %d (%v)", n, err)
+		}
+		str, err := view.Field("testie").Field("listmapstruct").Index(1).Get(2).Field("liststring").Index(1).Text()
+		if err != nil || str != "World" {
+			t.Fatalf("expected World, got %q (%v)", str, err)
+		}
+		route, err := view.Map("route").Get("c").Int()
+		if err != nil || route != 3 {
+			t.Fatalf("expected 3, got %d (%v)", route, err)
+		}
+		f, err := view.Field("float").Float()
+		if err != nil || f != 1.5 {
+			t.Fatalf("expected 1.5, got %v (%v)", f, err)
+		}
+		b, err := view.Field("bytes").Bytes()
+		if err != nil || !bytes.Equal(b, viewStruct.Bytes) {
+			t.Fatalf("expected %q, got %q (%v)", viewStruct.Bytes, b, err)
+		}
+		length, err := view.Field("testie").Field("intlist").Len()
+		if err != nil || length != len(testStruct.Intlist) {
+			t.Fatalf("expected %d, got %d (%v)", len(testStruct.Intlist), length, err)
+		}
+
+		var packed []int64
+		if err = view.Field("packed").Decode(&packed); err != nil || !reflect.DeepEqual(packed, viewStruct.Packed) {
+			t.Fatalf("expected %v, got %v (%v)", viewStruct.Packed, packed, err)
+		}
+		var all AllStruct
+		if err = view.Field("testie").Field("all").Decode(&all); err != nil || !reflect.DeepEqual(&all, testStruct.All) {
+			t.Fatalf("expected %+v, got %+v (%v)", testStruct.All, all, err)
+		}
+	}
+}
+
+func TestViewOmitEmpty(t *testing.T) {
+	type omitItem struct {
+		Note string `tiny:"note,omitempty"`
+		ID   int64  `tiny:"id"`
+	}
+	type omitStruct struct {
+		Optional string     `tiny:"optional,omitempty"`
+		Items    []omitItem `tiny:"items,omitempty"`
+		Last     string     `tiny:"last"`
+	}
+	for _, s := range []*Serializer{NewSerializer(), NewSerializer().SetIndex(true)} {
+		for _, value := range []omitStruct{
+			{Optional: "x", Items: []omitItem{{Note: "n", ID: 1}, {ID: 2}}, Last: "last"},
+			{Last: "last"},
+		} {
+			data, err := s.AppendSerialize(nil, &value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var view = s.View(data, (*omitStruct)(nil))
+			if last, err := view.Field("last").Text(); err != nil || last != "last" {
+				t.Fatalf("expected last, got %q (%v)", last, err)
+			}
+			// Fields which are left out are viewed as their zero value
+			if optional, err := view.Field("optional").Text(); err != nil || optional != value.Optional {
+				t.Fatalf("expected %q, got %q (%v)", value.Optional, optional, err)
+			}
+			if n, err := view.Field("items").Len(); err != nil || n != len(value.Items) {
+				t.Fatalf("expected %d items, got %d (%v)", len(value.Items), n, err)
+			}
+			if len(value.Items) > 0 {
+				if id, err := view.Field("items").Index(1).Field("id").Int(); err != nil || id != 2 {
+					t.Fatalf("expected 2, got %d (%v)", id, err)
+				}
+			}
+		}
+	}
+}
+
+func TestViewLinkedList(t *testing.T) {
+	var s = NewSerializer()
+	data, err := s.AppendSerialize(nil, &linkedList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var view = s.View(data, (*listNode)(nil))
+	if name, err := view.Field("next").Field("next").Field("name").Text(); err != nil || name != "c" {
+		t.Fatalf("expected c, got %q (%v)", name, err)
+	}
+	// The missing node after the last one is viewed as its zero value
+	if name, err := view.Field("next").Field("next").Field("next").Field("name").Text(); err != nil || name != "" {
+		t.Fatalf("expected an empty name, got %q (%v)", name, err)
+	}
+}
+
+func TestViewErrors(t *testing.T) {
+	var s = NewSerializer()
+	data, err := s.AppendSerialize(nil, &viewStruct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var view = s.View(data, ViewStruct{})
+
+	if _, err = view.Map("route").Get("missing").Int(); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected %v, got %v", ErrKeyNotFound, err)
+	}
+	var invalid = []View{
+		view.Field("missing"),
+		view.Map("name"),
+		view.Field("name").Field("name"),
+		view.Field("packed").Index(0),
+		view.Field("testie").Field("intlist").Index(10),
+		view.Map("route").Get(1.5),
+		s.View(data[:10], ViewStruct{}).Field("testie").Field("all"),
+		s.View(data, nil),
+	}
+	for i, v := range invalid {
+		if v.Err() == nil {
+			t.Fatalf("%d: expected error", i)
+		}
+	}
+	if _, err = view.Field("name").Int(); err == nil {
+		t.Fatal("expected error reading a string as an int")
+	}
+	if _, err = view.Field("name").Bool(); err == nil {
+		t.Fatal("expected error reading a string as a bool")
+	}
+}
+
+func BenchmarkViewIndexed(b *testing.B) {
+	var s = NewSerializer().SetIndex(true)
+	data, err := s.AppendSerialize(nil, &viewStruct)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = s.View(data, (*ViewStruct)(nil)).Field("float").Float(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkView(b *testing.B) {
+	var s = NewSerializer()
+	data, err := s.AppendSerialize(nil, &viewStruct)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = s.View(data, (*ViewStruct)(nil)).Field("float").Float(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}